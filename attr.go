@@ -0,0 +1,87 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"strings"
+)
+
+const paxAttrPrefix = paxPrefix + "attr."
+
+// SetAttr sets attribute of the whole embedfs, like build id or release
+// channel, which is stored when Embedder is closed and can be obtained by
+// Attr method of EmbedFs.
+func (e *Embedder) SetAttr(key, value string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.attributes == nil {
+		e.attributes = map[string]string{}
+	}
+
+	e.attributes[key] = value
+}
+
+// Attr returns value of embedfs attribute set by SetAttr or empty string if
+// there is no such attribute.
+func (fs *EmbedFs) Attr(key string) string {
+	fs.attributesOnce.Do(func() {
+		fs.attributes = map[string]string{}
+
+		if fs.load() != nil {
+			return
+		}
+
+		for _, volume := range append([]*EmbedFs{fs}, fs.volumes...) {
+			volume.readAttributes(fs.attributes)
+		}
+	})
+
+	return fs.attributes[key]
+}
+
+// writeAttributes writes attributes as global header in the end of
+// embedfs.
+func (e *Embedder) writeAttributes() error {
+	if len(e.attributes) == 0 {
+		return nil
+	}
+
+	records := map[string]string{}
+
+	for key, value := range e.attributes {
+		records[paxAttrPrefix+key] = value
+	}
+
+	return e.writeHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: records,
+	})
+}
+
+// readAttributes collects attributes from global headers of embedfs into
+// specified map. Contents of files are skipped without reading.
+func (fs *EmbedFs) readAttributes(attributes map[string]string) {
+	stream, err := tarStream(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return
+	}
+
+	tarReader := tar.NewReader(stream)
+
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			return
+		}
+
+		if header.Typeflag != tar.TypeXGlobalHeader {
+			continue
+		}
+
+		for key, value := range header.PAXRecords {
+			if strings.HasPrefix(key, paxAttrPrefix) {
+				attributes[strings.TrimPrefix(key, paxAttrPrefix)] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package embedfs
+
+import (
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanSetAttributesOnClose(t *testing.T) {
+	container := mockfile.New("lala64")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	embedder.SetAttr("channel", "beta")
+	embedder.SetAttr("commit", "d640b06")
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.Attr("channel") != "beta" || fs.Attr("commit") != "d640b06" {
+		t.Fatalf(
+			"unexpected attributes: %q %q", fs.Attr("channel"), fs.Attr("commit"),
+		)
+	}
+
+	if fs.Attr("missing") != "" {
+		t.Fatalf("unexpected attribute: %q", fs.Attr("missing"))
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
@@ -0,0 +1,252 @@
+package embedfs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultCacheBlockSize  = 64 * 1024
+	defaultCacheMemorySize = 16 * 1024 * 1024
+)
+
+// BlockCacheOptions holds settings which are used by NewBlockCache.
+type BlockCacheOptions struct {
+	// BlockSize is the size of blocks, which are read from source and
+	// cached. Zero value means 64 KiB.
+	BlockSize int64
+
+	// MemorySize limits total size of blocks cached in memory; least
+	// recently used blocks are evicted first. Zero value means 16 MiB.
+	MemorySize int64
+
+	// DiskDir, if not empty, specifies directory where blocks are cached
+	// on disk without size limit, so they survive restarts. Blocks are
+	// stored in subdirectory named after size of source and Identity, so
+	// blocks of another source are not read.
+	DiskDir string
+
+	// Identity identifies version of source, e.g. ETag or digest of remote
+	// origin. It should be set if source can change without changing its
+	// size, otherwise stale blocks are read from disk.
+	Identity string
+}
+
+// blockCache is io.ReaderAt which reads source by blocks and caches them.
+type blockCache struct {
+	source  io.ReaderAt
+	size    int64
+	options BlockCacheOptions
+
+	mutex  sync.Mutex
+	blocks map[int64]*list.Element
+	recent *list.List
+}
+
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// NewBlockCache returns io.ReaderAt, which reads source of specified size
+// by blocks and caches them in memory and, optionally, on disk, so repeated
+// reads of the same ranges of remote origin don't fetch them again. It's
+// safe for concurrent use.
+//
+// Result can be passed to OpenReaderAt.
+func NewBlockCache(
+	source io.ReaderAt, size int64, options BlockCacheOptions,
+) io.ReaderAt {
+	if options.BlockSize <= 0 {
+		options.BlockSize = defaultCacheBlockSize
+	}
+
+	if options.MemorySize <= 0 {
+		options.MemorySize = defaultCacheMemorySize
+	}
+
+	return &blockCache{
+		source:  source,
+		size:    size,
+		options: options,
+		blocks:  map[int64]*list.Element{},
+		recent:  list.New(),
+	}
+}
+
+func (cache *blockCache) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	read := 0
+
+	for read < len(p) {
+		position := off + int64(read)
+		if position >= cache.size {
+			return read, io.EOF
+		}
+
+		index := position / cache.options.BlockSize
+
+		data, err := cache.block(index)
+		if err != nil {
+			return read, err
+		}
+
+		read += copy(p[read:], data[position-index*cache.options.BlockSize:])
+	}
+
+	return read, nil
+}
+
+// block returns data of block with specified index, reading it from memory,
+// disk or source.
+func (cache *blockCache) block(index int64) ([]byte, error) {
+	cache.mutex.Lock()
+	element, ok := cache.blocks[index]
+	if ok {
+		cache.recent.MoveToFront(element)
+	}
+	cache.mutex.Unlock()
+
+	if ok {
+		return element.Value.(*cachedBlock).data, nil
+	}
+
+	data, err := cache.readDisk(index)
+	if err != nil {
+		data, err = cache.readSource(index)
+		if err != nil {
+			return nil, err
+		}
+
+		cache.writeDisk(index, data)
+	}
+
+	cache.remember(index, data)
+
+	return data, nil
+}
+
+// blockLen returns length of block with specified index, which is shorter
+// than BlockSize for the last block.
+func (cache *blockCache) blockLen(index int64) int64 {
+	offset := index * cache.options.BlockSize
+
+	if offset+cache.options.BlockSize > cache.size {
+		return cache.size - offset
+	}
+
+	return cache.options.BlockSize
+}
+
+func (cache *blockCache) readSource(index int64) ([]byte, error) {
+	data := make([]byte, cache.blockLen(index))
+
+	n, err := cache.source.ReadAt(data, index*cache.options.BlockSize)
+	if n == len(data) {
+		return data, nil
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return nil, err
+}
+
+// remember puts block into memory, evicting least recently used blocks if
+// memory limit is exceeded.
+func (cache *blockCache) remember(index int64, data []byte) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if _, ok := cache.blocks[index]; ok {
+		return
+	}
+
+	cache.blocks[index] = cache.recent.PushFront(
+		&cachedBlock{index: index, data: data},
+	)
+
+	limit := int(cache.options.MemorySize / cache.options.BlockSize)
+	for cache.recent.Len() > limit && cache.recent.Len() > 1 {
+		oldest := cache.recent.Remove(cache.recent.Back()).(*cachedBlock)
+		delete(cache.blocks, oldest.index)
+	}
+}
+
+// blockDir returns directory where blocks of the source are cached on
+// disk.
+func (cache *blockCache) blockDir() string {
+	hash := sha256.Sum256(
+		[]byte(strconv.FormatInt(cache.size, 10) + "\x00" +
+			cache.options.Identity),
+	)
+
+	return filepath.Join(cache.options.DiskDir, hex.EncodeToString(hash[:]))
+}
+
+func (cache *blockCache) blockPath(index int64) string {
+	return filepath.Join(
+		cache.blockDir(),
+		strconv.FormatInt(cache.options.BlockSize, 10)+"-"+
+			strconv.FormatInt(index, 10),
+	)
+}
+
+func (cache *blockCache) readDisk(index int64) ([]byte, error) {
+	if cache.options.DiskDir == "" {
+		return nil, ErrNoExist
+	}
+
+	data, err := ioutil.ReadFile(cache.blockPath(index))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) != cache.blockLen(index) {
+		return nil, ErrCorrupted
+	}
+
+	return data, nil
+}
+
+// writeDisk stores block on disk; cache is only an optimization, so errors
+// are ignored.
+func (cache *blockCache) writeDisk(index int64, data []byte) {
+	if cache.options.DiskDir == "" {
+		return
+	}
+
+	err := os.MkdirAll(cache.blockDir(), 0700)
+	if err != nil {
+		return
+	}
+
+	temp, err := ioutil.TempFile(cache.blockDir(), ".tmp-block-")
+	if err != nil {
+		return
+	}
+
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), cache.blockPath(index))
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+}
@@ -0,0 +1,118 @@
+package embedfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+// countingSource counts reads of underlying data and fails them if it's
+// offline.
+type countingSource struct {
+	*bytes.Reader
+	reads   int
+	offline bool
+}
+
+func (source *countingSource) ReadAt(p []byte, off int64) (int, error) {
+	if source.offline {
+		return 0, errors.New("source is offline")
+	}
+
+	source.reads++
+
+	return source.Reader.ReadAt(p, off)
+}
+
+func TestCanReadThroughBlockCache(t *testing.T) {
+	container := mockfile.New("lala75")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	data, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-blocks")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	source := &countingSource{Reader: bytes.NewReader(data)}
+	options := BlockCacheOptions{BlockSize: 512, DiskDir: dir}
+
+	open := func() *EmbedFs {
+		fs, err := OpenReaderAt(
+			NewBlockCache(source, int64(len(data)), options),
+			int64(len(data)),
+			OpenOptions{},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return fs
+	}
+
+	fs := open()
+
+	for i := 0; i < 2; i++ {
+		if string(fs.MustReadFile("/b/2")) != "2\n" {
+			t.Fatal("file </b/2> is not read")
+		}
+	}
+
+	reads := source.reads
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" || source.reads != reads {
+		t.Fatalf("cached blocks are read again: %d", source.reads-reads)
+	}
+
+	// blocks are read from disk by new cache
+	source.offline = true
+
+	fs = open()
+
+	contents, err := fs.ReadFile("/b/2")
+	if err != nil || string(contents) != "2\n" {
+		t.Fatalf("file </b/2> is not read from disk: %v", err)
+	}
+
+	_, err = NewBlockCache(source, 10, options).ReadAt(make([]byte, 1), 20)
+	if err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// blocks of another version of source are not read from disk
+	options.Identity = "v2"
+
+	_, err = NewBlockCache(source, int64(len(data)), options).ReadAt(
+		make([]byte, 1), 0,
+	)
+	if err == nil {
+		t.Fatal("block of another version of source is read from disk")
+	}
+}
@@ -0,0 +1,61 @@
+package embedfs
+
+import (
+	"hash/fnv"
+)
+
+const (
+	bloomBitsPerEntry = 10
+	bloomHashes       = 7
+)
+
+// bloomFilter is used for fast negative answers on existence checks, so
+// probing many non-existent paths will not hit the index.
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(entries int) *bloomFilter {
+	size := (entries*bloomBitsPerEntry + 63) / 64
+	if size == 0 {
+		size = 1
+	}
+
+	return &bloomFilter{
+		bits: make([]uint64, size),
+	}
+}
+
+func (filter *bloomFilter) add(key string) {
+	low, high := bloomHash(key)
+
+	for i := uint32(0); i < bloomHashes; i++ {
+		bit := filter.position(low, high, i)
+		filter.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (filter *bloomFilter) mayContain(key string) bool {
+	low, high := bloomHash(key)
+
+	for i := uint32(0); i < bloomHashes; i++ {
+		bit := filter.position(low, high, i)
+		if filter.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (filter *bloomFilter) position(low, high, i uint32) uint64 {
+	return uint64(low+i*high) % uint64(len(filter.bits)*64)
+}
+
+func bloomHash(key string) (uint32, uint32) {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	sum := hash.Sum64()
+
+	return uint32(sum), uint32(sum >> 32)
+}
@@ -0,0 +1,31 @@
+package embedfs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterHasNoFalseNegatives(t *testing.T) {
+	filter := newBloomFilter(1000)
+
+	for i := 0; i < 1000; i++ {
+		filter.add(fmt.Sprintf("/file/%d", i))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !filter.mayContain(fmt.Sprintf("/file/%d", i)) {
+			t.Fatalf("file </file/%d> is not found in bloom filter", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if filter.mayContain(fmt.Sprintf("/missing/%d", i)) {
+			falsePositives++
+		}
+	}
+
+	if falsePositives > 50 {
+		t.Fatalf("too many false positives: %d", falsePositives)
+	}
+}
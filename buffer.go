@@ -0,0 +1,183 @@
+package embedfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Buffer is in-memory file, which can be used as origin for both Create and
+// Open, so embedfs can be built without touching disk and written anywhere
+// afterwards, e.g. into HTTP response with customized binary.
+//
+// Zero value is an empty buffer ready to use.
+type Buffer struct {
+	mutex  sync.Mutex
+	data   []byte
+	offset int64
+	name   string
+}
+
+// NewBuffer returns buffer, which initially contains specified data, e.g.
+// contents of binary which should have embedfs appended. Buffer takes
+// ownership of data.
+func NewBuffer(name string, data []byte) *Buffer {
+	return &Buffer{data: data, name: name}
+}
+
+// Bytes returns whole contents of buffer. Returned slice is valid only until
+// next write to buffer.
+func (buffer *Buffer) Bytes() []byte {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return buffer.data
+}
+
+// Len returns size of buffer contents.
+func (buffer *Buffer) Len() int {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return len(buffer.data)
+}
+
+// WriteTo writes whole contents of buffer to w regardless of current offset.
+func (buffer *Buffer) WriteTo(w io.Writer) (int64, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	written, err := w.Write(buffer.data)
+
+	return int64(written), err
+}
+
+// Read reads data starting from current offset.
+func (buffer *Buffer) Read(b []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	if buffer.offset >= int64(len(buffer.data)) {
+		return 0, io.EOF
+	}
+
+	read := copy(b, buffer.data[buffer.offset:])
+	buffer.offset += int64(read)
+
+	return read, nil
+}
+
+// ReadAt reads data starting from specified offset.
+func (buffer *Buffer) ReadAt(b []byte, offset int64) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if offset >= int64(len(buffer.data)) {
+		return 0, io.EOF
+	}
+
+	read := copy(b, buffer.data[offset:])
+	if read < len(b) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// Write writes data at current offset, growing buffer if needed.
+func (buffer *Buffer) Write(b []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	end := buffer.offset + int64(len(b))
+	if end > int64(len(buffer.data)) {
+		buffer.grow(end)
+	}
+
+	copy(buffer.data[buffer.offset:], b)
+	buffer.offset = end
+
+	return len(b), nil
+}
+
+// Seek sets offset for next Read or Write.
+func (buffer *Buffer) Seek(offset int64, whence int) (int64, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += buffer.offset
+	case os.SEEK_END:
+		offset += int64(len(buffer.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	buffer.offset = offset
+
+	return offset, nil
+}
+
+// Truncate changes size of buffer, filling it with zeroes if it grows.
+// Offset is not changed.
+func (buffer *Buffer) Truncate(size int64) error {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	if size < 0 {
+		return errors.New("negative size")
+	}
+
+	if size > int64(len(buffer.data)) {
+		buffer.grow(size)
+	} else {
+		buffer.data = buffer.data[:size]
+	}
+
+	return nil
+}
+
+// Stat returns file info with size of buffer contents.
+func (buffer *Buffer) Stat() (os.FileInfo, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	return memoryFileInfo{
+		name: buffer.name,
+		size: int64(len(buffer.data)),
+	}, nil
+}
+
+// Close is no-op for buffer, contents are still available after close.
+func (buffer *Buffer) Close() error {
+	return nil
+}
+
+func (buffer *Buffer) grow(size int64) {
+	if size <= int64(cap(buffer.data)) {
+		tail := buffer.data[len(buffer.data):size]
+		for i := range tail {
+			tail[i] = 0
+		}
+
+		buffer.data = buffer.data[:size]
+
+		return
+	}
+
+	data := make([]byte, size, 2*size)
+	copy(data, buffer.data)
+
+	buffer.data = data
+}
@@ -0,0 +1,60 @@
+package embedfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCanBuildEmbedfsInBuffer(t *testing.T) {
+	container := NewBuffer("binary", []byte("binary"))
+
+	_, err := container.Seek(0, 2)
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from buffer")
+	}
+
+	output := &bytes.Buffer{}
+
+	_, err = container.WriteTo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CopyWithout(bytes.NewReader(output.Bytes()), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Truncate(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(container.Bytes()) != "binary" {
+		t.Fatalf("unexpected truncated buffer: %q", container.Bytes())
+	}
+}
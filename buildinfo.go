@@ -0,0 +1,78 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"runtime/debug"
+	"time"
+)
+
+const buildInfoPath = internalDir + "/build-info.json"
+
+// BuildInfo describes program which has built embedfs payload.
+type BuildInfo struct {
+	// Module is the path of main module of the program.
+	Module string `json:"module,omitempty"`
+
+	// Version is the version of main module.
+	Version string `json:"version,omitempty"`
+
+	// Revision is the VCS revision the program was built from.
+	Revision string `json:"revision,omitempty"`
+
+	// Modified is true if working tree had uncommitted changes.
+	Modified bool `json:"modified,omitempty"`
+
+	// EmbeddedAt is the time when build info was embedded.
+	EmbeddedAt time.Time `json:"embedded_at"`
+}
+
+// EmbedBuildInfo embeds build info of the running program, obtained from
+// debug.ReadBuildInfo, along with current time, so it can be obtained later
+// by BuildInfo method of EmbedFs.
+func (e *Embedder) EmbedBuildInfo() error {
+	info := BuildInfo{EmbeddedAt: time.Now().UTC()}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.Module = build.Main.Path
+		info.Version = build.Main.Version
+
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	tarHeader := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  info.EmbeddedAt,
+	}
+
+	return e.embedContent(
+		tarHeader, buildInfoPath, buildInfoPath, bytes.NewReader(data),
+	)
+}
+
+// BuildInfo returns build info embedded by EmbedBuildInfo.
+func (fs *EmbedFs) BuildInfo() (*BuildInfo, error) {
+	info := &BuildInfo{}
+
+	err := DecodeJSON(fs, buildInfoPath, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,41 @@
+package embedfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanEmbedBuildInfo(t *testing.T) {
+	container := mockfile.New("lala65")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedBuildInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	info, err := fs.BuildInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(info.EmbeddedAt) > time.Minute {
+		t.Fatalf("unexpected embedding time: %s", info.EmbeddedAt)
+	}
+}
@@ -0,0 +1,151 @@
+package embedfs
+
+import (
+	"errors"
+	"sort"
+)
+
+// CheckReport describes state of possibly corrupted embedfs.
+type CheckReport struct {
+	// Intact lists files which checksums match stored ones.
+	Intact []string
+
+	// Unverified lists files which can be read, but have no stored
+	// checksums, so they can't be verified.
+	Unverified []string
+
+	// Damaged lists files which can't be read or which checksums don't
+	// match stored ones.
+	Damaged []DamagedEntry
+
+	// DamagedRanges lists absolute ranges of origin, which can't be parsed
+	// or hold contents of damaged files, sorted by offset. Files stored in
+	// unparseable ranges are lost and are not listed anywhere else.
+	DamagedRanges []ByteRange
+}
+
+// DamagedEntry describes damaged file found by Check.
+type DamagedEntry struct {
+	Name  string
+	Range ByteRange
+	Err   error
+}
+
+// ByteRange describes range of bytes in origin.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+// Check opens embedfs in lenient mode and verifies every found file, so
+// impact of corruption can be assessed. Error is returned only if embedfs
+// can't be opened at all. Encrypted files are reported as unverified,
+// because their checksums are sealed; use CheckWithOptions to pass
+// decryption key.
+func Check(origin file) (*CheckReport, error) {
+	return CheckWithOptions(origin, OpenOptions{})
+}
+
+// CheckWithOptions works like Check, but opens embedfs with specified
+// options. Mode is always lenient.
+func CheckWithOptions(origin file, options OpenOptions) (*CheckReport, error) {
+	options.Mode = ModeLenient
+
+	fs, err := OpenWithOptions(origin, options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CheckReport{
+		DamagedRanges: append([]ByteRange{}, fs.damaged...),
+	}
+
+	for _, entry := range fs.files {
+		err := fs.verifyEntry(entry)
+		switch {
+		case err == nil:
+			report.Intact = append(report.Intact, entry.name)
+
+		case errors.Is(err, ErrNoChecksum):
+			report.Unverified = append(report.Unverified, entry.name)
+
+		default:
+			stored, inOrigin := entry.storedRange()
+
+			damaged := DamagedEntry{
+				Name:  entry.name,
+				Range: stored,
+				Err:   err,
+			}
+
+			report.Damaged = append(report.Damaged, damaged)
+
+			if inOrigin {
+				report.DamagedRanges = append(
+					report.DamagedRanges, damaged.Range,
+				)
+			}
+		}
+	}
+
+	sort.Slice(report.DamagedRanges, func(i, j int) bool {
+		return report.DamagedRanges[i].Offset <
+			report.DamagedRanges[j].Offset
+	})
+
+	return report, nil
+}
+
+// Salvageable returns names of files which can be extracted from damaged
+// embedfs, that is intact and unverified files.
+func (report *CheckReport) Salvageable() []string {
+	names := append([]string{}, report.Intact...)
+
+	return append(names, report.Unverified...)
+}
+
+// storedRange returns range of bytes, which holds data of entry as it's
+// stored, that is encrypted or compressed. Files packed into encoded solid
+// block share range of the whole block. Returns false if data is not
+// stored in origin.
+func (entry *embedFsEntry) storedRange() (ByteRange, bool) {
+	var stored ByteRange
+
+	switch {
+	case entry.external != nil:
+		return ByteRange{}, false
+
+	case entry.encrypted != nil:
+		stored = ByteRange{
+			Offset: entry.encrypted.offset,
+			Length: entry.encrypted.sealedSize(),
+		}
+
+	case entry.compressed != nil:
+		stored = ByteRange{
+			Offset: entry.compressed.offset,
+			Length: entry.compressed.size,
+		}
+
+	case entry.gzipped != nil:
+		stored = ByteRange{
+			Offset: entry.gzipped.offset,
+			Length: entry.gzipped.size,
+		}
+
+	case entry.squashed != nil:
+		stored = entry.squashed.storedRange()
+
+	default:
+		stored = ByteRange{Offset: entry.offset, Length: entry.size}
+	}
+
+	return stored, entry.volume == nil
+}
+
+func (fs *EmbedFs) addDamaged(from, to int64) {
+	fs.damaged = append(fs.damaged, ByteRange{
+		Offset: from,
+		Length: to - from,
+	})
+}
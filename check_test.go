@@ -0,0 +1,164 @@
+package embedfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanCheckDamagedEmbedFs(t *testing.T) {
+	container := mockfile.New("lala49")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/c")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	lost, err := fs.lookup("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	damaged, err := fs.lookup("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	// break header of the first entry and data of the second one
+	for _, offset := range []int64{lost.offset - blockSize, damaged.offset} {
+		_, err = container.Seek(offset, os.SEEK_SET)
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = container.Write([]byte{'!'})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	report, err := Check(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.Salvageable(), []string{"/c"}) {
+		t.Fatalf("unexpected salvageable files: %v", report.Salvageable())
+	}
+
+	if len(report.Damaged) != 1 || report.Damaged[0].Name != "/b/2" ||
+		!errors.Is(report.Damaged[0].Err, ErrChecksumMismatch) {
+		t.Fatalf("unexpected damaged files: %+v", report.Damaged)
+	}
+
+	expected := []ByteRange{
+		{
+			Offset: lost.headerOffset,
+			Length: damaged.headerOffset - lost.headerOffset,
+		},
+		{Offset: damaged.offset, Length: damaged.size},
+	}
+
+	if !reflect.DeepEqual(report.DamagedRanges, expected) {
+		t.Fatalf("unexpected damaged ranges: %+v", report.DamagedRanges)
+	}
+}
+
+func TestCanCheckEncryptedFiles(t *testing.T) {
+	key := bytes.Repeat([]byte{42}, 32)
+
+	container := mockfile.New("lala102")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Encrypt:       []string{"/b/**"},
+		EncryptionKey: key,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	encrypted, err := fs.lookup("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	stored := ByteRange{
+		Offset: encrypted.encrypted.offset,
+		Length: 2 + encryptionOverhead,
+	}
+
+	_, err = container.Seek(stored.Offset, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!'})
+	if err != nil {
+		panic(err)
+	}
+
+	// checksum is sealed, so it can't be verified without key
+	report, err := Check(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Damaged) != 0 ||
+		!reflect.DeepEqual(report.Unverified, []string{"/b/2"}) {
+		t.Fatalf("unexpected report without key: %+v", report)
+	}
+
+	report, err = CheckWithOptions(container, OpenOptions{DecryptionKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Damaged) != 1 || report.Damaged[0].Name != "/b/2" ||
+		report.Damaged[0].Range != stored ||
+		!errors.Is(report.Damaged[0].Err, ErrCorrupted) {
+		t.Fatalf("unexpected damaged files: %+v", report.Damaged)
+	}
+
+	if !reflect.DeepEqual(report.DamagedRanges, []ByteRange{stored}) {
+		t.Fatalf("unexpected damaged ranges: %+v", report.DamagedRanges)
+	}
+}
@@ -0,0 +1,155 @@
+package embedfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	iofs "io/fs"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"plugin"
+)
+
+// Command extracts executable file from embedded fs and returns command,
+// which runs it with specified arguments.
+//
+// Executable is extracted to ExtractCacheDir under directory named by its
+// checksum, so it's extracted only once and different versions don't clash.
+// Checksum of previously extracted executable is validated before it's
+// run, so file planted or damaged in cache is extracted again. Execute
+// permissions are always set, and setuid and setgid bits are cleared.
+func (fs *EmbedFs) Command(name string, args ...string) (*exec.Cmd, error) {
+	executable, err := fs.extractCached(name, ExtractOptions{
+		MapAttributes: func(_ string, attributes Attributes) Attributes {
+			attributes.Mode |= 0111
+			return attributes
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return exec.Command(executable, args...), nil
+}
+
+// Plugin extracts Go plugin from embedded fs the same way as Command does
+// and loads it. Checksum of previously extracted plugin is validated before
+// it's loaded.
+func (fs *EmbedFs) Plugin(name string) (*plugin.Plugin, error) {
+	library, err := fs.extractCached(name, ExtractOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin.Open(library)
+}
+
+// Library extracts native shared library from embedded fs the same way as
+// Command does and returns its path, so it can be loaded by dlopen or
+// passed to dynamic linker.
+//
+// Checksum of previously extracted library is validated, so damaged file is
+// extracted again, and extraction is guarded by file lock, so concurrent
+// processes don't race.
+func (fs *EmbedFs) Library(name string) (string, error) {
+	return fs.extractCached(name, ExtractOptions{})
+}
+
+// extractCached extracts file to ExtractCacheDir under directory named by
+// its checksum, unless it's already extracted, and returns its path.
+// Checksum of extracted file is checked under lock after extraction, and
+// cache directory should be private, so file can't be replaced until it's
+// used.
+func (fs *EmbedFs) extractCached(
+	name string, options ExtractOptions,
+) (string, error) {
+	clean, err := cleanPath(name)
+	if err != nil {
+		return "", &iofs.PathError{Op: "extract", Path: name, Err: err}
+	}
+
+	entry, err := fs.lookup(clean)
+	if err != nil {
+		return "", &iofs.PathError{Op: "extract", Path: name, Err: err}
+	}
+
+	header, err := fs.header(entry)
+	if err != nil {
+		return "", err
+	}
+
+	hash, ok := header.PAXRecords[paxChecksum]
+	if !ok {
+		hash, err = fs.checksum(entry)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// checksum is a part of path, so it should not contain anything else
+	if !isChecksum(hash) {
+		return "", fmt.Errorf(
+			`%w: invalid checksum of file <%s>`, ErrCorrupted, clean,
+		)
+	}
+
+	// path of extracted file is returned, so it should not be replaceable
+	// by others after it's verified
+	root := cacheDir(fs.options.ExtractCacheDir)
+
+	err = privateDir(root)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(root, hash)
+	target := filepath.Join(dir, path.Base(clean))
+
+	err = privateDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, err := lockFile(dir + ".lock")
+	if err != nil {
+		return "", err
+	}
+
+	defer unlock()
+
+	actual, err := fileChecksum(target)
+	if err == nil && actual == hash {
+		return target, nil
+	}
+
+	options.Atomic = true
+	options.ClearSetuid = true
+
+	err = fs.ExtractFile(clean, target, options)
+	if err != nil {
+		return "", err
+	}
+
+	actual, err = fileChecksum(target)
+	if err != nil {
+		return "", err
+	}
+
+	if actual != hash {
+		return "", fmt.Errorf(
+			`%w: extracted file <%s> has checksum %s, expected %s`,
+			ErrChecksumMismatch, target, actual, hash,
+		)
+	}
+
+	return target, nil
+}
+
+// isChecksum returns true if specified string is hex-encoded SHA-256
+// checksum.
+func isChecksum(hash string) bool {
+	_, err := hex.DecodeString(hash)
+
+	return err == nil && len(hash) == sha256.Size*2
+}
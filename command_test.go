@@ -0,0 +1,174 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanRunEmbeddedCommand(t *testing.T) {
+	script, err := ioutil.TempFile("", "embedfs-script")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(script.Name())
+
+	_, err = script.WriteString("#!/bin/sh\necho \"hello $1\"\n")
+	if err != nil {
+		panic(err)
+	}
+
+	script.Close()
+
+	container := mockfile.New("lala59")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(script.Name(), "/bin/hello")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-command")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fs, err := OpenWithOptions(container, OpenOptions{ExtractCacheDir: dir})
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		command, err := fs.Command("/bin/hello", "world")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// file of the same size planted in cache should not be run
+		if i == 1 {
+			err = ioutil.WriteFile(
+				command.Path, []byte("#!/bin/sh\necho \"pwned $1\"\n"), 0755,
+			)
+			if err != nil {
+				panic(err)
+			}
+
+			continue
+		}
+
+		output, err := command.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(output) != "hello world\n" {
+			t.Fatalf("unexpected output: %q", output)
+		}
+	}
+
+	// executable in directory writable by others can be replaced before
+	// it's run
+	err = os.Chmod(dir, 0777)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.Command("/bin/hello", "world")
+	if !errors.Is(err, ErrUnsafeDir) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanNotLoadMissingPlugin(t *testing.T) {
+	container := mockfile.New("lala60")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.Plugin("/plugins/missing.so")
+	if !errors.Is(err, ErrNoExist) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanExtractLibraryAgainIfDamaged(t *testing.T) {
+	container := mockfile.New("lala61")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/lib/libone.so")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-library")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	fs, err := OpenWithOptions(container, OpenOptions{ExtractCacheDir: dir})
+	if err != nil {
+		panic(err)
+	}
+
+	library, err := fs.Library("/lib/libone.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(library, []byte("2\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	library, err = fs.Library("/lib/libone.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "1\n" {
+		t.Fatalf("damaged library is not extracted again: %q", contents)
+	}
+}
@@ -0,0 +1,369 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/seletskiy/go-embed-fs/internal/zstd"
+)
+
+const (
+	paxCompression      = paxPrefix + "compression"
+	paxUncompressedSize = paxPrefix + "uncompressed-size"
+	paxDictionary       = paxPrefix + "dictionary"
+)
+
+const (
+	compressionZstd = "zstd"
+
+	// dictionaryPath is the internal file, which holds dictionary used for
+	// compression of files.
+	dictionaryPath = internalDir + "/dictionary"
+
+	// MaxDictionarySize is the maximum size of dictionary built by
+	// TrainDictionary. Every compressed file refers to the whole
+	// dictionary, so larger ones only slow down compression.
+	MaxDictionarySize = 112 * 1024
+
+	// dictionaryGram is the length of substrings, which are counted while
+	// training dictionary.
+	dictionaryGram = 8
+)
+
+// compressedData is the source of decompressed data of compressed entry.
+// Compressed files are expected to be small, so they are decompressed into
+// memory on first read and kept there, as zstd frame can't be read at
+// arbitrary offsets.
+type compressedData struct {
+	source     io.ReaderAt
+	offset     int64
+	size       int64
+	dictionary string
+	fs         *EmbedFs
+
+	// plainSize is the declared size of decompressed data, so data which
+	// decompresses to anything else is rejected without exhausting memory.
+	plainSize int64
+
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// compresses returns true if file embedded under specified name matches
+// patterns specified in EmbedOptions.Compress.
+func (e *Embedder) compresses(name string) bool {
+	for _, pattern := range e.options.Compress {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compress reads whole content and returns it compressed by zstd with
+// dictionary, if it's specified, storing parameters of compression in PAX
+// records of the header. Content is returned as is, if compression doesn't
+// make it smaller.
+func (e *Embedder) compress(
+	tarHeader *tar.Header, content io.ReadSeeker,
+) (io.ReadSeeker, error) {
+	plain, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(plain))
+
+	compressed := zstd.Compress(plain, e.zstdDictionary)
+	if int64(len(compressed)) >= size {
+		return bytes.NewReader(plain), nil
+	}
+
+	if tarHeader.PAXRecords == nil {
+		tarHeader.PAXRecords = map[string]string{}
+	}
+
+	tarHeader.PAXRecords[paxCompression] = compressionZstd
+	tarHeader.PAXRecords[paxUncompressedSize] = strconv.FormatInt(size, 10)
+
+	dictionary := e.options.CompressionDictionary
+	if len(dictionary) > 0 {
+		hash := sha256.Sum256(dictionary)
+
+		tarHeader.PAXRecords[paxDictionary] = hex.EncodeToString(hash[:])
+
+		e.dictionary = dictionary
+	}
+
+	tarHeader.Size = int64(len(compressed))
+
+	return bytes.NewReader(compressed), nil
+}
+
+// writeDictionary stores dictionary in embedfs, if it has been used for
+// compression of any file.
+func (e *Embedder) writeDictionary() error {
+	if e.dictionary == nil {
+		return nil
+	}
+
+	return e.embedData(dictionaryPath, e.dictionary)
+}
+
+// attachCompression sets up decompression of the entry, if it's
+// compressed. Size in the header is changed to the size of decompressed
+// data and offset of entry points to its beginning.
+func (fs *EmbedFs) attachCompression(
+	entry *embedFsEntry, header *tar.Header,
+) error {
+	method, ok := header.PAXRecords[paxCompression]
+	if !ok {
+		return nil
+	}
+
+	if method != compressionZstd {
+		return fmt.Errorf(
+			`%w: unsupported compression <%s>`, ErrNotImplemented, method,
+		)
+	}
+
+	size, err := strconv.ParseInt(
+		header.PAXRecords[paxUncompressedSize], 10, 64,
+	)
+	if err != nil || size < 0 {
+		return fmt.Errorf(`%w: invalid size of compressed file`, ErrCorrupted)
+	}
+
+	// whole file is decompressed into memory, so it can't be larger than
+	// all entries are allowed to be
+	maxSize := fs.options.Limits.MaxTotalSize
+	if maxSize > 0 && size > maxSize {
+		return fmt.Errorf(
+			`%w: decompressed size is more than %d bytes`,
+			ErrLimitExceeded, maxSize,
+		)
+	}
+
+	entry.compressed = &compressedData{
+		source:     fs.originOf(entry),
+		offset:     entry.offset,
+		size:       entry.size,
+		dictionary: header.PAXRecords[paxDictionary],
+		fs:         fs,
+		plainSize:  size,
+	}
+
+	header.Size = size
+	entry.offset = 0
+	entry.size = size
+
+	return nil
+}
+
+// ReadAt reads decompressed data starting from specified offset.
+func (data *compressedData) ReadAt(p []byte, off int64) (int, error) {
+	data.once.Do(data.decompress)
+	if data.err != nil {
+		return 0, data.err
+	}
+
+	if off >= int64(len(data.data)) {
+		return 0, io.EOF
+	}
+
+	read := copy(p, data.data[off:])
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (data *compressedData) decompress() {
+	var dictionary *zstd.Dictionary
+
+	if data.dictionary != "" {
+		dictionary, data.err = data.fs.compressionDictionary(data.dictionary)
+		if data.err != nil {
+			return
+		}
+	}
+
+	compressed := make([]byte, data.size)
+
+	read, err := data.source.ReadAt(compressed, data.offset)
+	if read < len(compressed) {
+		data.err = err
+		return
+	}
+
+	data.data, data.err = zstd.Decompress(
+		compressed, dictionary, int(data.plainSize),
+	)
+
+	switch {
+	case errors.Is(data.err, zstd.ErrTooLarge):
+		data.err = fmt.Errorf(
+			`%w: decompressed size differs from declared %d bytes`,
+			ErrCorrupted, data.plainSize,
+		)
+	case data.err != nil:
+		data.err = fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted,
+			data.err)
+	case int64(len(data.data)) != data.plainSize:
+		data.data = nil
+		data.err = fmt.Errorf(
+			`%w: decompressed size differs from declared %d bytes`,
+			ErrCorrupted, data.plainSize,
+		)
+	}
+}
+
+// compressionDictionary returns dictionary stored in embedfs, checking that
+// it has specified checksum.
+func (fs *EmbedFs) compressionDictionary(
+	checksum string,
+) (*zstd.Dictionary, error) {
+	fs.dictionaryOnce.Do(func() {
+		fs.dictionaryData, fs.dictionaryErr = fs.ReadFile(dictionaryPath)
+		if fs.dictionaryErr != nil {
+			return
+		}
+
+		fs.dictionary, fs.dictionaryErr = zstd.NewDictionary(fs.dictionaryData)
+		if fs.dictionaryErr != nil {
+			fs.dictionaryErr = fmt.Errorf(
+				`%w: invalid compression dictionary: %s`, ErrCorrupted,
+				fs.dictionaryErr,
+			)
+		}
+	})
+
+	if fs.dictionaryErr != nil {
+		return nil, fs.dictionaryErr
+	}
+
+	hash := sha256.Sum256(fs.dictionaryData)
+	if hex.EncodeToString(hash[:]) != checksum {
+		return nil, fmt.Errorf(
+			`%w: compression dictionary checksum mismatch`, ErrCorrupted,
+		)
+	}
+
+	return fs.dictionary, nil
+}
+
+// TrainDictionary builds compression dictionary of at most specified size
+// out of contents of sample files, so it can be passed as
+// CompressionDictionary option. Samples should be representative for files
+// which will be compressed, like several JSON locales or SQL migrations.
+//
+// Dictionary is raw zstd dictionary made of substrings, which are found in
+// more than one sample, most frequent ones placed at the end, where they
+// are referred by shorter offsets.
+func TrainDictionary(samples []string, size int) ([]byte, error) {
+	if size > MaxDictionarySize {
+		size = MaxDictionarySize
+	}
+
+	contents := make([][]byte, len(samples))
+	for i, sample := range samples {
+		data, err := ioutil.ReadFile(sample)
+		if err != nil {
+			return nil, err
+		}
+
+		contents[i] = data
+	}
+
+	// number of samples, which contain every substring
+	frequency := map[string]int{}
+	for _, data := range contents {
+		seen := map[string]bool{}
+		for i := 0; i+dictionaryGram <= len(data); i++ {
+			gram := string(data[i : i+dictionaryGram])
+			if !seen[gram] {
+				seen[gram] = true
+				frequency[gram]++
+			}
+		}
+	}
+
+	// common segments are the longest runs of substrings found in more
+	// than one sample
+	segments := map[string]int{}
+	for _, data := range contents {
+		start := -1
+		score := 0
+
+		for i := 0; i+dictionaryGram <= len(data)+1; i++ {
+			count := 0
+			if i+dictionaryGram <= len(data) {
+				count = frequency[string(data[i:i+dictionaryGram])]
+			}
+
+			if count > 1 {
+				if start < 0 {
+					start = i
+					score = 0
+				}
+
+				score += count
+
+				continue
+			}
+
+			if start >= 0 {
+				segment := string(data[start : i-1+dictionaryGram])
+				if score > segments[segment] {
+					segments[segment] = score
+				}
+
+				start = -1
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(segments))
+	for segment := range segments {
+		ordered = append(ordered, segment)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		if segments[ordered[i]] != segments[ordered[j]] {
+			return segments[ordered[i]] > segments[ordered[j]]
+		}
+
+		return ordered[i] < ordered[j]
+	})
+
+	selected := []string{}
+	total := 0
+
+	for _, segment := range ordered {
+		if total+len(segment) > size {
+			continue
+		}
+
+		selected = append(selected, segment)
+		total += len(segment)
+	}
+
+	dictionary := make([]byte, 0, total)
+	for i := len(selected) - 1; i >= 0; i-- {
+		dictionary = append(dictionary, selected[i]...)
+	}
+
+	return dictionary, nil
+}
@@ -0,0 +1,282 @@
+package embedfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanCompressFilesWithDictionary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-compress")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	samples := []string{}
+	for i := 0; i < 20; i++ {
+		sample := filepath.Join(dir, fmt.Sprintf("%02d.json", i))
+
+		err = ioutil.WriteFile(sample, []byte(fmt.Sprintf(
+			`{"greeting": "hello, user number %d", `+
+				`"farewell": "goodbye, see you later", `+
+				`"error": "something went wrong, please try again"}`,
+			i,
+		)), 0644)
+		if err != nil {
+			panic(err)
+		}
+
+		samples = append(samples, sample)
+	}
+
+	dictionary, err := TrainDictionary(samples, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dictionary) == 0 || len(dictionary) > 1024 {
+		t.Fatalf("unexpected size of dictionary: %d", len(dictionary))
+	}
+
+	embed := func(options EmbedOptions) *EmbedFs {
+		container := mockfile.New("lala81")
+
+		embedder, err := CreateWithOptions(container, options)
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.EmbedDirectory(dir, "/locales")
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		fs, err := OpenWithOptions(container, OpenOptions{
+			DecryptionKey: bytes.Repeat([]byte{42}, 32),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return fs
+	}
+
+	compressedSize := func(fs *EmbedFs) int64 {
+		err := fs.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := int64(0)
+		for _, entry := range fs.files {
+			if entry.name == dictionaryPath {
+				continue
+			}
+
+			if entry.compressed == nil {
+				t.Fatalf("file <%s> is not compressed", entry.name)
+			}
+
+			size += entry.compressed.size
+		}
+
+		return size
+	}
+
+	plain := embed(EmbedOptions{Compress: []string{"/locales/*"}})
+
+	fs := embed(EmbedOptions{
+		Compress:              []string{"/locales/*"},
+		CompressionDictionary: dictionary,
+		Encrypt:               []string{"/locales/00.json"},
+		EncryptionKey:         bytes.Repeat([]byte{42}, 32),
+	})
+
+	if compressedSize(fs) >= compressedSize(plain)/2 {
+		t.Fatalf(
+			"dictionary doesn't help: %d vs %d bytes",
+			compressedSize(fs), compressedSize(plain),
+		)
+	}
+
+	for i, sample := range samples {
+		expected, err := ioutil.ReadFile(sample)
+		if err != nil {
+			panic(err)
+		}
+
+		name := fmt.Sprintf("/locales/%02d.json", i)
+
+		if !bytes.Equal(fs.MustReadFile(name), expected) {
+			t.Fatalf("unexpected contents of <%s>", name)
+		}
+
+		stat, err := fs.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stat.Size() != int64(len(expected)) {
+			t.Fatalf("unexpected size of <%s>: %d", name, stat.Size())
+		}
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanNotDecompressMoreThanDeclared(t *testing.T) {
+	big, err := ioutil.TempFile("", "embedfs-compress")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(big.Name())
+
+	_, err = big.Write(bytes.Repeat([]byte("x"), 100000))
+	if err != nil {
+		panic(err)
+	}
+
+	big.Close()
+
+	container := mockfile.New("lala98")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Compress: []string{"/**"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(big.Name(), "/big")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	// compressed file is small, but it's decompressed into memory
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxTotalSize: 50000},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	container.Seek(0, 0)
+
+	raw, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// declared size is patched in place, keeping length of PAX record
+	declared := []byte(paxUncompressedSize + "=100000")
+	if !bytes.Contains(raw, declared) {
+		t.Fatal("declared size of compressed file is not found")
+	}
+
+	raw = bytes.Replace(
+		raw, declared, []byte(paxUncompressedSize+"=000010"), 1,
+	)
+
+	container.Seek(0, 0)
+
+	_, err = container.Write(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fs.ReadFile("/big")
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanCompressFilesWithDictionaryTrainedByZstd(t *testing.T) {
+	// dictionary and sample are written by zstd --train and zstd itself
+	dictionary, err := ioutil.ReadFile("internal/zstd/_test/sample.dict")
+	if err != nil {
+		panic(err)
+	}
+
+	sample := "internal/zstd/_test/sample.json"
+
+	expected, err := ioutil.ReadFile(sample)
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala104")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Compress:              []string{"/**"},
+		CompressionDictionary: dictionary,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(sample, "/sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry *embedFsEntry
+	for _, file := range fs.files {
+		if file.name == "/sample.json" {
+			entry = file
+		}
+	}
+
+	if entry == nil || entry.compressed == nil {
+		t.Fatal("file is not compressed")
+	}
+
+	if entry.compressed.size >= int64(len(expected))/2 {
+		t.Fatalf("dictionary doesn't help: %d bytes", entry.compressed.size)
+	}
+
+	if !bytes.Equal(fs.MustReadFile("/sample.json"), expected) {
+		t.Fatal("unexpected contents of </sample.json>")
+	}
+}
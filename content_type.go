@@ -0,0 +1,38 @@
+package embedfs
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+// sniffLen is the amount of data used by http.DetectContentType.
+const sniffLen = 512
+
+// detectContentType returns content type by file extension or, if
+// extension is unknown, by contents of the file.
+func detectContentType(name string, head []byte) string {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType != "" {
+		return contentType
+	}
+
+	return http.DetectContentType(head)
+}
+
+// ContentType returns content type of the file, which was detected when
+// file was embedded. For files embedded without content type it's
+// guessed by file extension.
+func (entry Entry) ContentType() (string, error) {
+	header, err := entry.fs.header(entry.entry)
+	if err != nil {
+		return "", err
+	}
+
+	contentType, ok := header.PAXRecords[paxContentType]
+	if ok {
+		return contentType, nil
+	}
+
+	return mime.TypeByExtension(filepath.Ext(entry.entry.name)), nil
+}
@@ -0,0 +1,110 @@
+package embedfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	iofs "io/fs"
+	"os"
+)
+
+// CopyPolicy specifies what CopyFile does when file already exists on disk.
+type CopyPolicy int
+
+const (
+	// CopyOverwrite always replaces file on disk.
+	CopyOverwrite CopyPolicy = iota
+
+	// CopyIfMissing copies file only if there is no file on disk.
+	CopyIfMissing
+
+	// CopyIfChanged copies file only if contents of file on disk differ
+	// from embedded one.
+	CopyIfChanged
+)
+
+// CopyFile writes file from embedded fs to the specified path on disk
+// according to policy, restoring its mode and modification time. File is
+// written atomically, so it's either old or new one on disk.
+//
+// Returns true if file has been written.
+func CopyFile(
+	fs *EmbedFs, embeddedPath, diskPath string, policy CopyPolicy,
+) (bool, error) {
+	if policy != CopyOverwrite {
+		skip, err := fs.isCopied(embeddedPath, diskPath, policy)
+		if err != nil || skip {
+			return false, err
+		}
+	}
+
+	err := fs.ExtractFile(embeddedPath, diskPath, ExtractOptions{Atomic: true})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// isCopied returns true if file on disk satisfies policy and should not be
+// written again.
+func (fs *EmbedFs) isCopied(
+	embeddedPath, diskPath string, policy CopyPolicy,
+) (bool, error) {
+	_, err := os.Stat(diskPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil || policy == CopyIfMissing {
+		return true, err
+	}
+
+	name, err := cleanPath(embeddedPath)
+	if err != nil {
+		return false, &iofs.PathError{Op: "copy", Path: embeddedPath, Err: err}
+	}
+
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return false, &iofs.PathError{Op: "copy", Path: embeddedPath, Err: err}
+	}
+
+	header, err := fs.header(entry)
+	if err != nil {
+		return false, err
+	}
+
+	expected, ok := header.PAXRecords[paxChecksum]
+	if !ok {
+		expected, err = fs.checksum(entry)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	actual, err := fileChecksum(diskPath)
+	if err != nil {
+		return false, err
+	}
+
+	return actual == expected, nil
+}
+
+// fileChecksum returns SHA-256 checksum of the file on disk.
+func fileChecksum(path string) (string, error) {
+	diskFile, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer diskFile.Close()
+
+	hash := sha256.New()
+
+	_, err = copyBuffered(hash, diskFile)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,85 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanCopyFileAccordingToPolicy(t *testing.T) {
+	container := mockfile.New("lala57")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/config")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-copy")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "config")
+
+	tests := []struct {
+		policy   CopyPolicy
+		existing string
+		copied   bool
+		contents string
+	}{
+		{CopyIfMissing, "", true, "1\n"},
+		{CopyIfMissing, "changed", false, "changed"},
+		{CopyIfChanged, "1\n", false, "1\n"},
+		{CopyIfChanged, "changed", true, "1\n"},
+		{CopyOverwrite, "1\n", true, "1\n"},
+	}
+
+	for _, test := range tests {
+		os.Remove(target)
+
+		if test.existing != "" {
+			err = ioutil.WriteFile(target, []byte(test.existing), 0644)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		copied, err := CopyFile(fs, "/config", target, test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if copied != test.copied {
+			t.Fatalf("policy %d: unexpected copied flag: %v", test.policy, copied)
+		}
+
+		contents, err := ioutil.ReadFile(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(contents) != test.contents {
+			t.Fatalf("policy %d: unexpected contents: %q", test.policy, contents)
+		}
+	}
+}
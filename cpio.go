@@ -0,0 +1,186 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	cpioMagic   = "070701"
+	cpioTrailer = "TRAILER!!!"
+)
+
+// Mode bits of file types in cpio archive.
+const (
+	cpioTypeRegular   = 0100000
+	cpioTypeDirectory = 0040000
+	cpioTypeSymlink   = 0120000
+	cpioTypeMask      = 0170000
+)
+
+// WriteCpio writes all files from embedded fs as cpio archive in newc
+// format, which is used for Linux initramfs images. Archive has the same
+// contents as one written by WriteTar, except that hard links are written
+// as copies of files they point to.
+func (fs *EmbedFs) WriteCpio(w io.Writer) error {
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(fs.WriteTar(writer))
+	}()
+
+	defer reader.Close()
+
+	tarReader := tar.NewReader(reader)
+	cpioWriter := &cpioWriter{fs: fs, writer: w}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = cpioWriter.writeEntry(header, tarReader)
+		if err != nil {
+			return err
+		}
+	}
+
+	cpioWriter.inode = 0
+
+	return cpioWriter.writeHeader(cpioTrailer, 0, 0, 0, 0, 0)
+}
+
+type cpioWriter struct {
+	fs      *EmbedFs
+	writer  io.Writer
+	inode   int64
+	written int64
+}
+
+// writeEntry writes file described by tar header with data read from r.
+func (writer *cpioWriter) writeEntry(header *tar.Header, r io.Reader) error {
+	name := strings.TrimSuffix(header.Name, "/")
+	mode := header.Mode & 07777
+	size := header.Size
+
+	var data io.Reader
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+		mode |= cpioTypeRegular
+		data = io.LimitReader(r, header.Size)
+
+	case tar.TypeDir:
+		mode |= cpioTypeDirectory
+
+	case tar.TypeSymlink:
+		mode |= cpioTypeSymlink
+		data = strings.NewReader(header.Linkname)
+		size = int64(len(header.Linkname))
+
+	// hard link is written as a copy, because newc archive stores data of
+	// linked files only once, in the first of them, which should already
+	// know number of links
+	case tar.TypeLink:
+		source := path.Clean("/" + header.Linkname)
+
+		entry, err := writer.fs.lookup(source)
+		if err != nil {
+			return fmt.Errorf(`can't resolve hard link <%s>: %w`, header.Name, err)
+		}
+
+		linked, err := writer.fs.header(entry)
+		if err != nil {
+			return err
+		}
+
+		if linked.Typeflag != tar.TypeReg {
+			return fmt.Errorf(
+				`%w: cpio hard link <%s> to non-regular file`,
+				ErrNotImplemented, header.Name,
+			)
+		}
+
+		mode |= cpioTypeRegular
+		data = writer.fs.newReader(entry, source)
+		size = entry.size
+
+	default:
+		return fmt.Errorf(
+			`%w: cpio entry of type %q for <%s>`,
+			ErrNotImplemented, header.Typeflag, header.Name,
+		)
+	}
+
+	writer.inode++
+
+	err := writer.writeHeader(
+		name, mode, header.Uid, header.Gid, size, header.ModTime.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	written, err := copyBuffered(writer, data)
+	if err != nil {
+		return err
+	}
+
+	if written != size {
+		return io.ErrUnexpectedEOF
+	}
+
+	return writer.pad()
+}
+
+// writeHeader writes newc header followed by padded name.
+func (writer *cpioWriter) writeHeader(
+	name string, mode int64, uid int, gid int, size int64, modTime int64,
+) error {
+	links := 1
+	if mode&cpioTypeMask == cpioTypeDirectory {
+		links = 2
+	}
+
+	_, err := fmt.Fprintf(
+		writer,
+		"%s%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%s\x00",
+		cpioMagic,
+		writer.inode, mode, uid, gid, links, modTime, size,
+		0, 0, 0, 0, len(name)+1, 0,
+		name,
+	)
+	if err != nil {
+		return err
+	}
+
+	return writer.pad()
+}
+
+func (writer *cpioWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	writer.written += int64(n)
+
+	return n, err
+}
+
+// pad aligns archive to 4 bytes.
+func (writer *cpioWriter) pad() error {
+	padding := (4 - writer.written%4) % 4
+
+	_, err := writer.Write(make([]byte, padding))
+
+	return err
+}
@@ -0,0 +1,177 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanWriteCpio(t *testing.T) {
+	container := mockfile.New("lala83")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	archive := &bytes.Buffer{}
+
+	err = fs.WriteCpio(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, modes, _ := readCpio(t, archive.Bytes())
+
+	if files["a/1"] != "1\n" || files["b/2"] != "2\n" {
+		t.Fatalf("unexpected files in archive: %v", files)
+	}
+
+	if modes["a"]&cpioTypeMask != cpioTypeDirectory {
+		t.Fatalf("directory <a> is not written: %o", modes["a"])
+	}
+}
+
+func TestCanWriteHardLinksToCpio(t *testing.T) {
+	layer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(layer)
+
+	err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "a/1",
+		Mode:     0644,
+		Uid:      1000,
+		Gid:      100,
+		Size:     2,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = tarWriter.Write([]byte("1\n"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     "b/1",
+		Linkname: "a/1",
+		Mode:     0644,
+		Uid:      1000,
+		Gid:      100,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala94")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedOCILayer(layer)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	archive := &bytes.Buffer{}
+
+	err = fs.WriteCpio(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, modes, owners := readCpio(t, archive.Bytes())
+
+	if files["b/1"] != "1\n" || modes["b/1"]&cpioTypeMask != cpioTypeRegular {
+		t.Fatalf("hard link is not written as copy: %q", files["b/1"])
+	}
+
+	if owners["a/1"] != [2]int64{1000, 100} {
+		t.Fatalf("unexpected owner of <a/1>: %v", owners["a/1"])
+	}
+}
+
+// readCpio returns contents, modes and owners of files in newc archive.
+func readCpio(
+	t *testing.T, data []byte,
+) (map[string]string, map[string]int64, map[string][2]int64) {
+	files := map[string]string{}
+	modes := map[string]int64{}
+	owners := map[string][2]int64{}
+
+	for {
+		if len(data) < 110 || string(data[:6]) != cpioMagic {
+			t.Fatalf("invalid cpio header: %q", data)
+		}
+
+		field := func(index int) int64 {
+			value, err := strconv.ParseInt(
+				string(data[6+index*8:6+index*8+8]), 16, 64,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return value
+		}
+
+		mode, size, nameSize := field(1), field(6), field(11)
+		uid, gid := field(2), field(3)
+		name := string(data[110 : 110+nameSize-1])
+
+		data = data[(110+nameSize+3)/4*4:]
+
+		if name == cpioTrailer {
+			break
+		}
+
+		files[name] = string(data[:size])
+		modes[name] = mode
+		owners[name] = [2]int64{uid, gid}
+
+		data = data[(size+3)/4*4:]
+	}
+
+	if len(data) != 0 {
+		t.Fatalf("unexpected data after trailer: %q", data)
+	}
+
+	return files, modes, owners
+}
@@ -0,0 +1,37 @@
+package embedfs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+)
+
+// Decode reads file from embedded fs and decodes its contents into v by
+// specified unmarshal function, like yaml.Unmarshal or toml.Unmarshal, so
+// embedded configuration in any format can be loaded in one call.
+func Decode(
+	fs *EmbedFs, path string, v interface{},
+	unmarshal func([]byte, interface{}) error,
+) error {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf(`can't decode <%s>: %w`, path, err)
+	}
+
+	return nil
+}
+
+// DecodeJSON decodes JSON file from embedded fs into v.
+func DecodeJSON(fs *EmbedFs, path string, v interface{}) error {
+	return Decode(fs, path, v, json.Unmarshal)
+}
+
+// DecodeXML decodes XML file from embedded fs into v.
+func DecodeXML(fs *EmbedFs, path string, v interface{}) error {
+	return Decode(fs, path, v, xml.Unmarshal)
+}
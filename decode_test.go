@@ -0,0 +1,71 @@
+package embedfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanDecodeConfig(t *testing.T) {
+	container := mockfile.New("lala62")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.embedData("/config.json", []byte(`{"listen": ":80"}`))
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.embedData("/config.xml", []byte(
+		`<config><listen>:443</listen></config>`,
+	))
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	var config struct {
+		Listen string `json:"listen" xml:"listen"`
+	}
+
+	err = DecodeJSON(fs, "/config.json", &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Listen != ":80" {
+		t.Fatalf("unexpected JSON config: %+v", config)
+	}
+
+	err = DecodeXML(fs, "/config.xml", &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Listen != ":443" {
+		t.Fatalf("unexpected XML config: %+v", config)
+	}
+
+	err = DecodeJSON(fs, "/config.xml", &config)
+	if err == nil {
+		t.Fatal("error is expected for malformed JSON")
+	}
+
+	err = DecodeJSON(fs, "/missing.json", &config)
+	if !errors.Is(err, ErrNoExist) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"sort"
+)
+
+// Difference lists names of files, which differ between two embedded fs.
+// Names are sorted.
+type Difference struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Diff compares embedded fs a with b and returns files, which are added to
+// b, removed from b or modified in it. Files are compared by checksums of
+// contents, type, mode, owner and link target; modification times are not
+// compared, because they are changed by every build.
+func Diff(a, b *EmbedFs) (*Difference, error) {
+	before, err := a.headers()
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := b.headers()
+	if err != nil {
+		return nil, err
+	}
+
+	difference := &Difference{}
+
+	for name, header := range after {
+		previous, ok := before[name]
+		if !ok {
+			difference.Added = append(difference.Added, name)
+			continue
+		}
+
+		modified, err := isModified(a, previous, b, header)
+		if err != nil {
+			return nil, err
+		}
+
+		if modified {
+			difference.Modified = append(difference.Modified, name)
+		}
+	}
+
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			difference.Removed = append(difference.Removed, name)
+		}
+	}
+
+	sort.Strings(difference.Added)
+	sort.Strings(difference.Removed)
+	sort.Strings(difference.Modified)
+
+	return difference, nil
+}
+
+// headers returns headers of visible files by their names.
+func (fs *EmbedFs) headers() (map[string]*tar.Header, error) {
+	headers := map[string]*tar.Header{}
+
+	err := fs.list("/", true, func(entry Entry) error {
+		header, err := fs.header(entry.entry)
+		if err != nil {
+			return err
+		}
+
+		// later entry with the same name shadows previous one
+		headers[entry.Name()] = header
+
+		return nil
+	})
+
+	return headers, err
+}
+
+func isModified(
+	a *EmbedFs, before *tar.Header, b *EmbedFs, after *tar.Header,
+) (bool, error) {
+	if before.Typeflag != after.Typeflag || before.Mode != after.Mode ||
+		before.Uid != after.Uid || before.Gid != after.Gid ||
+		before.Linkname != after.Linkname || before.Size != after.Size {
+		return true, nil
+	}
+
+	beforeHash, err := a.hashOf(before)
+	if err != nil {
+		return false, err
+	}
+
+	afterHash, err := b.hashOf(after)
+	if err != nil {
+		return false, err
+	}
+
+	return beforeHash != afterHash, nil
+}
+
+// hashOf returns stored checksum of file with specified header, calculating
+// it if it's not stored.
+func (fs *EmbedFs) hashOf(header *tar.Header) (string, error) {
+	hash, ok := header.PAXRecords[paxChecksum]
+	if ok {
+		return hash, nil
+	}
+
+	entry, err := fs.lookup(header.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return fs.checksum(entry)
+}
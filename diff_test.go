@@ -0,0 +1,65 @@
+package embedfs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanDiffEmbeddedFs(t *testing.T) {
+	embed := func(name string, files map[string]string) *EmbedFs {
+		container := mockfile.New(name)
+
+		embedder, err := Create(container)
+		if err != nil {
+			panic(err)
+		}
+
+		for target, source := range files {
+			err = embedder.EmbedFile(source, target)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		fs, err := Open(container)
+		if err != nil {
+			panic(err)
+		}
+
+		return fs
+	}
+
+	a := embed("lala71", map[string]string{
+		"/same":    "_test/a/1",
+		"/changed": "_test/a/1",
+		"/removed": "_test/a/1",
+	})
+
+	b := embed("lala72", map[string]string{
+		"/same":    "_test/a/1",
+		"/changed": "_test/b/2",
+		"/added":   "_test/a/1",
+	})
+
+	difference, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Difference{
+		Added:    []string{"/added"},
+		Removed:  []string{"/removed"},
+		Modified: []string{"/changed"},
+	}
+
+	if !reflect.DeepEqual(difference, expected) {
+		t.Fatalf("unexpected difference: %+v", difference)
+	}
+}
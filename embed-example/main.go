@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -20,6 +21,9 @@ Usage:
   embed-example -C <file>
   embed-example -L
   embed-example -T <target>
+  embed-example -M <target>
+  embed-example -D <target>
+  embed-example -W <target> <dir>
 
 Options:
   -h --help  Show this screen.
@@ -27,7 +31,10 @@ Options:
   -E         Embed specified <file>s into <target> binary.
   -C         Print contents of specified file to stdout.
   -L         List embedded files.
-  -T         Truncate current binary and write clean binary to <target>.`
+  -T         Truncate current binary and write clean binary to <target>.
+  -M         Migrate embedfs of <target> binary to the latest format.
+  -D         Show files which differ in embedfs of <target> binary.
+  -W         Watch <dir> and embed it into <target> binary on every change.`
 
 	args, _ := docopt.Parse(usage, nil, true, "EmbedFS Example", false)
 
@@ -44,6 +51,12 @@ Options:
 		CatFile(os.Args[0], args["<file>"].([]string)[0])
 	case args["-T"]:
 		Truncate(os.Args[0], args["<target>"].(string))
+	case args["-M"]:
+		Migrate(args["<target>"].(string))
+	case args["-D"]:
+		DiffFiles(os.Args[0], args["<target>"].(string))
+	case args["-W"]:
+		Watch(args["<target>"].(string), args["<dir>"].(string))
 	case args["-I"]:
 		Check(os.Args[0])
 	}
@@ -62,27 +75,61 @@ func EmbedFiles(sourceName string, embedFsFileName string, files []string) {
 
 	source, err := os.Open(sourceName)
 	if err != nil {
-		log.Fatalf(`can't open <%s> for reading: %s`, source, err)
+		log.Fatalf(`can't open <%s> for reading: %s`, sourceName, err)
 	}
 
-	io.Copy(target, source)
+	defer source.Close()
 
-	embedder, err := embedfs.Create(target)
+	_, err = io.Copy(target, source)
 	if err != nil {
-		log.Fatalf(`can't create embedfs on <%s>: %s`, embedFsFileName, err)
+		log.Fatalf(
+			`can't copy <%s> to <%s>: %s`, sourceName, embedFsFileName, err,
+		)
 	}
 
-	defer embedder.Close()
+	err = target.Close()
+	if err != nil {
+		log.Fatalf(`can't close <%s>: %s`, embedFsFileName, err)
+	}
+
+	err = embedfs.Reembed(
+		embedFsFileName,
+		func(embedder *embedfs.Embedder) error {
+			for _, fileName := range files {
+				err := embedder.EmbedFile(fileName, fileName)
+				if err != nil {
+					log.Printf(`can't embed file <%s> into <%s>: %s`,
+						fileName,
+						embedFsFileName,
+						err.Error(),
+					)
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		log.Fatalf(`can't embed files into <%s>: %s`, embedFsFileName, err)
+	}
+}
 
-	for _, fileName := range files {
-		err := embedder.EmbedFile(fileName, fileName)
-		if err != nil {
-			log.Printf(`can't embed file <%s> into <%s>: %s`,
-				fileName,
-				embedFsFileName,
-				err.Error(),
-			)
-		}
+func Watch(targetName string, dirName string) {
+	err := embedfs.Watch(
+		context.Background(), dirName, targetName,
+		embedfs.WatchOptions{
+			Rebuilt: func(err error) {
+				if err != nil {
+					log.Printf(`can't rebuild <%s>: %s`, targetName, err)
+					return
+				}
+
+				log.Printf(`<%s> is embedded into <%s>`, dirName, targetName)
+			},
+		},
+	)
+	if err != nil {
+		log.Fatalf(`can't watch <%s>: %s`, dirName, err)
 	}
 }
 
@@ -113,28 +160,52 @@ func CatFile(embedFsFileName string, fileName string) {
 }
 
 func Truncate(embedFsFileName string, targetName string) {
-	target, err := os.Create(targetName)
+	err := embedfs.StripTo(embedFsFileName, targetName, embedfs.StripOptions{})
+	if err != nil {
+		log.Fatalf(`can't write clean binary to <%s>: %s`, targetName, err)
+	}
+}
+
+func Migrate(targetName string) {
+	target, err := os.OpenFile(targetName, os.O_RDWR, 0)
 	if err != nil {
 		log.Fatalf(`can't open <%s> for writing: %s`, targetName, err)
 	}
 
 	defer target.Close()
 
-	err = os.Chmod(targetName, 0700)
+	err = embedfs.Migrate(target, embedfs.LatestFormatVersion)
 	if err != nil {
-		log.Fatalf(`can't chmod <%s> to 0700: %s`, targetName, err)
+		log.Fatalf(`can't migrate embedfs of <%s>: %s`, targetName, err)
 	}
+}
 
-	source, err := os.Open(embedFsFileName)
+func DiffFiles(embedFsFileName string, targetName string) {
+	fs, err := openEmbedFs(embedFsFileName)
 	if err != nil {
-		log.Fatalf(`can't open <%s> for reading: %s`, embedFsFileName, err)
+		log.Fatalf(`can't open embedfs: %s`, err)
 	}
 
-	io.Copy(target, source)
+	target, err := openEmbedFs(targetName)
+	if err != nil {
+		log.Fatalf(`can't open embedfs of <%s>: %s`, targetName, err)
+	}
 
-	err = embedfs.Truncate(target)
+	difference, err := embedfs.Diff(fs, target)
 	if err != nil {
-		log.Fatalf(`can't truncate embedfs: %s`, err)
+		log.Fatalf(`can't compare embedfs: %s`, err)
+	}
+
+	for _, name := range difference.Added {
+		fmt.Println("A", name)
+	}
+
+	for _, name := range difference.Removed {
+		fmt.Println("D", name)
+	}
+
+	for _, name := range difference.Modified {
+		fmt.Println("M", name)
 	}
 }
 
@@ -9,288 +9,1953 @@ package embedfs
 
 import (
 	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	iofs "io/fs"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/seletskiy/go-embed-fs/internal/zstd"
 )
 
 var (
 	ErrNotAvail       = errors.New("not available, embedfs is read only file system")
-	ErrNoExist        = errors.New("file is not exist")
 	ErrNoFootprint    = errors.New("no embedfs footprint found")
 	ErrInvalidOffset  = errors.New("embedfs offset is out of bounds of file")
+	ErrCorrupted      = errors.New("embedfs data is corrupted")
 	ErrNotImplemented = errors.New("not implemented yet")
+	ErrLimitExceeded  = errors.New("embedfs exceeds configured limits")
+	ErrFormatTooNew   = errors.New("embedfs format is newer than supported")
+	ErrInvalidSeek    = errors.New("invalid seek position")
+	ErrBudgetExceeded = errors.New("embedded files exceed size budget")
+	ErrDuplicate      = errors.New("file is already embedded")
+	ErrInvalidPath    = errors.New("path escapes root of embedfs")
+	ErrSignatureLen   = errors.New("signature should be 12 bytes long")
+	ErrExists         = errors.New("origin already contains embedfs")
+
+	// ErrNoExist is the same as fs.ErrNotExist, so errors.Is will work for
+	// both of them.
+	ErrNoExist = iofs.ErrNotExist
+)
+
+const signatureLen = 12
+
+// formatVersion is the version of embedfs format, which is written in the
+// last digits of signature.
+const formatVersion = 0
+
+const blockSize = 512
+
+// internalDir is the directory where embedfs stores its own data.
+const internalDir = "/.embedfs"
+
+const (
+	paxPrefix       = "EMBEDFS."
+	paxChecksum     = paxPrefix + "sha256"
+	paxContentType  = paxPrefix + "content-type"
+	paxExternal     = paxPrefix + "external"
+	paxExternalSize = paxPrefix + "external-size"
+	paxURL          = paxPrefix + "url"
+)
+
+var (
+	signature = [signatureLen]byte{
+		'E', 'M', 'B', 'E', 'D', 'F', 'S', '~', '0', '0', '0', ':',
+	}
+)
+
+// EmbedFs represents read-only instance of embedded fs, which can be used
+// for accessing previously embedded files and directories.
+type EmbedFs struct {
+	files   []*embedFsEntry
+	index   []*embedFsEntry
+	bloom   *bloomFilter
+	limiter *rateLimiter
+	origin  file
+	volumes []*EmbedFs
+	offset  int64
+	end     int64
+	options OpenOptions
+	errors  []error
+	damaged []ByteRange
+
+	// bare is set for embedfs opened by OpenAt, which has no footprint.
+	bare bool
+
+	// entries and totalSize count entries added so far, including files
+	// packed into solid blocks, and sum of their declared sizes, which are
+	// checked against Limits. Sidecar volumes continue counting of origin.
+	entries   int
+	totalSize int64
+
+	loadOnce sync.Once
+	loadErr  error
+
+	fingerprintsOnce sync.Once
+	fingerprintMap   map[string]string
+	fingerprintsErr  error
+
+	nested      map[string]iofs.FS
+	nestedMutex sync.Mutex
+
+	attributesOnce sync.Once
+	attributes     map[string]string
+
+	// pinned holds data of entries pinned in memory by Pin.
+	pinned sync.Map
+
+	dictionaryOnce sync.Once
+	dictionaryData []byte
+	dictionary     *zstd.Dictionary
+	dictionaryErr  error
+}
+
+// OpenMode specifies how embedfs should react on malformed data found
+// while opening.
+type OpenMode int
+
+const (
+	// ModeDefault stops on first malformed header and returns error.
+	ModeDefault OpenMode = iota
+
+	// ModeStrict stops on first malformed header, unknown PAX record or
+	// checksum mismatch and returns error. Contents of file are checked
+	// against its checksum when it's opened first time.
+	ModeStrict
+
+	// ModeLenient skips malformed entries and collects errors about them,
+	// which can be obtained later via Errors method.
+	ModeLenient
+)
+
+// OpenOptions holds settings which are used by OpenWithOptions.
+type OpenOptions struct {
+	Mode OpenMode
+
+	// Limits are enforced while reading embedfs index, so untrusted
+	// container can't exhaust memory.
+	Limits Limits
+
+	// Compact mode keeps only name, size and offsets for every entry in
+	// memory. Full headers are read from origin when they are needed.
+	Compact bool
+
+	// Lazy mode defers reading of embedfs index until first access to
+	// embedded files.
+	Lazy bool
+
+	// IndexCacheDir, if not empty, specifies directory where parsed index
+	// is stored between runs, keyed by hash of origin file, so subsequent
+	// opens of the same origin will not scan embedfs again.
+	IndexCacheDir string
+
+	// BloomFilter enables bloom filter in front of the index, which makes
+	// existence checks of missing files cheaper for large filesystems.
+	BloomFilter bool
+
+	// RateLimit limits total bandwidth of reading embedded files in bytes
+	// per second. Zero value means no limit.
+	RateLimit int64
+
+	// Metrics, if not nil, receives notifications about embedfs activity.
+	Metrics Metrics
+
+	// Logger, if not nil, receives messages about recoverable anomalies.
+	Logger Logger
+
+	// SortedListing makes ListDir return files in lexical order instead of
+	// the order they was added, so listing is stable across embedding runs.
+	SortedListing bool
+
+	// RemoteCacheDir specifies directory where contents of entries embedded
+	// by EmbedURL are stored after download. Default is embedfs directory
+	// in user cache directory, or directory of the user in temporary
+	// directory if there is none. Directory should be owned by the user
+	// and not writable by others, otherwise reading fails with
+	// ErrUnsafeDir.
+	RemoteCacheDir string
+
+	// ExtractCacheDir specifies directory where executables, plugins and
+	// libraries are extracted by Command, Plugin and Library. Default and
+	// requirements are the same as for RemoteCacheDir.
+	ExtractCacheDir string
+
+	// HTTPClient is used to download contents of entries embedded by
+	// EmbedURL. Default is http.DefaultClient.
+	HTTPClient *http.Client
+
+	// VerifyOnRead makes opened files to calculate checksum of contents
+	// while they are read, and to return error instead of io.EOF if it
+	// doesn't match checksum stored at embedding time. Only files read
+	// sequentially from the beginning are verified; ReadAt is not verified.
+	VerifyOnRead bool
+
+	// TrailerWindow, if not zero, specifies size of the trailing part of
+	// origin, where footprint is searched if it's not found in the very end,
+	// so origins with small amount of data appended after embedfs, like
+	// code signatures, still can be opened.
+	TrailerWindow int64
+
+	// Signature, if not empty, is used instead of default embedfs signature
+	// to find footprint. It should be the same as used for embedding.
+	Signature string
+
+	// Label, if not empty, selects section of origin with the specified
+	// label instead of the last one.
+	Label string
+
+	// Hooks are called on loading of embedfs and on opening and closing of
+	// embedded files.
+	Hooks Hooks
+
+	// Readahead, if not zero, makes sequential reads of opened files to
+	// read data from origin by chunks of at least specified size, so small
+	// reads don't cause requests to slow or remote origin.
+	Readahead int64
+
+	// DecryptionKey is the key used to read files, which were encrypted
+	// while embedding. Without it reading of encrypted files fails with
+	// ErrNoKey, while other files are available as usual.
+	DecryptionKey []byte
+
+	// MetadataOnly makes reading of contents of embedded files to fail with
+	// ErrMetadataOnly, while listing and headers are available as usual.
+	MetadataOnly bool
+}
+
+// Limits describes maximum sizes of embedfs which will be accepted by Open.
+//
+// Zero value of any field means no limit.
+type Limits struct {
+	// MaxEntries limits total number of entries.
+	MaxEntries int
+
+	// MaxTotalSize limits sum of declared sizes of all entries.
+	MaxTotalSize int64
+
+	// MaxPathDepth limits number of components in entry path.
+	MaxPathDepth int
+
+	// MaxNameLength limits length of entry path in bytes.
+	MaxNameLength int
+}
+
+type embedFsEntry struct {
+	name         string
+	offset       int64
+	size         int64
+	headerOffset int64
+
+	// header is nil when embedfs is opened in compact mode; it will be
+	// read from origin on demand.
+	header *tar.Header
+
+	// volume is sidecar volume where entry is stored, or nil for entries
+	// stored in origin.
+	volume *EmbedFs
+
+	// external is set for entries which data is stored in external file.
+	external *externalData
+
+	// whiteout is set for entries which remove previously embedded files.
+	whiteout string
+
+	// hidden is set for entries which are not listed.
+	hidden bool
+
+	// encrypted is set for entries which data is encrypted.
+	encrypted *encryptedData
+
+	// compressed is set for entries which data is compressed.
+	compressed *compressedData
+
+	// solid is set for solid blocks, and member is set for files packed
+	// into them, which headers are always kept in memory.
+	solid  bool
+	member bool
+
+	// gzipped is set for entries stored in gzip members of embedfs written
+	// with Stargz option.
+	gzipped *gzipMember
+
+	// squashed is set for files of embedfs written with Squashfs option.
+	squashed *squashfsFile
+
+	// verifyOnce guards verification of entry contents on first open in
+	// strict mode, which result is stored in verifyErr.
+	verifyOnce sync.Once
+	verifyErr  error
+}
+
+// storedAsIs returns true if data of entry is stored in its volume as is,
+// so it can be read directly from the volume.
+func (entry *embedFsEntry) storedAsIs() bool {
+	return entry.external == nil && entry.encrypted == nil &&
+		entry.compressed == nil && entry.gzipped == nil &&
+		entry.squashed == nil
+}
+
+type embedFsFootprint struct {
+	Signature [signatureLen]byte
+	Offset    int64
+}
+
+// Embedder writes files into embedfs. It's safe to embed files from
+// multiple goroutines: files are written one by one in the order embedding
+// calls acquire Embedder.
+type Embedder struct {
+	mutex sync.Mutex
+
+	writer    *tar.Writer
+	offset    int64
+	origin    io.Writer
+	options   EmbedOptions
+	signature [signatureLen]byte
+	sums      []embeddedChecksum
+
+	fingerprints map[string]string
+	totalSize    int64
+	embedded     map[string]bool
+	report       EmbedReport
+	attributes   map[string]string
+
+	// sidecars are volumes created by Embedder itself, and volumeSize is
+	// the size of data written to the current volume.
+	sidecars   []*os.File
+	volumeSize int64
+	volumeBase string
+
+	// aead is set when files should be encrypted.
+	aead cipher.AEAD
+
+	// dictionary is set when it's used for compression of any file, and
+	// zstdDictionary is CompressionDictionary parsed for compression.
+	dictionary     []byte
+	zstdDictionary *zstd.Dictionary
+
+	// block is solid block, which is being filled, and blocks is the number
+	// of already written blocks.
+	block  *solidBlock
+	blocks int
+
+	// stargz is set when embedfs is written in eStargz layout.
+	stargz *stargzWriter
+
+	// squashfs is set when embedfs is written as SquashFS image.
+	squashfs *squashfsWriter
+}
+
+// DuplicatePolicy specifies what Embedder does when file is embedded under
+// the name which is already used.
+type DuplicatePolicy int
+
+const (
+	// DuplicateOverwrite embeds file again, so it shadows previously
+	// embedded file with the same name.
+	DuplicateOverwrite DuplicatePolicy = iota
+
+	// DuplicateError returns ErrDuplicate.
+	DuplicateError
+
+	// DuplicateKeepFirst silently skips file, keeping previously embedded
+	// one.
+	DuplicateKeepFirst
+)
+
+// ExistingPolicy specifies what CreateWithOptions does when origin already
+// ends with embedfs.
+type ExistingPolicy int
+
+const (
+	// ExistingAppend embeds new embedfs after existing one, so it shadows
+	// existing one on Open, which still can be reached by OpenNamed, if it
+	// has a label.
+	ExistingAppend ExistingPolicy = iota
+
+	// ExistingError returns ErrExists.
+	ExistingError
+
+	// ExistingReplace truncates existing embedfs, like Truncate does, and
+	// embeds new one in its place.
+	ExistingReplace
 )
 
-const signatureLen = 12
+// EmbedOptions holds settings which are used by CreateWithOptions.
+type EmbedOptions struct {
+	// Fingerprint contains patterns of base names of files (as in
+	// path.Match), which should be embedded under names containing hash of
+	// their contents, like app.3f9ab2c1.js. Original names can be resolved
+	// to fingerprinted ones using Fingerprinted method of embedfs.
+	Fingerprint []string
+
+	// Transform, if not nil, is applied to contents of every embedded file,
+	// so files can be minified or re-encoded while embedding. Path is the
+	// path to the source file. Transformed contents are buffered in memory.
+	Transform func(path string, r io.Reader) (io.Reader, error)
+
+	// MaxFileSize, if not zero, limits size of every embedded file in bytes.
+	MaxFileSize int64
+
+	// MaxTotalSize, if not zero, limits total size of all embedded files in
+	// bytes, so accidentally added huge file will fail embedding.
+	MaxTotalSize int64
+
+	// Duplicates specifies how files embedded under already used names are
+	// handled.
+	Duplicates DuplicatePolicy
+
+	// Xattrs enables storing of extended attributes of embedded files as
+	// SCHILY.xattr PAX records. Supported only on Linux.
+	Xattrs bool
+
+	// DryRun makes Embedder to check and account files as usual, but not to
+	// write anything, so Report can be used to find out what would be
+	// embedded.
+	DryRun bool
+
+	// Events, if not nil, is called when embedding of file is started,
+	// skipped or finished. It's called concurrently when files are embedded
+	// from multiple goroutines.
+	Events func(Event)
+
+	// MapPath, if not nil, is called by EmbedDirectory for every found file
+	// with its path relative to the embedded directory, using forward
+	// slashes. It returns path under which file should be embedded relative
+	// to the prefix, or skip flag, if file should not be embedded at all.
+	MapPath func(relative string) (target string, skip bool)
+
+	// Filter, if not nil, is called by EmbedDirectory for every found file
+	// and directory, and returns false if it should not be embedded, so
+	// files can be selected by size, modification time or mode. Directories
+	// which are filtered out are not walked.
+	Filter func(path string, info iofs.FileInfo) bool
+
+	// PreserveSymlinks makes EmbedDirectory to embed found symlinks as
+	// symlinks instead of contents of files they point to.
+	PreserveSymlinks bool
+
+	// RewriteSymlinks makes absolute targets of preserved symlinks, which
+	// point inside of the embedded directory, relative to the symlink, so
+	// they remain valid after extraction to arbitrary directory.
+	RewriteSymlinks bool
+
+	// VolumeSize, if not zero, limits size of data embedded into single
+	// volume. Files which don't fit into origin are embedded into sidecar
+	// volumes, which are created next to origin and named like
+	// <origin>.1.efs. Files are never split, so file which is larger than
+	// VolumeSize gets a volume of its own.
+	VolumeSize int64
+
+	// Signature, if not empty, is written in footprint instead of default
+	// embedfs signature, so container format can be branded and is not
+	// discovered by tools looking for embedfs. It should be exactly 12 bytes
+	// long.
+	Signature string
+
+	// Label, if not empty, is stored in embedfs, so several independent
+	// sections, each with its own label, can be embedded one after another
+	// into the same origin and opened by OpenNamed.
+	Label string
+
+	// Version, if not empty, is stored in embedfs and can be obtained by
+	// Version method, so programs can detect stale payloads.
+	Version string
+
+	// BuildMetadata is stored in embedfs along with Version and can be
+	// obtained by BuildMetadata method.
+	BuildMetadata map[string]string
+
+	// CollectLicenses makes files named like LICENSE, NOTICE or COPYING to
+	// be also embedded into the dedicated directory, so licenses of bundled
+	// third-party assets can be printed by WriteLicenses method.
+	CollectLicenses bool
+
+	// Existing specifies what is done when origin already ends with
+	// embedfs at the current offset.
+	Existing ExistingPolicy
+
+	// Hidden contains patterns of paths of embedded files (as in
+	// path.Match, with trailing "/**" matching everything under the
+	// directory), which should be hidden: they are not returned by
+	// ListDir, List, Glob or ReadDir, but still can be opened by exact
+	// path, so internal payloads like keys don't show up in listings.
+	Hidden []string
+
+	// Encrypt contains patterns of paths of embedded files (as in Hidden),
+	// which should be encrypted by AES-256-GCM with EncryptionKey, so
+	// secrets can be embedded along with public files. Encrypted files are
+	// buffered in memory while embedding. Their checksums are sealed by the
+	// same key and are available only when EmbedFs is opened with it, and
+	// they are neither fingerprinted nor written by WriteSHA256Sums.
+	Encrypt []string
+
+	// Compress contains patterns of paths of embedded files (as in
+	// Hidden), which should be compressed by zstd. Compressed files are
+	// buffered in memory while embedding, and whole decompressed file is
+	// kept in memory of EmbedFs after first read, so it's meant for small
+	// files like locales, where CompressionDictionary recovers ratio lost
+	// by compressing files separately. Large files should not match these
+	// patterns.
+	Compress []string
+
+	// CompressionDictionary, if not empty, is used as zstd dictionary for
+	// compressed files and solid blocks and is stored in embedfs. It can
+	// be built from sample files by TrainDictionary, or by zstd --train.
+	CompressionDictionary []byte
+
+	// SolidBlockSize, if not zero, makes regular files not larger than
+	// SolidFileSize to be packed together into compressed solid blocks of
+	// about specified size, instead of being stored as separate tar
+	// entries, which take at least 1 KiB each. Files are decompressed by
+	// the whole block on first read, so blocks should be small. Encrypted
+	// files are never packed.
+	SolidBlockSize int64
+
+	// SolidFileSize is the maximum size of file packed into solid block.
+	// Zero value means 4 KiB.
+	SolidFileSize int64
+
+	// EncryptionKey is 32 bytes long key, which is used to encrypt files
+	// matching Encrypt patterns. Same key should be specified as
+	// DecryptionKey option of OpenWithOptions to read them.
+	EncryptionKey []byte
+
+	// Stargz makes embedfs to be written in eStargz layout: every entry is
+	// stored in gzip member of its own and table of contents is written in
+	// the end, so data between the offset of embedfs and its footprint can
+	// be pushed as container image layer, which is pulled lazily by
+	// runtimes, with annotation returned by TOCDigest. Such embedfs is
+	// opened as usual, but files are decompressed while they are read.
+	// Sidecar volumes are not supported.
+	Stargz bool
+
+	// Squashfs makes embedfs to be written as SquashFS image, which is
+	// built when Embedder is closed, so data between the offset of embedfs
+	// and its footprint can be mounted by kernel as is. Files are stored
+	// in separately compressed blocks of 128 KiB, PAX records of embedfs
+	// and extended attributes become extended attributes of inodes, and
+	// whiteouts are applied to the image instead of being stored. Such
+	// embedfs is opened as usual. Sidecar volumes, solid blocks, encrypted
+	// and compressed files are not supported.
+	Squashfs bool
+}
+
+type embeddedChecksum struct {
+	name string
+	hash string
+}
+
+type embedFileReader struct {
+	name   string
+	start  int64
+	length int64
+	offset int64
+	source io.ReaderAt
+	fs     *EmbedFs
+	entry  *embedFsEntry
+
+	// hash is set when embedfs is opened with VerifyOnRead option, hashed
+	// is the number of bytes passed to hash.
+	hash   hash.Hash
+	hashed int64
+
+	// ahead holds data read ahead from offset aheadOffset of the entry, when
+	// embedfs is opened with Readahead option.
+	ahead       []byte
+	aheadOffset int64
+}
+
+type file interface {
+	io.Closer
+	io.Writer
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+}
+
+// Open will return embedfs if it's available in specified source file.
+//
+// That embedfs should first be created by method Create.
+//
+// It will accept common file as it's argument, os.File will server well.
+func Open(origin file) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{})
+}
+
+// OpenStrict works like Open, but fails on any malformed header, unknown
+// PAX record or checksum mismatch found in embedfs.
+func OpenStrict(origin file) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{Mode: ModeStrict})
+}
+
+// OpenLenient works like Open, but skips malformed entries instead of
+// failing. Skipped entries are reported by Errors method.
+func OpenLenient(origin file) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{Mode: ModeLenient})
+}
+
+// OpenLazy works like Open, but defers reading of embedfs index until
+// embedded files are accessed first time, so opening is cheap.
+func OpenLazy(origin file) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{Lazy: true})
+}
+
+// OpenAt opens embedfs stored as tar archive at specified offset of origin
+// without looking for footprint, so payloads appended by plain
+// `cat binary payload.tar > out` or by other tooling can be read. Archive
+// is read up to its end marker or up to the end of origin. Relative names
+// of files in archive are opened as if they start with slash.
+func OpenAt(origin file, offset int64) (*EmbedFs, error) {
+	stat, err := origin.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 || offset > stat.Size() {
+		return nil, ErrInvalidOffset
+	}
+
+	fs := newEmbedFsAt(origin, offset, stat.Size(), OpenOptions{})
+	fs.bare = true
+
+	return fs, fs.load()
+}
+
+// OpenWithOptions works like Open, but allows to tune opening process by
+// specified options.
+func OpenWithOptions(origin file, options OpenOptions) (*EmbedFs, error) {
+	return OpenContext(context.Background(), origin, options)
+}
+
+// OpenContext works like OpenWithOptions, but reports opening via tracer
+// carried by specified context.
+//
+// Reading of embedfs index is stopped with context error as soon as context
+// is done, so opening of huge embedfs on slow media can be bounded by
+// deadline. Embedfs opened with Lazy option reads index without context.
+func OpenContext(
+	ctx context.Context, origin file, options OpenOptions,
+) (*EmbedFs, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := newEmbedFs(origin, options)
+	if err != nil {
+		return nil, err
+	}
+
+	_, span := startSpan(ctx, "embedfs.Open", map[string]interface{}{
+		"embedfs.payload_size": fs.end - fs.offset,
+	})
+
+	if !options.Lazy {
+		err = fs.loadContext(ctx)
+	}
+
+	endSpan(span, err)
+
+	return fs, err
+}
+
+func newEmbedFs(origin file, options OpenOptions) (*EmbedFs, error) {
+	stat, err := origin.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	magic, err := signatureOf(options.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	footprint, end, err := findFootprint(
+		origin, stat.Size(), options.TrailerWindow, magic,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Label != "" {
+		footprint, end, err = findSection(
+			origin, footprint, end, magic, options.Label,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newEmbedFsAt(origin, footprint.Offset, end, options), nil
+}
+
+// newEmbedFsAt returns embedfs located in specified range of origin.
+func newEmbedFsAt(
+	origin file, offset, end int64, options OpenOptions,
+) *EmbedFs {
+	if options.Metrics == nil {
+		options.Metrics = noMetrics{}
+	}
+
+	if options.Logger == nil {
+		options.Logger = noLogger{}
+	}
+
+	return &EmbedFs{
+		files:   []*embedFsEntry{},
+		origin:  origin,
+		offset:  offset,
+		end:     end,
+		options: options,
+		limiter: newRateLimiter(options.RateLimit),
+	}
+}
+
+// signatureOf returns specified custom signature or default one.
+func signatureOf(custom string) ([signatureLen]byte, error) {
+	magic := signature
+
+	if custom == "" {
+		return magic, nil
+	}
+
+	if len(custom) != signatureLen {
+		return magic, ErrSignatureLen
+	}
+
+	copy(magic[:], custom)
+
+	return magic, nil
+}
+
+// findFootprint returns footprint of embedfs and its offset in origin of
+// specified size. If there is no footprint in the very end of origin, it's
+// searched in the trailing window of specified size.
+func findFootprint(
+	origin io.ReaderAt, size int64, window int64, magic [signatureLen]byte,
+) (embedFsFootprint, int64, error) {
+	footprint := embedFsFootprint{}
+	footprintSize := int64(binary.Size(footprint))
+
+	if size < footprintSize {
+		return footprint, 0, ErrNoFootprint
+	}
+
+	location := size - footprintSize
+
+	err := binary.Read(
+		io.NewSectionReader(origin, location, footprintSize),
+		binary.BigEndian, &footprint,
+	)
+	if err != nil {
+		return footprint, 0, err
+	}
+
+	if footprint.Signature == magic {
+		if footprint.Offset >= location || footprint.Offset < 0 {
+			return footprint, 0, ErrInvalidOffset
+		}
+
+		return footprint, location, nil
+	}
+
+	notFound := ErrNoFootprint
+	if magic == signature {
+		notFound = signatureError(footprint.Signature)
+	}
+
+	if window > size {
+		window = size
+	}
+
+	if window <= footprintSize {
+		return footprint, 0, notFound
+	}
+
+	trailer := make([]byte, window)
+
+	_, err = origin.ReadAt(trailer, size-window)
+	if err != nil {
+		return footprint, 0, err
+	}
+
+	// footprint closest to the end wins, garbage is expected only after it
+	found := bytes.LastIndex(trailer[:window-footprintSize+signatureLen],
+		magic[:])
+	for ; found >= 0; found = bytes.LastIndex(trailer[:found], magic[:]) {
+		location = size - window + int64(found)
+
+		err = binary.Read(
+			bytes.NewReader(trailer[found:found+int(footprintSize)]),
+			binary.BigEndian, &footprint,
+		)
+		if err != nil {
+			return footprint, 0, err
+		}
+
+		if footprint.Offset < location && footprint.Offset >= 0 {
+			return footprint, location, nil
+		}
+	}
+
+	return footprint, 0, notFound
+}
+
+func (fs *EmbedFs) scan(ctx context.Context) error {
+	if isGzipped(fs.origin, fs.offset) {
+		return fs.scanStargz(ctx)
+	}
+
+	if isSquashfs(fs.origin, fs.offset) {
+		return fs.scanSquashfs(ctx)
+	}
+
+	section := io.NewSectionReader(fs.origin, fs.offset, fs.end-fs.offset)
+	tarReader := tar.NewReader(section)
+
+	var (
+		next        int64
+		skipping    bool
+		damagedFrom int64
+	)
+
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		headerOffset := next
+
+		tarHeader, err := tarReader.Next()
+		if err == io.EOF {
+			if skipping {
+				fs.addDamaged(damagedFrom, fs.end)
+			}
+
+			break
+		}
+
+		if err != nil {
+			err = &EntryError{
+				Offset: fs.offset + next,
+				Err:    fmt.Errorf(`%w: %w`, ErrCorrupted, err),
+			}
+
+			if fs.options.Mode != ModeLenient {
+				return err
+			}
+
+			if !skipping {
+				damagedFrom = fs.offset + next
+				fs.errors = append(fs.errors, err)
+
+				fs.options.Logger.Warn(
+					"skipping malformed entry",
+					"offset", fs.offset+next, "error", err,
+				)
+			}
+
+			skipping = true
+			next += blockSize
+
+			_, err = section.Seek(next, os.SEEK_SET)
+			if err != nil {
+				return err
+			}
+
+			tarReader = tar.NewReader(section)
+			continue
+		}
+
+		if skipping {
+			fs.addDamaged(damagedFrom, fs.offset+headerOffset)
+		}
+
+		skipping = false
+
+		seek, _ := section.Seek(0, os.SEEK_CUR)
+		next = seek + alignBlock(tarHeader.Size)
+
+		// global header holds attributes of the whole embedfs
+		if tarHeader.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		err = fs.addEntry(&embedFsEntry{
+			name:         tarHeader.Name,
+			offset:       fs.offset + seek,
+			size:         tarHeader.Size,
+			headerOffset: fs.offset + headerOffset,
+		}, tarHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addEntry adds entry described by specified header to the list of files,
+// setting up its data source according to PAX records of the header.
+func (fs *EmbedFs) addEntry(
+	entry *embedFsEntry, tarHeader *tar.Header,
+) error {
+	if fs.options.Mode == ModeStrict {
+		err := validateHeader(tarHeader)
+		if err != nil {
+			return &EntryError{
+				Name:   tarHeader.Name,
+				Offset: entry.headerOffset,
+				Err:    err,
+			}
+		}
+	}
+
+	fs.entries++
+	fs.totalSize += tarHeader.Size
+
+	err := fs.options.Limits.check(fs.entries, fs.totalSize, tarHeader)
+	if err != nil {
+		return err
+	}
+
+	// names in archives written by other tools are relative
+	entry.name = path.Clean("/" + entry.name)
+
+	entry.whiteout = tarHeader.PAXRecords[paxWhiteout]
+	entry.hidden = isHidden(tarHeader)
+
+	err = fs.attachExternal(entry, tarHeader)
+	if err == nil {
+		err = fs.attachEncryption(entry, tarHeader)
+	}
+
+	if err == nil {
+		err = fs.attachCompression(entry, tarHeader)
+	}
+
+	var members []*embedFsEntry
+	if err == nil {
+		members, err = fs.expandSolid(entry, tarHeader)
+	}
+
+	if err != nil {
+		return &EntryError{
+			Name:   tarHeader.Name,
+			Offset: entry.headerOffset,
+			Err:    err,
+		}
+	}
+
+	// headers of entries stored in gzip members or SquashFS image can't be
+	// read again cheaply
+	if !fs.options.Compact || entry.gzipped != nil || entry.squashed != nil {
+		entry.header = tarHeader
+	}
+
+	fs.files = append(fs.files, entry)
+	fs.files = append(fs.files, members...)
+
+	return nil
+}
+
+func (fs *EmbedFs) header(entry *embedFsEntry) (*tar.Header, error) {
+	if entry.header != nil {
+		return entry.header, nil
+	}
+
+	if entry.volume != nil {
+		fs = entry.volume
+	}
+
+	header, err := tar.NewReader(
+		io.NewSectionReader(
+			fs.origin, entry.headerOffset, fs.end-entry.headerOffset,
+		),
+	).Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry.storedAsIs() {
+		header.Size = entry.size
+	}
+
+	if entry.encrypted != nil {
+		entry.encrypted.unsealRecords(header)
+	}
+
+	return header, nil
+}
+
+// Errors returns list of errors about malformed entries, which were skipped
+// while opening embedfs in lenient mode.
+func (fs *EmbedFs) Errors() []error {
+	fs.load()
+
+	return fs.errors
+}
+
+func (fs *EmbedFs) load() error {
+	return fs.loadContext(context.Background())
+}
+
+// loadContext reads embedfs index once. If context is done while reading,
+// its error is returned by all subsequent loads.
+func (fs *EmbedFs) loadContext(ctx context.Context) error {
+	loaded := false
+
+	fs.loadOnce.Do(func() {
+		if fs.options.IndexCacheDir != "" {
+			fs.loadErr = fs.scanCached(ctx)
+		} else {
+			fs.loadErr = fs.scan(ctx)
+		}
+
+		if fs.loadErr == nil {
+			fs.loadErr = fs.loadVolumes(ctx)
+		}
+
+		fs.applyWhiteouts()
+		fs.buildIndex()
+
+		loaded = true
+	})
+
+	if loaded {
+		fs.options.Hooks.loaded(fs.loadErr)
+	}
+
+	return fs.loadErr
+}
+
+func validateHeader(header *tar.Header) error {
+	for key := range header.PAXRecords {
+		if !isKnownPAXRecord(key) {
+			return fmt.Errorf(`%w: unknown PAX record <%s>`, ErrCorrupted, key)
+		}
+	}
+
+	return nil
+}
+
+func isKnownPAXRecord(key string) bool {
+	switch key {
+	case "path", "linkpath", "size", "uid", "gid", "uname", "gname",
+		"mtime", "atime", "ctime", "charset", "comment", "hdrcharset":
+		return true
+	}
+
+	return strings.HasPrefix(key, paxPrefix) ||
+		strings.HasPrefix(key, "SCHILY.xattr.") ||
+		strings.HasPrefix(key, "GNU.sparse.")
+}
+
+func (limits Limits) check(
+	entries int, totalSize int64, header *tar.Header,
+) error {
+	if limits.MaxEntries > 0 && entries > limits.MaxEntries {
+		return fmt.Errorf(
+			`%w: more than %d entries`, ErrLimitExceeded, limits.MaxEntries,
+		)
+	}
+
+	if limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize {
+		return fmt.Errorf(
+			`%w: total size is more than %d bytes`,
+			ErrLimitExceeded, limits.MaxTotalSize,
+		)
+	}
+
+	if limits.MaxNameLength > 0 && len(header.Name) > limits.MaxNameLength {
+		return fmt.Errorf(
+			`%w: entry name is longer than %d bytes`,
+			ErrLimitExceeded, limits.MaxNameLength,
+		)
+	}
+
+	if limits.MaxPathDepth > 0 {
+		depth := len(strings.Split(strings.Trim(header.Name, "/"), "/"))
+		if depth > limits.MaxPathDepth {
+			return fmt.Errorf(
+				`%w: entry <%s> is deeper than %d levels`,
+				ErrLimitExceeded, header.Name, limits.MaxPathDepth,
+			)
+		}
+	}
+
+	return nil
+}
+
+func alignBlock(size int64) int64 {
+	return (size + blockSize - 1) / blockSize * blockSize
+}
+
+// Truncate erases all embedfs data from the specified file, leaving it
+// in the state it was before embedding has been done.
+func Truncate(origin file) error {
+	fs, err := Open(origin)
+	if err != nil {
+		return err
+	}
+
+	return origin.Truncate(fs.offset)
+}
+
+// Create creates new embedfs in the end of specified file.
+//
+// It will return Embedder, which can be used for storing files and directories
+// in that embedfs.
+//
+// After all files were added, Close method should be invoked to correctly
+// finish embedfs data.
+func Create(origin file) (*Embedder, error) {
+	return CreateWithOptions(origin, EmbedOptions{})
+}
+
+// CreateWithOptions works like Create, but allows to tune embedding process
+// by specified options.
+func CreateWithOptions(origin file, options EmbedOptions) (*Embedder, error) {
+	currentSeek, err := origin.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Existing != ExistingAppend {
+		currentSeek, err = probeExisting(origin, currentSeek, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewWriterWithOptions(origin, currentSeek, options)
+}
+
+// probeExisting looks for embedfs which ends at specified offset of origin
+// and handles it according to the Existing option. Returns offset at which
+// new embedfs should be written.
+func probeExisting(
+	origin file, offset int64, options EmbedOptions,
+) (int64, error) {
+	magic, err := signatureOf(options.Signature)
+	if err != nil {
+		return 0, err
+	}
+
+	footprint, _, err := findFootprint(origin, offset, 0, magic)
+	switch {
+	case errors.Is(err, ErrNoFootprint):
+		return offset, nil
+	case err != nil:
+		return 0, err
+	case options.Existing == ExistingError:
+		return 0, ErrExists
+	}
+
+	err = origin.Truncate(footprint.Offset)
+	if err != nil {
+		return 0, err
+	}
+
+	return origin.Seek(footprint.Offset, os.SEEK_SET)
+}
+
+// NewWriter creates new embedfs, which is written to w. Unlike Create, it
+// doesn't require origin to be seekable, so embedfs can be written while
+// binary is streamed to pipe or network connection.
+//
+// Offset is the number of bytes, which are already written to the target
+// before embedfs, like size of the binary itself.
+func NewWriter(w io.Writer, offset int64) (*Embedder, error) {
+	return NewWriterWithOptions(w, offset, EmbedOptions{})
+}
+
+// NewWriterWithOptions works like NewWriter, but allows to tune embedding
+// process by specified options.
+func NewWriterWithOptions(
+	w io.Writer, offset int64, options EmbedOptions,
+) (*Embedder, error) {
+	magic, err := signatureOf(options.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder := &Embedder{
+		signature:    magic,
+		writer:       tar.NewWriter(w),
+		offset:       offset,
+		origin:       w,
+		options:      options,
+		fingerprints: map[string]string{},
+		embedded:     map[string]bool{},
+	}
+
+	if options.Stargz {
+		if options.VolumeSize > 0 {
+			return nil, fmt.Errorf(
+				`%w: sidecar volumes in eStargz layout`, ErrNotImplemented,
+			)
+		}
+
+		embedder.stargz = newStargzWriter(w)
+		embedder.writer = tar.NewWriter(embedder.stargz)
+	}
+
+	if options.Squashfs {
+		switch {
+		case options.Stargz:
+			return nil, fmt.Errorf(
+				`%w: eStargz layout of SquashFS image`, ErrNotImplemented,
+			)
+
+		case options.VolumeSize > 0 || options.SolidBlockSize > 0 ||
+			len(options.Encrypt) > 0 || len(options.Compress) > 0:
+			return nil, fmt.Errorf(
+				`%w: sidecar volumes, solid blocks, encrypted or compressed `+
+					`files in SquashFS image`, ErrNotImplemented,
+			)
+		}
+
+		if !options.DryRun {
+			embedder.squashfs, err = newSquashfsWriter(w)
+			if err != nil {
+				return nil, err
+			}
+
+			embedder.writer = tar.NewWriter(embedder.squashfs)
+		}
+	}
+
+	if len(options.Encrypt) > 0 {
+		embedder.aead, err = newAEAD(options.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(options.CompressionDictionary) > 0 {
+		embedder.zstdDictionary, err = zstd.NewDictionary(
+			options.CompressionDictionary,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = embedder.writeGlobalHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	return embedder, nil
+}
+
+// EmbedFile used for embedding single file to the embedded fs.
+//
+// Specified file will be added to the end of list. SHA-256 checksum and
+// content type of the file are stored along with the file.
+func (e *Embedder) EmbedFile(path string, target string) error {
+	err := e.embedFile(path, target)
+	if err != nil {
+		return err
+	}
+
+	return e.collectLicense(path, target)
+}
+
+func (e *Embedder) embedFile(path string, target string) error {
+	e.options.emit(Event{Kind: EventStarted, Source: path, Target: target})
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tarHeader, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+
+	if e.options.Xattrs {
+		err = embedXattrs(tarHeader, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	sourceFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer sourceFile.Close()
+
+	if e.options.Transform == nil {
+		return e.embedContent(tarHeader, path, target, sourceFile)
+	}
+
+	transformed, err := e.options.Transform(path, sourceFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(transformed)
+	if err != nil {
+		return err
+	}
+
+	tarHeader.Size = int64(len(data))
+
+	return e.embedContent(tarHeader, path, target, bytes.NewReader(data))
+}
+
+// embedContent writes header and contents of the file, calculating checksum
+// and content type beforehand, so content is read twice. Content is nil for
+// symlinks.
+func (e *Embedder) embedContent(
+	tarHeader *tar.Header, source, target string, content io.ReadSeeker,
+) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	name := filepath.Join("/", target)
+
+	if e.embedded[name] {
+		switch e.options.Duplicates {
+		case DuplicateError:
+			return &iofs.PathError{Op: "embed", Path: name, Err: ErrDuplicate}
+
+		case DuplicateKeepFirst:
+			e.options.emit(Event{
+				Kind:   EventSkipped,
+				Source: source,
+				Target: name,
+				Reason: "file with the same name is already embedded",
+			})
+
+			return nil
+
+		default:
+			e.forgetChecksum(name)
+		}
+	}
+
+	err := e.checkBudget(target, tarHeader.Size)
+	if err != nil {
+		return err
+	}
+
+	checksum := embeddedChecksum{name: name}
+
+	// symlinks don't have contents, so they are stored without checksum
+	if content != nil {
+		err = e.describeContent(tarHeader, &checksum, target, content)
+		if err != nil {
+			return err
+		}
+
+		// checksum of encrypted file would allow to guess its contents
+		if !e.encrypts(name) {
+			e.sums = append(e.sums, checksum)
+		}
+	}
+
+	tarHeader.Name = checksum.name
+
+	e.hide(tarHeader, name)
+
+	e.embedded[name] = true
+	e.report.add(source, checksum.name, tarHeader.Size)
+
+	if !e.options.DryRun {
+		err = e.writeContent(tarHeader, name, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.options.emit(Event{
+		Kind:   EventFinished,
+		Source: source,
+		Target: checksum.name,
+		Bytes:  tarHeader.Size,
+	})
+
+	return nil
+}
+
+// writeContent writes header and content of the file, compressing and
+// encrypting it, or packs it into solid block, if needed.
+func (e *Embedder) writeContent(
+	tarHeader *tar.Header, name string, content io.ReadSeeker,
+) error {
+	if content != nil && e.packs(tarHeader, name) {
+		return e.pack(tarHeader, content)
+	}
+
+	// packed file with the same name should not shadow this one
+	if e.block != nil && e.block.names[tarHeader.Name] {
+		err := e.flushBlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+
+	if content != nil && e.compresses(name) {
+		content, err = e.compress(tarHeader, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if content != nil && e.encrypts(name) {
+		content, err = e.encrypt(tarHeader, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = e.reserveVolume(tarHeader.Size)
+	if err != nil {
+		return err
+	}
+
+	err = e.writeHeader(tarHeader)
+	if err != nil {
+		return err
+	}
+
+	if content != nil {
+		_, err = copyBuffered(e.writer, content)
+	}
+
+	return err
+}
+
+// describeContent calculates checksum and content type of the file and
+// stores them in PAX records of the header. Name of checksum is changed to
+// fingerprinted one, if needed.
+//
+// Files which are going to be encrypted are not fingerprinted and their
+// content type is not stored, and checksum is sealed by encrypt later, so
+// nothing derived from their contents is stored in the clear.
+func (e *Embedder) describeContent(
+	tarHeader *tar.Header, checksum *embeddedChecksum, target string,
+	content io.ReadSeeker,
+) error {
+	head := make([]byte, sniffLen)
+
+	headLen, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	hash := sha256.New()
+	hash.Write(head[:headLen])
+
+	_, err = copyBuffered(hash, content)
+	if err != nil {
+		return err
+	}
+
+	_, err = content.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	checksum.hash = hex.EncodeToString(hash.Sum(nil))
+
+	if tarHeader.PAXRecords == nil {
+		tarHeader.PAXRecords = map[string]string{}
+	}
+
+	tarHeader.PAXRecords[paxChecksum] = checksum.hash
+
+	if e.encrypts(checksum.name) {
+		return nil
+	}
+
+	checksum.name = e.fingerprint(checksum.name, checksum.hash)
+
+	tarHeader.PAXRecords[paxContentType] = detectContentType(
+		target, head[:headLen],
+	)
+
+	return nil
+}
+
+// forgetChecksum removes checksum of file which is going to be shadowed by
+// newly embedded one.
+func (e *Embedder) forgetChecksum(name string) {
+	if fingerprinted, ok := e.fingerprints[name]; ok {
+		delete(e.fingerprints, name)
+		name = fingerprinted
+	}
+
+	for i, checksum := range e.sums {
+		if checksum.name == name {
+			e.sums = append(e.sums[:i], e.sums[i+1:]...)
+			break
+		}
+	}
+}
+
+// checkBudget accounts file of specified size against MaxFileSize and
+// MaxTotalSize options.
+func (e *Embedder) checkBudget(target string, size int64) error {
+	maxFile := e.options.MaxFileSize
+	if maxFile > 0 && size > maxFile {
+		return fmt.Errorf(
+			`%w: file <%s> is %d bytes, which is more than %d bytes`,
+			ErrBudgetExceeded, target, size, maxFile,
+		)
+	}
+
+	maxTotal := e.options.MaxTotalSize
+	if maxTotal > 0 && e.totalSize+size > maxTotal {
+		return fmt.Errorf(
+			`%w: adding file <%s> makes total size more than %d bytes`,
+			ErrBudgetExceeded, target, maxTotal,
+		)
+	}
+
+	e.totalSize += size
+
+	return nil
+}
+
+// embedData embeds specified data as a regular file.
+func (e *Embedder) embedData(name string, data []byte) error {
+	hash := sha256.Sum256(data)
+
+	err := e.writeHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		PAXRecords: map[string]string{
+			paxChecksum:    hex.EncodeToString(hash[:]),
+			paxContentType: detectContentType(name, data),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.writer.Write(data)
+
+	return err
+}
+
+// writeHeader writes header of the next entry. In eStargz layout every
+// entry is started in new gzip member.
+func (e *Embedder) writeHeader(tarHeader *tar.Header) error {
+	if e.stargz != nil {
+		err := e.stargz.next(e.writer)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := e.writer.WriteHeader(tarHeader)
+	if err != nil {
+		return err
+	}
 
-var (
-	signature = [signatureLen]byte{
-		'E', 'M', 'B', 'E', 'D', 'F', 'S', '~', '0', '0', '0', ':',
+	if e.stargz != nil {
+		e.stargz.record(tarHeader)
 	}
-)
 
-// EmbedFs represents read-only instance of embedded fs, which can be used
-// for accessing previously embedded files and directories.
-type EmbedFs struct {
-	files  []*embedFsEntry
-	index  map[string]*embedFsEntry
-	origin file
-	offset int64
+	return nil
 }
 
-type embedFsEntry struct {
-	name   string
-	offset int64
-	header *tar.Header
-}
+// EmbedDirectory used for embedding entire directory to the embedded fs.
+//
+// It's simple wrapper under filepath.Walk and EmbedFile. Files can be
+// selected by Filter option and renamed by MapPath option.
+func (e *Embedder) EmbedDirectory(root, prefix string) error {
+	return filepath.Walk(root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
 
-type embedFsFootprint struct {
-	Signature [signatureLen]byte
-	Offset    int64
-}
+			if e.options.Filter != nil && path != root &&
+				!e.options.Filter(path, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
 
-type Embedder struct {
-	writer *tar.Writer
-	offset int64
-	origin file
-}
+				e.options.emit(Event{
+					Kind:   EventSkipped,
+					Source: path,
+					Reason: "skipped by filter",
+				})
 
-type embedFileReader struct {
-	name   string
-	start  int64
-	length int64
-	offset int64
-	source file
-}
+				return nil
+			}
 
-type file interface {
-	io.Closer
-	io.Writer
-	io.Reader
-	io.ReaderAt
-	io.Seeker
-	Stat() (os.FileInfo, error)
-	Truncate(size int64) error
+			if info.IsDir() {
+				return nil
+			}
+
+			target := strings.TrimPrefix(path, root)
+
+			if e.options.MapPath != nil {
+				relative, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+
+				mapped, skip := e.options.MapPath(filepath.ToSlash(relative))
+				if skip {
+					e.options.emit(Event{
+						Kind:   EventSkipped,
+						Source: path,
+						Reason: "skipped by path mapping",
+					})
+
+					return nil
+				}
+
+				target = mapped
+			}
+
+			target = filepath.Join(prefix, target)
+
+			if e.options.PreserveSymlinks && info.Mode()&os.ModeSymlink != 0 {
+				return e.embedSymlink(root, path, target, info)
+			}
+
+			return e.EmbedFile(path, target)
+		},
+	)
 }
 
-// Open will return embedfs if it's available in specified source file.
-//
-// That embedfs should first be created by method Create.
+// Close stops embedding process and write end marker to the container file.
 //
-// It will accept common file as it's argument, os.File will server well.
-func Open(origin file) (*EmbedFs, error) {
-	stat, err := origin.Stat()
-	if err != nil {
-		return nil, err
+// After this invokation embedded fs are no longer write-capable.
+func (e *Embedder) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.options.DryRun {
+		return nil
 	}
 
-	footprint := embedFsFootprint{}
-	_, err = origin.Seek(-int64(binary.Size(footprint)), os.SEEK_END)
+	err := e.flushBlock()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = binary.Read(origin, binary.BigEndian, &footprint)
+	err = e.writeFingerprints()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if footprint.Signature != signature {
-		return nil, ErrNoFootprint
+	err = e.writeDictionary()
+	if err != nil {
+		return err
 	}
 
-	if footprint.Offset >= stat.Size() || footprint.Offset < 0 {
-		return nil, ErrInvalidOffset
+	err = e.writeAttributes()
+	if err != nil {
+		return err
 	}
 
-	fs := &EmbedFs{
-		files:  []*embedFsEntry{},
-		index:  map[string]*embedFsEntry{},
-		origin: origin,
-		offset: footprint.Offset,
+	err = e.closeVolume()
+	if err != nil {
+		return err
 	}
 
-	_, err = origin.Seek(fs.offset, os.SEEK_SET)
-	if err != nil {
-		return nil, err
+	for _, sidecar := range e.sidecars {
+		err = sidecar.Close()
+		if err != nil {
+			return err
+		}
 	}
 
-	tarReader := tar.NewReader(origin)
+	return nil
+}
 
-	for {
-		tarHeader, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
+// closeVolume finishes tar stream and writes footprint to the current
+// volume.
+func (e *Embedder) closeVolume() error {
+	var err error
 
-		if err != nil {
-			return fs, err
-		}
+	switch {
+	case e.stargz != nil:
+		err = e.stargz.close(e.writer)
 
-		seek, _ := origin.Seek(0, os.SEEK_CUR)
-		entry := &embedFsEntry{
-			name:   tarHeader.Name,
-			offset: seek,
-			header: tarHeader,
+	case e.squashfs != nil:
+		err = e.writer.Close()
+		if err == nil {
+			err = e.squashfs.close()
 		}
 
-		fs.files = append(fs.files, entry)
-		fs.index[entry.name] = entry
+	default:
+		err = e.writer.Close()
 	}
 
-	return fs, nil
-}
-
-// Truncate erases all embedfs data from the specified file, leaving it
-// in the state it was before embedding has been done.
-func Truncate(origin file) error {
-	fs, err := Open(origin)
 	if err != nil {
 		return err
 	}
 
-	return origin.Truncate(fs.offset)
+	return binary.Write(e.origin, binary.BigEndian, embedFsFootprint{
+		e.signature,
+		e.offset,
+	})
 }
 
-// Create creates new embedfs in the end of specified file.
-//
-// It will return Embedder, which can be used for storing files and directories
-// in that embedfs.
+// Open opens specified file from embedded fs for reading only.
 //
-// After all files were added, Close method should be invoked to correctly
-// finish embedfs data.
-func Create(origin file) (*Embedder, error) {
-	currentSeek, err := origin.Seek(0, os.SEEK_CUR)
+// If several files were embedded under the same name, the last embedded one
+// is opened.
+func (fs *EmbedFs) Open(path string) (file, error) {
+	started := time.Now()
+
+	path, err := cleanPath(path)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	entry, err := fs.lookup(path)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	reader, err := fs.openEntry(entry, path, started)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Embedder{
-		writer: tar.NewWriter(origin),
-		offset: currentSeek,
-		origin: origin,
-	}, nil
+	return reader, nil
 }
 
-// EmbedFile used for embedding single file to the embedded fs.
-//
-// Specified file will be added to the end of list.
-func (e Embedder) EmbedFile(path string, target string) error {
-	stat, err := os.Stat(path)
+// openEntry returns reader of the entry found by lookup started at
+// specified time, reporting opening to metrics and hooks.
+func (fs *EmbedFs) openEntry(
+	entry *embedFsEntry, path string, started time.Time,
+) (*embedFileReader, error) {
+	err := fs.verifyStrict(entry)
 	if err != nil {
-		return err
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
 	}
 
-	tarHeader, err := tar.FileInfoHeader(stat, "")
+	fs.options.Metrics.FileOpened(path, time.Since(started))
+	fs.options.Hooks.opened(path)
+
+	return fs.newReader(entry, path), nil
+}
+
+func (fs *EmbedFs) newReader(
+	entry *embedFsEntry, name string,
+) *embedFileReader {
+	reader := &embedFileReader{
+		start:  entry.offset,
+		length: entry.size,
+		source: fs.originOf(entry),
+		name:   name,
+		fs:     fs,
+		entry:  entry,
+	}
+
+	if pinned, ok := fs.pinned.Load(entry); ok {
+		reader.start = 0
+		reader.source = pinned.(io.ReaderAt)
+	}
+
+	if fs.options.VerifyOnRead {
+		reader.hash = sha256.New()
+	}
+
+	return reader
+}
+
+// originOf returns source of data of specified entry.
+func (fs *EmbedFs) originOf(entry *embedFsEntry) io.ReaderAt {
+	if fs.options.MetadataOnly {
+		return metadataOnly{}
+	}
+
+	if entry.compressed != nil {
+		return entry.compressed
+	}
+
+	if entry.encrypted != nil {
+		return entry.encrypted
+	}
+
+	if entry.gzipped != nil {
+		return entry.gzipped
+	}
+
+	if entry.squashed != nil {
+		return entry.squashed
+	}
+
+	if entry.external != nil {
+		return entry.external
+	}
+
+	if entry.volume != nil {
+		return entry.volume.origin
+	}
+
+	return fs.origin
+}
+
+// Stat returns file info of the specified file from embedded fs.
+func (fs *EmbedFs) Stat(path string) (os.FileInfo, error) {
+	name, err := cleanPath(path)
 	if err != nil {
-		return err
+		return nil, &iofs.PathError{Op: "stat", Path: path, Err: err}
 	}
 
-	tarHeader.Name = filepath.Join("/", target)
-	e.writer.WriteHeader(tarHeader)
+	entry, err := fs.lookup(name)
 	if err != nil {
-		return err
+		return nil, &iofs.PathError{Op: "stat", Path: path, Err: err}
 	}
 
-	sourceFile, err := os.Open(path)
+	header, err := fs.header(entry)
 	if err != nil {
-		return err
+		return nil, &iofs.PathError{Op: "stat", Path: path, Err: err}
 	}
 
-	defer sourceFile.Close()
+	return header.FileInfo(), nil
+}
+
+// ListDir return list of files in embedded fs in the order they was added
+// or in lexical order, if embedfs is opened with SortedListing option.
+func (fs *EmbedFs) ListDir(path string) ([]string, error) {
+	result := []string{}
 
-	_, err = io.Copy(e.writer, sourceFile)
+	err := fs.List(path, func(entry Entry) error {
+		result = append(result, entry.Name())
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return result, nil
 }
 
-// EmbedDirectory used for embedding entire directory to the embedded fs.
-//
-// It's simple wrapper under filepath.Walk and EmbedFile.
-func (e Embedder) EmbedDirectory(root, prefix string) error {
-	return filepath.Walk(root,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+// IsFileExist return true, if specified file exist in embedded fs.
+func (fs *EmbedFs) IsFileExist(path string) bool {
+	path, err := cleanPath(path)
+	if err != nil {
+		return false
+	}
 
-			if info.IsDir() {
-				return nil
+	_, err = fs.lookup(path)
+	return err == nil
+}
+
+// cleanPath converts specified path to the absolute one as it's stored in
+// embedfs: duplicate and trailing slashes are removed, "." and ".."
+// elements are resolved. Paths which escape root via ".." are rejected, so
+// they can't silently refer to some other file.
+func cleanPath(name string) (string, error) {
+	depth := 0
+
+	for _, element := range strings.Split(name, "/") {
+		switch element {
+		case "", ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return name, ErrInvalidPath
 			}
+		default:
+			depth++
+		}
+	}
 
-			return e.EmbedFile(path,
-				filepath.Join(prefix, strings.TrimPrefix(path, root)))
-		},
-	)
+	return path.Clean("/" + name), nil
 }
 
-// Close stops embedding process and write end marker to the container file.
-//
-// After this invokation embedded fs are no longer write-capable.
-func (e Embedder) Close() error {
-	err := e.writer.Close()
+func (fs *EmbedFs) lookup(path string) (*embedFsEntry, error) {
+	err := fs.load()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = binary.Write(e.origin, binary.BigEndian, embedFsFootprint{
-		signature,
-		e.offset,
-	})
-
-	return err
-}
+	if fs.bloom != nil && !fs.bloom.mayContain(path) {
+		return nil, ErrNoExist
+	}
 
-// Open opens specified file from embedded fs for reading only.
-func (fs *EmbedFs) Open(path string) (file, error) {
-	path = filepath.Join("/", path)
+	// index is sorted stable, so if there are several entries with the same
+	// name, the last one will be found
+	found := sort.Search(len(fs.index), func(i int) bool {
+		return fs.index[i].name > path
+	})
 
-	if !fs.IsFileExist(path) {
+	if found == 0 || fs.index[found-1].name != path {
 		return nil, ErrNoExist
 	}
 
-	return &embedFileReader{
-		start:  fs.index[path].offset,
-		length: fs.index[path].header.Size,
-		source: fs.origin,
-		name:   path,
-	}, nil
+	return fs.index[found-1], nil
 }
 
-// ListDir return list of files in embedded fs in the order they was added.
-func (fs EmbedFs) ListDir(path string) ([]string, error) {
-	result := []string{}
+// sizeOf returns total size of specified files.
+func (fs *EmbedFs) sizeOf(names []string) int64 {
+	var size int64
 
-	for _, entry := range fs.files {
-		rootName := filepath.Join("/", entry.name)
-		if strings.HasPrefix(rootName, filepath.Join(path, "/")) {
-			result = append(result, entry.name)
+	for _, name := range names {
+		entry, err := fs.lookup(name)
+		if err == nil {
+			size += entry.size
 		}
 	}
 
-	return result, nil
+	return size
 }
 
-// IsFileExist return true, if specified file exist in embedded fs.
-func (fs *EmbedFs) IsFileExist(path string) bool {
-	_, exist := fs.index[path]
-	return exist
+func (fs *EmbedFs) buildIndex() {
+	fs.index = make([]*embedFsEntry, len(fs.files))
+	copy(fs.index, fs.files)
+
+	sort.SliceStable(fs.index, func(i, j int) bool {
+		return fs.index[i].name < fs.index[j].name
+	})
+
+	if fs.options.BloomFilter {
+		fs.bloom = newBloomFilter(len(fs.index))
+		for _, entry := range fs.index {
+			fs.bloom.add(entry.name)
+		}
+	}
 }
 
 // Create operation does not supported. For interface compatibility only.
 func (fs *EmbedFs) Create(path string) (file, error) {
-	return nil, ErrNotAvail
+	return nil, &iofs.PathError{Op: "create", Path: path, Err: ErrNotAvail}
 }
 
 // Create operation does not supported. For interface compatibility only.
-func (fs EmbedFs) TempFile() (file, error) {
+func (fs *EmbedFs) TempFile() (file, error) {
 	return nil, ErrNotAvail
 }
 
@@ -299,8 +1964,18 @@ func (fs *EmbedFs) Move(from string, to string) error {
 	return ErrNotAvail
 }
 
-// Close closes previously opened file. For interface compatibility only.
+// Close closes previously opened file, sidecar volumes and external files.
 func (fs *EmbedFs) Close() error {
+	for _, volume := range fs.volumes {
+		volume.origin.Close()
+	}
+
+	for _, entry := range fs.files {
+		if entry.external != nil {
+			entry.external.Close()
+		}
+	}
+
 	return fs.origin.Close()
 }
 
@@ -308,18 +1983,59 @@ func (fs *EmbedFs) Close() error {
 func (reader *embedFileReader) Read(b []byte) (int, error) {
 	rest := reader.length - reader.offset
 	if rest <= 0 {
-		return 0, io.EOF
+		return 0, reader.verify()
+	}
+
+	// buffer is limited, so data following the entry is never read
+	if int64(len(b)) > rest {
+		b = b[:rest]
+	}
+
+	n, err := reader.readAhead(b)
+
+	// ReadAt returns error on every short read, but full read can be
+	// accompanied by io.EOF if entry is located in the very end of source
+	switch {
+	case n == len(b):
+		err = nil
+	case err == io.EOF:
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		err = &EntryError{
+			Name:   reader.name,
+			Offset: reader.start + reader.offset + int64(n),
+			Err:    err,
+		}
+	}
+
+	if reader.fs.limiter != nil {
+		reader.fs.limiter.wait(n)
 	}
 
-	n, err := reader.source.ReadAt(b, reader.start+reader.offset)
+	reader.fs.options.Metrics.BytesRead(reader.name, n)
+
+	reader.hashChunk(b[:n])
+
+	reader.offset += int64(n)
+
+	return n, err
+}
 
-	if rest < int64(n) {
-		reader.offset += int64(rest)
-		return int(rest), err
-	} else {
-		reader.offset += int64(n)
-		return n, err
+func (reader *embedFileReader) WriteTo(w io.Writer) (int64, error) {
+	origin, isFile := reader.source.(*os.File)
+	readerFrom, isReaderFrom := w.(io.ReaderFrom)
+	if isFile && isReaderFrom && reader.fs.limiter == nil &&
+		reader.hash == nil {
+		written, handled, err := reader.sendFile(readerFrom, origin)
+		if handled {
+			return written, err
+		}
 	}
+
+	// reader is wrapped to hide WriteTo method from io.CopyBuffer
+	return copyBuffered(w, struct{ io.Reader }{reader})
 }
 
 // Write operation is not supported. For interface compatibility only.
@@ -333,23 +2049,56 @@ func (reader *embedFileReader) Name() string {
 }
 
 // Close closes previously opened file. For interface compatibility only.
+//
+// Origin is shared between all opened files, so it's not closed; use Close
+// method of embedfs itself to close it.
 func (reader *embedFileReader) Close() error {
-	return reader.source.Close()
+	reader.fs.options.Hooks.closed(reader.name)
+
+	return nil
 }
 
-// ReadAt operation is not implemeted yet.
+// ReadAt is standard read function implementation from io.ReaderAt.
 func (reader *embedFileReader) ReadAt(p []byte, off int64) (int, error) {
-	return 0, ErrNotImplemented
+	return io.NewSectionReader(
+		reader.source, reader.start, reader.length,
+	).ReadAt(p, off)
 }
 
-// Seek operation is not implemeted yet.
+// Seek is standard seek function implementation from io.Seeker.
 func (reader *embedFileReader) Seek(offset int64, whence int) (int64, error) {
-	return 0, ErrNotImplemented
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += reader.offset
+	case os.SEEK_END:
+		offset += reader.length
+	default:
+		return 0, ErrInvalidSeek
+	}
+
+	if offset < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	if offset == 0 && reader.hash != nil {
+		reader.hash.Reset()
+		reader.hashed = 0
+	}
+
+	reader.offset = offset
+
+	return offset, nil
 }
 
-// Stat operation is not implemeted yet.
+// Stat returns file info of the embedded file.
 func (reader *embedFileReader) Stat() (os.FileInfo, error) {
-	return nil, ErrNotImplemented
+	header, err := reader.fs.header(reader.entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return header.FileInfo(), nil
 }
 
 // Truncate operation is not supported. For interface compatibility only.
@@ -1,8 +1,17 @@
 package embedfs
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
 	"io/ioutil"
+	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/seletskiy/go-mock-file"
@@ -133,3 +142,1062 @@ func TestCanReadFile(t *testing.T) {
 		t.Fatal("file from embedfs is not equal to actual file")
 	}
 }
+
+func TestCanSkipMalformedEntriesInLenientMode(t *testing.T) {
+	container := mockfile.New("lala4")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	entry, err := fs.lookup("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	// break checksum of the first entry header
+	_, err = container.Seek(entry.offset-blockSize, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!'})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenStrict(container)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corrupted embedfs error, got %v", err)
+	}
+
+	entryErr := &EntryError{}
+	if !errors.As(err, &entryErr) || entryErr.Offset != entry.headerOffset {
+		t.Fatalf("expected error at offset %d, got %v", entry.headerOffset, err)
+	}
+
+	fs, err = OpenLenient(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if fs.IsFileExist("/a/1") {
+		t.Fatal("malformed file </a/1> is exist in embedfs")
+	}
+
+	if !fs.IsFileExist("/b/2") {
+		t.Fatal("file </b/2> is not exist in embedfs")
+	}
+
+	if len(fs.Errors()) != 1 {
+		t.Fatalf("expected one error, got %v", fs.Errors())
+	}
+}
+
+func TestCanLimitEntriesCount(t *testing.T) {
+	container := mockfile.New("lala5")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxEntries: 1},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxEntries: 2, MaxPathDepth: 2},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestCanReadHeadersInCompactMode(t *testing.T) {
+	container := mockfile.New("lala6")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{Compact: true})
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := fs.Open("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Name() != "2" || stat.Size() != 2 {
+		t.Fatalf("unexpected file info: %s, %d bytes", stat.Name(), stat.Size())
+	}
+}
+
+func TestCanOpenLazily(t *testing.T) {
+	container := mockfile.New("lala7")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenLazy(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(fs.files) != 0 {
+		t.Fatal("embedfs index is read before first access")
+	}
+
+	stat, err := fs.Stat("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Size() != 2 {
+		t.Fatalf("unexpected size of </a/1>: %d", stat.Size())
+	}
+}
+
+func TestCanCopyFile(t *testing.T) {
+	container := mockfile.New("lala9")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := fs.Open("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	actual := &bytes.Buffer{}
+
+	_, err = io.Copy(actual, f)
+	if err != nil {
+		panic(err)
+	}
+
+	if actual.String() != "1\n" {
+		t.Fatalf("unexpected contents of </a/1>: %q", actual.String())
+	}
+}
+
+func TestCanCheckErrorsWithErrorsIs(t *testing.T) {
+	_, err := Open(mockfile.New("empty"))
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("expected no footprint error, got %v", err)
+	}
+
+	container := mockfile.New("lala14")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.Open("/missing")
+	if !errors.Is(err, iofs.ErrNotExist) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+
+	pathErr := &iofs.PathError{}
+	if !errors.As(err, &pathErr) || pathErr.Path != "/missing" {
+		t.Fatalf("expected path error for </missing>, got %v", err)
+	}
+}
+
+func TestCanDetectNewerFormat(t *testing.T) {
+	container := mockfile.New("lala15")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := container.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	// signature is followed by 8 bytes of offset; bump version to 001
+	_, err = container.Seek(stat.Size()-8-2, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'1'})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrFormatTooNew) {
+		t.Fatalf("expected format too new error, got %v", err)
+	}
+}
+
+func TestCanReadFileAfterClose(t *testing.T) {
+	container := mockfile.New("lala16")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("unexpected contents of </a/1>")
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("unexpected contents of </b/2>")
+	}
+}
+
+func TestCanListDirSorted(t *testing.T) {
+	container := mockfile.New("lala20")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/b")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{SortedListing: true})
+	if err != nil {
+		panic(err)
+	}
+
+	actual, _ := fs.ListDir("/")
+
+	expected := []string{"/a", "/b"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected listing: %v", actual)
+	}
+}
+
+func TestCanTransformFiles(t *testing.T) {
+	container := mockfile.New("lala24")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Transform: func(path string, r io.Reader) (io.Reader, error) {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+
+			return bytes.NewReader(bytes.Repeat(data, 2)), nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n1\n" {
+		t.Fatal("file </a/1> is not transformed")
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected verification error: %s", err)
+	}
+}
+
+func TestCanLimitEmbeddedSize(t *testing.T) {
+	embedder, err := CreateWithOptions(mockfile.New("lala32"), EmbedOptions{
+		MaxFileSize: 1,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error for large file, got %v", err)
+	}
+
+	embedder, err = CreateWithOptions(mockfile.New("lala33"), EmbedOptions{
+		MaxTotalSize: 3,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/b/2")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error for total size, got %v", err)
+	}
+}
+
+func TestCanHandleDuplicates(t *testing.T) {
+	embed := func(name string, policy DuplicatePolicy) (*EmbedFs, error) {
+		container := mockfile.New(name)
+
+		embedder, err := CreateWithOptions(container, EmbedOptions{
+			Duplicates: policy,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.EmbedFile("_test/a/1", "/file")
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.EmbedFile("_test/b/2", "/file")
+		if err != nil {
+			return nil, err
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		return Open(container)
+	}
+
+	fs, err := embed("lala34", DuplicateOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "2\n" {
+		t.Fatal("last embedded file should win")
+	}
+
+	fs, err = embed("lala35", DuplicateKeepFirst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "1\n" {
+		t.Fatal("first embedded file should be kept")
+	}
+
+	_, err = embed("lala36", DuplicateError)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestCanCleanRequestedPaths(t *testing.T) {
+	container := mockfile.New("lala37")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, path := range []string{"a/1", "//a//1", "/a/1/", "/b/../a/./1"} {
+		if !fs.IsFileExist(path) {
+			t.Fatalf("file <%s> should be found as </a/1>", path)
+		}
+	}
+
+	for _, path := range []string{"../a/1", "/a/../../a/1"} {
+		_, err = fs.Open(path)
+		if !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("expected invalid path error for <%s>, got %v", path, err)
+		}
+
+		_, err = fs.Stat(path)
+		if !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("expected invalid path error for <%s>, got %v", path, err)
+		}
+	}
+}
+
+func TestCanEmbedInDryRunMode(t *testing.T) {
+	container := mockfile.New("lala41")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{DryRun: true})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := container.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Size() != 0 {
+		t.Fatalf("dry run should not write anything, got %d bytes", stat.Size())
+	}
+
+	report := embedder.Report()
+
+	expected := []ReportedFile{
+		{Source: "_test/a/1", Target: "/a/1", Size: 2},
+		{Source: "_test/b/2", Target: "/b/2", Size: 2},
+	}
+
+	if !reflect.DeepEqual(report.Files, expected) || report.TotalSize != 4 {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+}
+
+func TestCanMapPathsOfEmbeddedDirectory(t *testing.T) {
+	container := mockfile.New("lala43")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		MapPath: func(relative string) (string, bool) {
+			if relative == "b/2" {
+				return "", true
+			}
+
+			return "renamed/" + relative, false
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/root")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		panic(err)
+	}
+
+	if !reflect.DeepEqual(files, []string{"/root/renamed/a/1"}) {
+		t.Fatalf("unexpected mapped files: %v", files)
+	}
+}
+
+func TestCanFilterEmbeddedDirectory(t *testing.T) {
+	container := mockfile.New("lala44")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Filter: func(path string, info iofs.FileInfo) bool {
+			return path != "_test/b"
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		panic(err)
+	}
+
+	if !reflect.DeepEqual(files, []string{"/a/1"}) {
+		t.Fatalf("unexpected filtered files: %v", files)
+	}
+}
+
+func TestCanEmbedConcurrently(t *testing.T) {
+	container := mockfile.New("lala48")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	var group sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		group.Add(1)
+
+		go func(i int) {
+			defer group.Done()
+
+			err := embedder.EmbedFile("embedfs.go", fmt.Sprintf("/%d", i))
+			if err != nil {
+				panic(err)
+			}
+		}(i)
+	}
+
+	group.Wait()
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		panic(err)
+	}
+
+	if len(files) != 16 {
+		t.Fatalf("unexpected number of embedded files: %d", len(files))
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanFindFootprintInTrailerWindow(t *testing.T) {
+	container := mockfile.New("lala50")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write(bytes.Repeat([]byte{'!'}, 100))
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("expected no footprint error, got %v", err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{TrailerWindow: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("file </b/2> is not read")
+	}
+}
+
+func TestCanUseCustomSignature(t *testing.T) {
+	container := mockfile.New("lala51")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Signature: "VENDOR~PACK:",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("expected no footprint error, got %v", err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{
+		Signature: "VENDOR~PACK:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read")
+	}
+
+	_, err = CreateWithOptions(container, EmbedOptions{Signature: "short"})
+	if !errors.Is(err, ErrSignatureLen) {
+		t.Fatalf("expected signature length error, got %v", err)
+	}
+}
+
+// cancelingOrigin cancels context on first read, simulating deadline which
+// expires while embedfs index is being read.
+type cancelingOrigin struct {
+	file
+	cancel func()
+}
+
+func (origin cancelingOrigin) ReadAt(p []byte, off int64) (int, error) {
+	origin.cancel()
+
+	return origin.file.ReadAt(p, off)
+}
+
+func TestCanStopOpeningWhenContextIsDone(t *testing.T) {
+	container := mockfile.New("lala68")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err = OpenContext(
+		ctx, cancelingOrigin{file: container, cancel: cancel}, OpenOptions{},
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error, got %v", err)
+	}
+
+	_, err = OpenContext(ctx, container, OpenOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context error, got %v", err)
+	}
+}
+
+// truncatedOrigin pretends that origin ends at specified offset, if it's
+// set.
+type truncatedOrigin struct {
+	file
+	end *int64
+}
+
+func (origin truncatedOrigin) ReadAt(p []byte, off int64) (int, error) {
+	if *origin.end == 0 || off+int64(len(p)) <= *origin.end {
+		return origin.file.ReadAt(p, off)
+	}
+
+	if off >= *origin.end {
+		return 0, io.EOF
+	}
+
+	n, err := origin.file.ReadAt(p[:*origin.end-off], off)
+	if err == nil {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+func TestCanReadWithinEntryBounds(t *testing.T) {
+	container := mockfile.New("lala69")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	var end int64
+
+	fs, err := Open(truncatedOrigin{file: container, end: &end})
+	if err != nil {
+		panic(err)
+	}
+
+	file, err := fs.Open("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	buffer := bytes.Repeat([]byte{'x'}, 4096)
+
+	n, err := file.Read(buffer)
+	if err != nil || n != 2 {
+		t.Fatalf("unexpected read: %d %v", n, err)
+	}
+
+	// bytes of the next header should not be read into the buffer
+	if string(buffer[:n]) != "1\n" ||
+		!bytes.Equal(buffer[n:], bytes.Repeat([]byte{'x'}, 4096-n)) {
+		t.Fatalf("unexpected buffer contents: %q", buffer[:16])
+	}
+
+	n, err = file.Read(buffer)
+	if err != io.EOF || n != 0 {
+		t.Fatalf("unexpected read after end: %d %v", n, err)
+	}
+
+	offset, _, err := fs.Extent("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	end = offset + 1
+
+	file, err = fs.Open("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	n, err = file.Read(buffer)
+	if n != 1 || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected read of truncated entry: %d %v", n, err)
+	}
+}
+
+func TestCanEmbedIntoPlainWriter(t *testing.T) {
+	stream := &bytes.Buffer{}
+	stream.WriteString("binary")
+
+	// pipe hides everything but Write
+	embedder, err := NewWriter(
+		struct{ io.Writer }{stream}, int64(stream.Len()),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	data := stream.Bytes()
+
+	fs, err := OpenReaderAt(
+		bytes.NewReader(data), int64(len(data)), OpenOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from written embedfs")
+	}
+
+	if !bytes.HasPrefix(data, []byte("binary")) {
+		t.Fatal("binary is corrupted")
+	}
+}
+
+func TestCanHandleExistingEmbedfsOnCreate(t *testing.T) {
+	container := NewBuffer("binary", []byte("binary"))
+
+	for _, source := range []string{"_test/a/1", "_test/b/2"} {
+		_, err := container.Seek(0, os.SEEK_END)
+		if err != nil {
+			panic(err)
+		}
+
+		embedder, err := CreateWithOptions(
+			container, EmbedOptions{Existing: ExistingReplace},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = embedder.EmbedFile(source, "/file")
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "2\n" {
+		t.Fatal("existing embedfs is not replaced")
+	}
+
+	err = Truncate(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(container.Bytes()) != "binary" {
+		t.Fatalf("embedfs is stacked: %q", container.Bytes())
+	}
+
+	_, err = container.Seek(0, os.SEEK_END)
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = CreateWithOptions(container, EmbedOptions{Existing: ExistingError})
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanOpenTarAtOffset(t *testing.T) {
+	container := mockfile.New("lala85")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	tarWriter := tar.NewWriter(container)
+
+	for _, header := range []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "./a/", Mode: 0755},
+		{Typeflag: tar.TypeReg, Name: "./a/1", Mode: 0644, Size: 2},
+	} {
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	_, err = tarWriter.Write([]byte("1\n"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fs, err := OpenAt(container, int64(len("binary")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from plain tar")
+	}
+
+	_, err = OpenAt(container, 1<<20)
+	if !errors.Is(err, ErrInvalidOffset) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package embedfstest provides helpers for testing code which uses embedfs
+// against realistic containers without committing binary fixtures.
+package embedfstest
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-embed-fs"
+)
+
+// New embeds directory dir into copy of the running test binary and opens
+// it. Container is removed when test finishes.
+func New(t testing.TB, dir string) *embedfs.EmbedFs {
+	t.Helper()
+
+	fs, cleanup, err := Embed(dir)
+	if err != nil {
+		t.Fatalf(`can't embed <%s> into test container: %s`, dir, err)
+	}
+
+	t.Cleanup(cleanup)
+
+	return fs
+}
+
+// Embed works like New, but can be used from TestMain, where there is no
+// testing.TB. Returned cleanup function closes and removes container.
+func Embed(dir string) (*embedfs.EmbedFs, func(), error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := os.Open(executable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer source.Close()
+
+	container, err := ioutil.TempFile("", "embedfstest")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		container.Close()
+		os.Remove(container.Name())
+	}
+
+	fs, err := embed(source, container, dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return fs, cleanup, nil
+}
+
+func embed(
+	source io.Reader, container *os.File, dir string,
+) (*embedfs.EmbedFs, error) {
+	_, err := io.Copy(container, source)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, err := embedfs.Create(container)
+	if err != nil {
+		return nil, err
+	}
+
+	err = embedder.EmbedDirectory(dir, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return embedfs.Open(container)
+}
@@ -0,0 +1,18 @@
+package embedfstest
+
+import (
+	"testing"
+)
+
+func TestCanOpenEmbeddedTestdata(t *testing.T) {
+	fs := New(t, "../_test")
+
+	contents, err := fs.ReadFile("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "1\n" {
+		t.Fatalf("unexpected contents: %q", contents)
+	}
+}
@@ -0,0 +1,397 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+const (
+	paxEncryption = paxPrefix + "encryption"
+	paxNonce      = paxPrefix + "nonce"
+	paxPlainSize  = paxPrefix + "plain-size"
+
+	// paxSealedChecksum holds checksum of decrypted data sealed by the same
+	// key, so checksum of file can't be used to guess its contents.
+	paxSealedChecksum = paxPrefix + "sealed-sha256"
+)
+
+// encryptionAES is the only supported encryption: data is split into chunks
+// of encryptionChunk bytes, and every chunk is sealed by AES-256-GCM with
+// nonce derived from nonce of the file and index of the chunk, so files
+// can be read at arbitrary offsets.
+//
+// Like in STREAM construction, name of the file, size of decrypted data and
+// flag of the last chunk are authenticated as additional data of every
+// chunk, so chunks can't be moved between files, and file can't be
+// truncated or extended unnoticed.
+const (
+	encryptionAES   = "aes-256-gcm"
+	encryptionChunk = 64 * 1024
+
+	// encryptionOverhead is the size of GCM tag appended to every chunk.
+	encryptionOverhead = 16
+
+	// sealedIndex is the index of chunk, which nonce is used to seal PAX
+	// records; there can't be that many chunks of data.
+	sealedIndex = -1
+)
+
+var ErrNoKey = errors.New("no key to decrypt file")
+
+// encryptedData is the source of decrypted data of encrypted entry.
+type encryptedData struct {
+	source io.ReaderAt
+	offset int64
+	size   int64
+	nonce  []byte
+	name   string
+	aead   cipher.AEAD
+
+	// chunk is the last decrypted chunk with index chunkIndex.
+	mutex      sync.Mutex
+	chunk      []byte
+	chunkIndex int64
+}
+
+// newAEAD returns AES-256-GCM cipher for specified key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf(
+			`encryption key should be 32 bytes long, got %d bytes`, len(key),
+		)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypts returns true if file embedded under specified name matches
+// patterns specified in EmbedOptions.Encrypt.
+func (e *Embedder) encrypts(name string) bool {
+	for _, pattern := range e.options.Encrypt {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encrypt reads whole content and returns it encrypted, storing parameters
+// of encryption in PAX records of the header.
+func (e *Embedder) encrypt(
+	tarHeader *tar.Header, content io.ReadSeeker,
+) (io.ReadSeeker, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := content.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := content.Seek(0, os.SEEK_END)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = content.Seek(start, os.SEEK_SET)
+	if err != nil {
+		return nil, err
+	}
+
+	// name is authenticated in the same form as it's read by EmbedFs
+	name := path.Clean("/" + tarHeader.Name)
+	size := end - start
+
+	encrypted := &bytes.Buffer{}
+	chunk := make([]byte, encryptionChunk)
+
+	// empty file still has one chunk, so it's authenticated too
+	for index := int64(0); ; index++ {
+		length := chunkLength(size, index)
+
+		_, err := io.ReadFull(content, chunk[:length])
+		if err != nil {
+			return nil, err
+		}
+
+		last := isLastChunk(size, index)
+
+		encrypted.Write(e.aead.Seal(
+			nil, chunkNonce(nonce, index), chunk[:length],
+			chunkData(name, size, last),
+		))
+
+		if last {
+			break
+		}
+	}
+
+	if tarHeader.PAXRecords == nil {
+		tarHeader.PAXRecords = map[string]string{}
+	}
+
+	checksum, ok := tarHeader.PAXRecords[paxChecksum]
+	if ok {
+		delete(tarHeader.PAXRecords, paxChecksum)
+
+		tarHeader.PAXRecords[paxSealedChecksum] = hex.EncodeToString(
+			e.aead.Seal(
+				nil, chunkNonce(nonce, sealedIndex), []byte(checksum),
+				chunkData(name, size, false),
+			),
+		)
+	}
+
+	tarHeader.PAXRecords[paxEncryption] = encryptionAES
+	tarHeader.PAXRecords[paxNonce] = hex.EncodeToString(nonce)
+	tarHeader.PAXRecords[paxPlainSize] = strconv.FormatInt(size, 10)
+	tarHeader.Size = int64(encrypted.Len())
+
+	return bytes.NewReader(encrypted.Bytes()), nil
+}
+
+// attachEncryption sets up decryption of the entry, if it's encrypted.
+// Size in the header is changed to the size of decrypted data and offset
+// of entry points to its beginning.
+func (fs *EmbedFs) attachEncryption(
+	entry *embedFsEntry, header *tar.Header,
+) error {
+	method, ok := header.PAXRecords[paxEncryption]
+	if !ok {
+		return nil
+	}
+
+	if method != encryptionAES {
+		return fmt.Errorf(
+			`%w: unsupported encryption <%s>`, ErrNotImplemented, method,
+		)
+	}
+
+	size, err := strconv.ParseInt(header.PAXRecords[paxPlainSize], 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf(`%w: invalid size of encrypted file`, ErrCorrupted)
+	}
+
+	encrypted, err := fs.newEncryptedData(
+		fs.originOf(entry), entry.offset, size, header.PAXRecords[paxNonce],
+		entry.name,
+	)
+	if err != nil {
+		return err
+	}
+
+	header.Size = size
+	entry.offset = 0
+	entry.size = size
+	entry.encrypted = encrypted
+
+	encrypted.unsealRecords(header)
+
+	return nil
+}
+
+// unsealRecords restores records sealed by encrypt in the header, if key is
+// known. Records which can't be unsealed are left out, so wrong key is
+// reported when file is read.
+func (data *encryptedData) unsealRecords(header *tar.Header) {
+	sealed, ok := header.PAXRecords[paxSealedChecksum]
+	if !ok || data.aead == nil {
+		return
+	}
+
+	ciphertext, err := hex.DecodeString(sealed)
+	if err != nil {
+		return
+	}
+
+	checksum, err := data.aead.Open(
+		nil, chunkNonce(data.nonce, sealedIndex), ciphertext,
+		chunkData(data.name, data.size, false),
+	)
+	if err != nil {
+		return
+	}
+
+	header.PAXRecords[paxChecksum] = string(checksum)
+}
+
+func (fs *EmbedFs) newEncryptedData(
+	source io.ReaderAt, offset int64, size int64, nonce string, name string,
+) (*encryptedData, error) {
+	encrypted := &encryptedData{
+		source:     source,
+		offset:     offset,
+		size:       size,
+		name:       name,
+		chunkIndex: -1,
+	}
+
+	var err error
+
+	encrypted.nonce, err = hex.DecodeString(nonce)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: invalid nonce of encrypted file`,
+			ErrCorrupted)
+	}
+
+	if fs.options.DecryptionKey == nil {
+		return encrypted, nil
+	}
+
+	encrypted.aead, err = newAEAD(fs.options.DecryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted.nonce) != encrypted.aead.NonceSize() {
+		return nil, fmt.Errorf(`%w: invalid nonce of encrypted file`,
+			ErrCorrupted)
+	}
+
+	return encrypted, nil
+}
+
+// ReadAt reads decrypted data starting from specified offset.
+func (data *encryptedData) ReadAt(p []byte, off int64) (int, error) {
+	if data.aead == nil {
+		return 0, ErrNoKey
+	}
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	read := 0
+
+	for read < len(p) {
+		position := off + int64(read)
+		if position >= data.size {
+			return read, io.EOF
+		}
+
+		index := position / encryptionChunk
+
+		err := data.decryptChunk(index)
+		if err != nil {
+			return read, err
+		}
+
+		read += copy(p[read:], data.chunk[position-index*encryptionChunk:])
+	}
+
+	return read, nil
+}
+
+// decryptChunk reads and decrypts chunk with specified index, unless it's
+// already decrypted.
+func (data *encryptedData) decryptChunk(index int64) error {
+	if data.chunkIndex == index {
+		return nil
+	}
+
+	overhead := int64(data.aead.Overhead())
+	length := chunkLength(data.size, index)
+
+	sealed := make([]byte, length+overhead)
+
+	_, err := data.source.ReadAt(
+		sealed, data.offset+index*(encryptionChunk+overhead),
+	)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	data.chunk, err = data.aead.Open(
+		data.chunk[:0], chunkNonce(data.nonce, index), sealed,
+		chunkData(data.name, data.size, isLastChunk(data.size, index)),
+	)
+	if err != nil {
+		data.chunkIndex = -1
+
+		return fmt.Errorf(
+			`%w: can't decrypt chunk %d: %s`, ErrCorrupted, index, err,
+		)
+	}
+
+	data.chunkIndex = index
+
+	return nil
+}
+
+// sealedSize returns size of encrypted data as it's stored in origin.
+func (data *encryptedData) sealedSize() int64 {
+	chunks := (data.size + encryptionChunk - 1) / encryptionChunk
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	return data.size + chunks*encryptionOverhead
+}
+
+// chunkNonce returns nonce of the chunk with specified index, which is the
+// nonce of the file with last 8 bytes XORed with index.
+func chunkNonce(nonce []byte, index int64) []byte {
+	result := make([]byte, len(nonce))
+	copy(result, nonce)
+
+	tail := result[len(result)-8:]
+	binary.BigEndian.PutUint64(
+		tail, binary.BigEndian.Uint64(tail)^uint64(index),
+	)
+
+	return result
+}
+
+// chunkLength returns length of decrypted data of chunk with specified
+// index of file of specified size.
+func chunkLength(size int64, index int64) int64 {
+	length := size - index*encryptionChunk
+	if length > encryptionChunk {
+		length = encryptionChunk
+	}
+
+	return length
+}
+
+// isLastChunk returns true if chunk with specified index is the last chunk
+// of file of specified size.
+func isLastChunk(size int64, index int64) bool {
+	return (index+1)*encryptionChunk >= size
+}
+
+// chunkData returns additional data, which is authenticated along with the
+// chunk: name of the file, size of decrypted data and flag of the last
+// chunk.
+func chunkData(name string, size int64, last bool) []byte {
+	data := make([]byte, len(name)+1+8+1)
+
+	copy(data, name)
+	binary.BigEndian.PutUint64(data[len(name)+1:], uint64(size))
+
+	if last {
+		data[len(data)-1] = 1
+	}
+
+	return data
+}
@@ -0,0 +1,331 @@
+package embedfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanEncryptSelectedFiles(t *testing.T) {
+	big, err := ioutil.TempFile("", "embedfs-encrypt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(big.Name())
+
+	secret := bytes.Repeat([]byte("top secret "), 20000)
+
+	_, err = big.Write(secret)
+	if err != nil {
+		panic(err)
+	}
+
+	big.Close()
+
+	key := bytes.Repeat([]byte{42}, 32)
+
+	container := mockfile.New("lala80")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Encrypt:       []string{"/b/**"},
+		EncryptionKey: key,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(big.Name(), "/b/big")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	raw, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if bytes.Contains(raw, []byte("top secret")) {
+		t.Fatal("file </b/big> is stored in the clear")
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{DecryptionKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("encrypted file </b/2> is not decrypted")
+	}
+
+	if !bytes.Equal(fs.MustReadFile("/b/big"), secret) {
+		t.Fatal("encrypted file </b/big> is not decrypted")
+	}
+
+	stat, err := fs.Stat("/b/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Size() != int64(len(secret)) {
+		t.Fatalf("unexpected size of encrypted file: %d", stat.Size())
+	}
+
+	section, err := fs.SectionReader("/b/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// range crosses boundary of encrypted chunks
+	chunk := make([]byte, 100)
+
+	_, err = section.ReadAt(chunk, encryptionChunk-50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(chunk, secret[encryptionChunk-50:encryptionChunk+50]) {
+		t.Fatal("unexpected data read at chunk boundary")
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(locked.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("public file </a/1> is not read without key")
+	}
+
+	_, err = locked.ReadFile("/b/2")
+	if !errors.Is(err, ErrNoKey) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wrong, err := OpenWithOptions(container, OpenOptions{
+		DecryptionKey: bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := wrong.Open("/b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ioutil.ReadAll(file)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanNotMoveOrTruncateEncryptedFile(t *testing.T) {
+	big, err := ioutil.TempFile("", "embedfs-encrypt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(big.Name())
+
+	_, err = big.Write(bytes.Repeat([]byte("x"), 2*encryptionChunk))
+	if err != nil {
+		panic(err)
+	}
+
+	big.Close()
+
+	key := bytes.Repeat([]byte{42}, 32)
+
+	container := mockfile.New("lala93")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Encrypt:       []string{"/**"},
+		EncryptionKey: key,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(big.Name(), "/big")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{DecryptionKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := fs.lookup("/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := entry.encrypted
+	nonce := hex.EncodeToString(encrypted.nonce)
+
+	for _, tampered := range []struct {
+		name string
+		size int64
+	}{
+		{"/other", encrypted.size},
+		{"/big", encryptionChunk},
+	} {
+		data, err := fs.newEncryptedData(
+			encrypted.source, encrypted.offset, tampered.size, nonce,
+			tampered.name,
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = data.ReadAt(make([]byte, 1), 0)
+		if !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("unexpected error for %+v: %v", tampered, err)
+		}
+	}
+}
+
+func TestCanNotFindChecksumOfEncryptedFile(t *testing.T) {
+	secret, err := ioutil.TempFile("", "embedfs-encrypt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(secret.Name())
+
+	_, err = secret.WriteString("hunter2")
+	if err != nil {
+		panic(err)
+	}
+
+	secret.Close()
+
+	key := bytes.Repeat([]byte{42}, 32)
+
+	container := mockfile.New("lala96")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Encrypt:       []string{"/secrets/**"},
+		EncryptionKey: key,
+		Fingerprint:   []string{"*"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(secret.Name(), "/secrets/password")
+	if err != nil {
+		panic(err)
+	}
+
+	sums := &bytes.Buffer{}
+
+	err = embedder.WriteSHA256Sums(sums)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	hash := sha256.Sum256([]byte("hunter2"))
+	plainHash := hex.EncodeToString(hash[:])
+
+	container.Seek(0, 0)
+
+	raw, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if bytes.Contains(raw, []byte(plainHash)) ||
+		bytes.Contains(raw, []byte(plainHash[:fingerprintLen])) ||
+		bytes.Contains(sums.Bytes(), []byte(plainHash)) {
+		t.Fatal("checksum of encrypted file is stored in the clear")
+	}
+
+	locked, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := locked.Header("/secrets/password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range header.PAXRecords {
+		if strings.Contains(value, plainHash) {
+			t.Fatalf("checksum of encrypted file is in record <%s>", key)
+		}
+	}
+
+	manifest := &bytes.Buffer{}
+
+	err = locked.ManifestJSON(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(manifest.Bytes(), []byte(plainHash)) {
+		t.Fatal("checksum of encrypted file is written to manifest")
+	}
+
+	// checksum is still available with the key
+	for _, compact := range []bool{false, true} {
+		fs, err := OpenWithOptions(container, OpenOptions{
+			DecryptionKey: key,
+			Compact:       compact,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, err := fs.Header("/secrets/password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.PAXRecords[paxChecksum] != plainHash {
+			t.Fatalf("checksum is not unsealed: %v", header.PAXRecords)
+		}
+
+		err = fs.VerifyAll(VerifyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
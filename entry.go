@@ -0,0 +1,142 @@
+package embedfs
+
+import (
+	"archive/tar"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry describes single file in embedded fs.
+type Entry struct {
+	fs    *EmbedFs
+	entry *embedFsEntry
+}
+
+// Name returns full path of the file in embedded fs.
+func (entry Entry) Name() string {
+	return entry.entry.name
+}
+
+// Size returns size of the file in bytes.
+func (entry Entry) Size() int64 {
+	return entry.entry.size
+}
+
+// Stat returns file info of the file.
+func (entry Entry) Stat() (os.FileInfo, error) {
+	header, err := entry.fs.header(entry.entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return header.FileInfo(), nil
+}
+
+// Header returns copy of tar header of the file, so fields not available via
+// FileInfo, like owner names, device numbers or PAX records, can be read.
+func (entry Entry) Header() (*tar.Header, error) {
+	header, err := entry.fs.header(entry.entry)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := *header
+	clone.PAXRecords = make(map[string]string, len(header.PAXRecords))
+
+	for key, value := range header.PAXRecords {
+		clone.PAXRecords[key] = value
+	}
+
+	return &clone, nil
+}
+
+// Header returns copy of tar header of the specified file.
+//
+// If several files were embedded under the same name, header of the last
+// embedded one is returned.
+func (fs *EmbedFs) Header(path string) (*tar.Header, error) {
+	name, err := cleanPath(path)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "header", Path: path, Err: err}
+	}
+
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "header", Path: path, Err: err}
+	}
+
+	return Entry{fs: fs, entry: entry}.Header()
+}
+
+// Headers calls fn with copy of tar header of every file in embedded fs in
+// the same order as List does.
+func (fs *EmbedFs) Headers(fn func(*tar.Header) error) error {
+	return fs.List("/", func(entry Entry) error {
+		header, err := entry.Header()
+		if err != nil {
+			return err
+		}
+
+		return fn(header)
+	})
+}
+
+// List calls fn for every file located under specified path in the same
+// order as ListDir returns them, without building the whole list in memory.
+//
+// If fn returns error, listing stops and error is returned, unless it's
+// fs.SkipAll, which just stops listing.
+func (fs *EmbedFs) List(path string, fn func(Entry) error) error {
+	return fs.list(path, false, fn)
+}
+
+// list works like List, but also lists hidden files if hidden is true.
+func (fs *EmbedFs) list(
+	path string, hidden bool, fn func(Entry) error,
+) error {
+	err := fs.load()
+	if err != nil {
+		return err
+	}
+
+	entries := fs.files
+	if fs.options.SortedListing {
+		entries = fs.index
+	}
+
+	prefix := filepath.Join("/", path)
+
+	for _, entry := range entries {
+		rootName := filepath.Join("/", entry.name)
+		if !isUnder(rootName, prefix) {
+			continue
+		}
+
+		if entry.hidden && !hidden {
+			continue
+		}
+
+		err := fn(Entry{fs: fs, entry: entry})
+		if err == iofs.SkipAll {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isUnder returns true if name is the same as directory dir or is located
+// under it, so /ab is not treated as located under /a.
+func isUnder(name, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+
+	return name == dir || strings.HasPrefix(name, dir+"/")
+}
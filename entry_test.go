@@ -0,0 +1,150 @@
+package embedfs
+
+import (
+	"archive/tar"
+	iofs "io/fs"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanListWithCallback(t *testing.T) {
+	container := mockfile.New("lala21")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	listed := []Entry{}
+
+	err = fs.List("/", func(entry Entry) error {
+		listed = append(listed, entry)
+		return iofs.SkipAll
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if len(listed) != 1 || listed[0].Name() != "/a/1" {
+		t.Fatalf("unexpected listing: %v", listed)
+	}
+
+	if listed[0].Size() != 2 {
+		t.Fatalf("unexpected size of </a/1>: %d", listed[0].Size())
+	}
+}
+
+func TestCanGetContentType(t *testing.T) {
+	container := mockfile.New("lala22")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("embedfs.go", "/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	contentTypes := []string{}
+
+	err = fs.List("/", func(entry Entry) error {
+		contentType, err := entry.ContentType()
+		contentTypes = append(contentTypes, contentType)
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if contentTypes[1] != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content types: %v", contentTypes)
+	}
+}
+
+func TestCanGetRawHeaders(t *testing.T) {
+	container := mockfile.New("lala63")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	header, err := fs.Header("a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Name != "/a/1" || header.PAXRecords[paxChecksum] == "" {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+
+	// returned header is a copy, so it can't corrupt embedfs
+	header.PAXRecords[paxChecksum] = "changed"
+
+	err = fs.Verify("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+
+	err = fs.Headers(func(header *tar.Header) error {
+		names = append(names, header.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 2 || names[0] != "/a/1" || names[1] != "/b/2" {
+		t.Fatalf("unexpected headers: %v", names)
+	}
+}
@@ -0,0 +1,72 @@
+package embedfs
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// EntryError describes failure which happened while reading embedfs data,
+// pointing to the place in container where it happened.
+type EntryError struct {
+	// Name of entry, if it's known.
+	Name string
+
+	// Offset in the container file where failure happened.
+	Offset int64
+
+	// Err is the underlying cause.
+	Err error
+}
+
+func (err *EntryError) Error() string {
+	if err.Name == "" {
+		return fmt.Sprintf("embedfs: at offset %d: %s", err.Offset, err.Err)
+	}
+
+	return fmt.Sprintf(
+		"embedfs: entry <%s> at offset %d: %s", err.Name, err.Offset, err.Err,
+	)
+}
+
+func (err *EntryError) Unwrap() error {
+	return err.Err
+}
+
+// FormatVersionError is returned when embedfs was written in format newer
+// than this library understands. It matches ErrFormatTooNew.
+type FormatVersionError struct {
+	// Version of found embedfs.
+	Version int
+
+	// Supported is the latest version supported by the library.
+	Supported int
+}
+
+func (err *FormatVersionError) Error() string {
+	return fmt.Sprintf(
+		"embedfs: format version %03d is newer than supported %03d, "+
+			"please upgrade", err.Version, err.Supported,
+	)
+}
+
+func (err *FormatVersionError) Is(target error) bool {
+	return target == ErrFormatTooNew
+}
+
+// signatureError returns error, which describes why found signature is not
+// valid: either it's not embedfs signature at all or it's newer version.
+func signatureError(found [signatureLen]byte) error {
+	const prefixLen = signatureLen - 4
+
+	if string(found[:prefixLen]) != string(signature[:prefixLen]) ||
+		found[signatureLen-1] != signature[signatureLen-1] {
+		return ErrNoFootprint
+	}
+
+	version, err := strconv.Atoi(string(found[prefixLen : signatureLen-1]))
+	if err != nil || version <= formatVersion {
+		return ErrNoFootprint
+	}
+
+	return &FormatVersionError{Version: version, Supported: formatVersion}
+}
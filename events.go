@@ -0,0 +1,56 @@
+package embedfs
+
+// EventKind specifies what happened with file during embedding or
+// extraction.
+type EventKind int
+
+const (
+	// EventStarted is emitted before file is processed.
+	EventStarted EventKind = iota
+
+	// EventSkipped is emitted when file is not processed, Reason describes
+	// why.
+	EventSkipped
+
+	// EventFinished is emitted after file is processed, Bytes holds size of
+	// written data.
+	EventFinished
+)
+
+// Event describes progress of embedding or extraction of single file.
+//
+// For embedding Source is the path to the source file and Target is the
+// name in embedded fs; for extraction Source is the name in embedded fs and
+// Target is the path to the extracted file.
+type Event struct {
+	Kind   EventKind
+	Source string
+	Target string
+	Reason string
+	Bytes  int64
+}
+
+func (kind EventKind) String() string {
+	switch kind {
+	case EventStarted:
+		return "started"
+	case EventSkipped:
+		return "skipped"
+	case EventFinished:
+		return "finished"
+	}
+
+	return "unknown"
+}
+
+func (options EmbedOptions) emit(event Event) {
+	if options.Events != nil {
+		options.Events(event)
+	}
+}
+
+func (options ExtractOptions) emit(event Event) {
+	if options.Events != nil {
+		options.Events(event)
+	}
+}
@@ -0,0 +1,89 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanReceiveEvents(t *testing.T) {
+	container := mockfile.New("lala42")
+
+	events := []Event{}
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Duplicates: DuplicateKeepFirst,
+		Events: func(event Event) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, source := range []string{"_test/a/1", "_test/b/2"} {
+		err = embedder.EmbedFile(source, "/file")
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	expected := []Event{
+		{Kind: EventStarted, Source: "_test/a/1", Target: "/file"},
+		{Kind: EventFinished, Source: "_test/a/1", Target: "/file", Bytes: 2},
+		{Kind: EventStarted, Source: "_test/b/2", Target: "/file"},
+		{
+			Kind:   EventSkipped,
+			Source: "_test/b/2",
+			Target: "/file",
+			Reason: "file with the same name is already embedded",
+		},
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("unexpected embedding events: %+v", events)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-events")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	events = []Event{}
+
+	err = fs.ExtractAll(dir, ExtractOptions{
+		Events: func(event Event) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	target := filepath.Join(dir, "file")
+
+	expected = []Event{
+		{Kind: EventStarted, Source: "/file", Target: target},
+		{Kind: EventFinished, Source: "/file", Target: target, Bytes: 2},
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("unexpected extraction events: %+v", events)
+	}
+}
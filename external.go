@@ -0,0 +1,180 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// externalData is the source of data of entry which is stored in external
+// file next to origin or is downloaded from URL. File is opened, and
+// downloaded if needed, on first read.
+type externalData struct {
+	reference string
+	path      string
+	size      int64
+
+	// url and hash are set for entries embedded by EmbedURL.
+	url    string
+	hash   string
+	client *http.Client
+
+	once sync.Once
+	file *os.File
+	err  error
+}
+
+// EmbedExternal embeds reference to the specified file instead of its
+// contents. Path and SHA-256 checksum of the file are stored in embedded fs,
+// and data is read from the file with the same name located next to the
+// origin, so rarely used huge files don't bloat the origin.
+//
+// File should be shipped along with the origin; it's verified by Verify
+// like any other embedded file.
+func (e *Embedder) EmbedExternal(path string, target string) error {
+	e.options.emit(Event{Kind: EventStarted, Source: path, Target: target})
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tarHeader, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+
+	sourceFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer sourceFile.Close()
+
+	head := make([]byte, sniffLen)
+
+	headLen, err := io.ReadFull(sourceFile, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	hash := sha256.New()
+	hash.Write(head[:headLen])
+
+	_, err = copyBuffered(hash, sourceFile)
+	if err != nil {
+		return err
+	}
+
+	tarHeader.Size = 0
+	tarHeader.PAXRecords = map[string]string{
+		paxChecksum:     hex.EncodeToString(hash.Sum(nil)),
+		paxContentType:  detectContentType(target, head[:headLen]),
+		paxExternal:     filepath.Base(path),
+		paxExternalSize: strconv.FormatInt(stat.Size(), 10),
+	}
+
+	return e.embedContent(tarHeader, path, target, nil)
+}
+
+// attachExternal sets up external data source for the entry, if it's
+// referenced by PAX records of the header. Size in the header is changed to
+// the size of external file and offset of entry points to its beginning.
+func (fs *EmbedFs) attachExternal(
+	entry *embedFsEntry, header *tar.Header,
+) error {
+	reference, ok := header.PAXRecords[paxExternal]
+	if !ok {
+		reference, ok = header.PAXRecords[paxURL]
+	}
+
+	if !ok {
+		return nil
+	}
+
+	size, err := strconv.ParseInt(header.PAXRecords[paxExternalSize], 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf(
+			`%w: invalid size of external file <%s>`, ErrCorrupted, reference,
+		)
+	}
+
+	header.Size = size
+	entry.offset = 0
+	entry.size = size
+	if _, remote := header.PAXRecords[paxURL]; remote {
+		entry.external, err = fs.newRemoteData(
+			reference, header.PAXRecords[paxChecksum], size,
+		)
+	} else {
+		entry.external = fs.newExternalData(reference, size)
+	}
+
+	return err
+}
+
+func (fs *EmbedFs) newExternalData(
+	reference string, size int64,
+) *externalData {
+	dir := ""
+	if origin, ok := fs.origin.(namedFile); ok {
+		dir = filepath.Dir(origin.Name())
+	}
+
+	return &externalData{
+		reference: reference,
+		path:      filepath.Join(dir, filepath.Base(reference)),
+		size:      size,
+	}
+}
+
+// ReadAt reads data from the external file, opening it first time.
+func (data *externalData) ReadAt(p []byte, off int64) (int, error) {
+	data.once.Do(data.open)
+	if data.err != nil {
+		return 0, data.err
+	}
+
+	return data.file.ReadAt(p, off)
+}
+
+func (data *externalData) open() {
+	if data.url != "" {
+		data.file, data.err = data.download()
+	} else {
+		data.file, data.err = os.Open(data.path)
+	}
+
+	if data.err != nil {
+		return
+	}
+
+	stat, err := data.file.Stat()
+	if err != nil {
+		data.err = err
+		return
+	}
+
+	if stat.Size() != data.size {
+		data.err = fmt.Errorf(
+			`%w: external file <%s> is %d bytes, expected %d bytes`,
+			ErrCorrupted, data.path, stat.Size(), data.size,
+		)
+	}
+}
+
+// Close closes external file, if it was opened.
+func (data *externalData) Close() error {
+	if data.file == nil {
+		return nil
+	}
+
+	return data.file.Close()
+}
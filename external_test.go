@@ -0,0 +1,84 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanEmbedExternalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-external")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	asset := filepath.Join(dir, "asset.bin")
+
+	err = ioutil.WriteFile(asset, []byte("external\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	container, err := os.Create(filepath.Join(dir, "app"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedExternal(asset, "/asset.bin")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{Compact: true})
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := fs.Stat("/asset.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Size() != 9 {
+		t.Fatalf("unexpected size of external file: %d", stat.Size())
+	}
+
+	if string(fs.MustReadFile("/asset.bin")) != "external\n" {
+		t.Fatal("external file is not read")
+	}
+
+	err = fs.Verify("/asset.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(asset, []byte("changed\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err = Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.ReadFile("/asset.bin")
+	if err == nil {
+		t.Fatal("changed external file should not be read")
+	}
+}
@@ -0,0 +1,486 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractOptions holds settings which are used by Extract.
+type ExtractOptions struct {
+	// Parallelism specifies how many files can be extracted concurrently.
+	// Zero value means sequential extraction.
+	Parallelism int
+
+	// RateLimit limits total bandwidth of extraction in bytes per second.
+	// Zero value means no limit.
+	RateLimit int64
+
+	// Umask is cleared from permission bits of extracted files and
+	// directories. Stored modes are restored as is otherwise, regardless of
+	// umask of the process.
+	Umask os.FileMode
+
+	// ClearSetuid clears setuid and setgid bits of extracted files.
+	ClearSetuid bool
+
+	// Chown changes owner of extracted files to uid and gid stored in
+	// embedded fs. It's ignored unless process is running as root.
+	Chown bool
+
+	// MapAttributes, if not nil, is called for every extracted file with
+	// its path in embedded fs and attributes after applying other options,
+	// and returns attributes which should be actually set.
+	MapAttributes func(name string, attributes Attributes) Attributes
+
+	// Xattrs enables restoring of extended attributes stored in embedded fs.
+	// Supported only on Linux.
+	Xattrs bool
+
+	// LinkDuplicates makes files with the same contents, mode and owner to
+	// be extracted as hardlinks to the first of them. Hardlinks stored in
+	// embedded fs are always recreated as hardlinks, if their targets are
+	// extracted too.
+	LinkDuplicates bool
+
+	// Events, if not nil, is called when extraction of file is started or
+	// finished. It's called concurrently when Parallelism is specified.
+	Events func(Event)
+
+	// Atomic makes regular files to be written to temporary files first,
+	// which are renamed to target paths after contents and attributes are
+	// written, so readers never observe partially extracted files.
+	Atomic bool
+}
+
+// Attributes describes mode and ownership of extracted file.
+type Attributes struct {
+	Mode os.FileMode
+	Uid  int
+	Gid  int
+}
+
+// Extract writes all files from embedded fs which are located under
+// specified prefix into directory dir, preserving their paths relative to
+// prefix, modes and modification times. Hardlinks and symlinks are
+// recreated.
+func (fs *EmbedFs) Extract(prefix, dir string, options ExtractOptions) error {
+	return fs.ExtractContext(context.Background(), prefix, dir, options)
+}
+
+// ExtractContext works like Extract, but reports extraction via tracer
+// carried by specified context.
+func (fs *EmbedFs) ExtractContext(
+	ctx context.Context, prefix, dir string, options ExtractOptions,
+) error {
+	names, err := fs.ListDir(prefix)
+	if err != nil {
+		return err
+	}
+
+	names = uniqueNames(names)
+
+	_, span := startSpan(ctx, "embedfs.Extract", map[string]interface{}{
+		"embedfs.prefix":       prefix,
+		"embedfs.files":        len(names),
+		"embedfs.payload_size": fs.sizeOf(names),
+	})
+
+	prefix = path.Clean("/" + prefix)
+	limiter := newRateLimiter(options.RateLimit)
+
+	links, symlinks, err := fs.linkSources(names, options)
+	if err != nil {
+		endSpan(span, err)
+		return err
+	}
+
+	var files, hardlinks, symlinked []string
+	for _, name := range names {
+		switch {
+		case links[name] != "":
+			hardlinks = append(hardlinks, name)
+		case symlinks[name]:
+			symlinked = append(symlinked, name)
+		default:
+			files = append(files, name)
+		}
+	}
+
+	// hardlinks are created after all files are extracted, so their
+	// targets exist; symlinks are created last, so no file is written
+	// through them
+	for _, batch := range [][]string{files, hardlinks, symlinked} {
+		err = forEach(options.Parallelism, batch,
+			func(name string) error {
+				return fs.extractEntry(
+					name, prefix, dir, options, limiter, links,
+				)
+			},
+		)
+		if err != nil {
+			break
+		}
+	}
+
+	endSpan(span, err)
+
+	return err
+}
+
+// linkSources returns map from names of files, which should be extracted as
+// hardlinks, to names of files they should be linked to, and set of names
+// of symlinks.
+func (fs *EmbedFs) linkSources(
+	names []string, options ExtractOptions,
+) (map[string]string, map[string]bool, error) {
+	var (
+		links      = map[string]string{}
+		symlinks   = map[string]bool{}
+		extracted  = map[string]bool{}
+		duplicates = map[string]string{}
+	)
+
+	for _, name := range names {
+		extracted[name] = true
+	}
+
+	for _, name := range names {
+		entry, err := fs.lookup(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		header, err := fs.header(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case header.Typeflag == tar.TypeSymlink:
+			symlinks[name] = true
+
+		case header.Typeflag == tar.TypeLink:
+			source := path.Clean("/" + header.Linkname)
+			if extracted[source] {
+				links[name] = source
+			}
+
+		case options.LinkDuplicates && header.Typeflag == tar.TypeReg:
+			hash := header.PAXRecords[paxChecksum]
+			if hash == "" {
+				continue
+			}
+
+			key := fmt.Sprintf(
+				"%s:%o:%d:%d", hash, header.Mode, header.Uid, header.Gid,
+			)
+
+			source, ok := duplicates[key]
+			if !ok {
+				duplicates[key] = name
+				continue
+			}
+
+			links[name] = source
+		}
+	}
+
+	// links to links are resolved to the files, which are extracted first
+	for name, source := range links {
+		for steps := 0; steps < len(links); steps++ {
+			next, ok := links[source]
+			if !ok {
+				break
+			}
+
+			source = next
+		}
+
+		links[name] = source
+	}
+
+	return links, symlinks, nil
+}
+
+// ExtractFile writes single file from embedded fs to the specified target
+// path, restoring its mode and modification time. Symlinks and hardlinks
+// are handled the same way as in Extract.
+func (fs *EmbedFs) ExtractFile(
+	path, target string, options ExtractOptions,
+) error {
+	name, err := cleanPath(path)
+	if err != nil {
+		return &iofs.PathError{Op: "extract", Path: path, Err: err}
+	}
+
+	return fs.extractEntry(
+		name, name, target, options, newRateLimiter(options.RateLimit), nil,
+	)
+}
+
+// ExtractAll writes all files from embedded fs into directory dir.
+func (fs *EmbedFs) ExtractAll(dir string, options ExtractOptions) error {
+	return fs.Extract("/", dir, options)
+}
+
+// MaterializeTemp extracts files located under specified prefix into newly
+// created temporary directory, so they can be used by programs which
+// require real paths. Returned cleanup function removes the directory.
+func (fs *EmbedFs) MaterializeTemp(
+	prefix string,
+) (dir string, cleanup func(), err error) {
+	dir, err = ioutil.TempDir("", "embedfs-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup = func() {
+		os.RemoveAll(dir)
+	}
+
+	err = fs.Extract(prefix, dir, ExtractOptions{})
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return dir, cleanup, nil
+}
+
+func (fs *EmbedFs) extractEntry(
+	name, prefix, dir string, options ExtractOptions, limiter *rateLimiter,
+	links map[string]string,
+) error {
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	header, err := fs.header(entry)
+	if err != nil {
+		return err
+	}
+
+	target := extractTarget(name, prefix, dir)
+
+	options.emit(Event{Kind: EventStarted, Source: name, Target: target})
+
+	finished := func(bytes int64) error {
+		options.emit(Event{
+			Kind:   EventFinished,
+			Source: name,
+			Target: target,
+			Bytes:  bytes,
+		})
+
+		return nil
+	}
+
+	if source, ok := links[name]; ok {
+		err = extractLink(extractTarget(source, prefix, dir), target)
+		if err != nil {
+			return err
+		}
+
+		return finished(0)
+	}
+
+	if header.Typeflag == tar.TypeSymlink {
+		err = extractSymlink(header.Linkname, target)
+		if err != nil {
+			return err
+		}
+
+		return finished(0)
+	}
+
+	// hardlink to file which is not extracted is extracted as a copy
+	if header.Typeflag == tar.TypeLink {
+		entry, err = fs.lookup(path.Clean("/" + header.Linkname))
+		if err != nil {
+			return err
+		}
+
+		header, err = fs.header(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	attributes := options.attributes(name, header)
+
+	if header.Typeflag == tar.TypeDir {
+		err = os.MkdirAll(target, attributes.Mode.Perm())
+		if err != nil {
+			return err
+		}
+
+		err = options.restoreAttributes(target, attributes)
+		if err != nil {
+			return err
+		}
+
+		return finished(0)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = fs.extractRegular(entry, header, target, options, attributes, limiter)
+	if err != nil {
+		return err
+	}
+
+	return finished(header.Size)
+}
+
+// extractRegular writes regular file along with its attributes and
+// modification time. If Atomic option is set, file is written to temporary
+// file first, which is renamed to target at last.
+func (fs *EmbedFs) extractRegular(
+	entry *embedFsEntry, header *tar.Header, target string,
+	options ExtractOptions, attributes Attributes, limiter *rateLimiter,
+) error {
+	written := target
+
+	if options.Atomic {
+		temp, err := ioutil.TempFile(filepath.Dir(target), ".tmp-extract-")
+		if err != nil {
+			return err
+		}
+
+		err = temp.Close()
+		if err != nil {
+			return err
+		}
+
+		written = temp.Name()
+		defer os.Remove(written)
+	}
+
+	err := fs.extractFile(entry, header, written, limiter)
+	if err != nil {
+		return err
+	}
+
+	err = options.restoreAttributes(written, attributes)
+	if err != nil {
+		return err
+	}
+
+	// xattrs are restored after chown, which drops file capabilities
+	if options.Xattrs {
+		err = restoreXattrs(written, header)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chtimes(written, header.ModTime, header.ModTime)
+	if err != nil {
+		return err
+	}
+
+	if written == target {
+		return nil
+	}
+
+	return os.Rename(written, target)
+}
+
+// extractTarget returns path to which file from embedded fs should be
+// extracted.
+func extractTarget(name, prefix, dir string) string {
+	// name is cleaned as absolute path first, so it can't escape dir
+	relative := strings.TrimPrefix(path.Clean("/"+name), prefix)
+
+	return filepath.Join(dir, filepath.FromSlash(relative))
+}
+
+// extractLink creates hardlink, replacing previously extracted file.
+func extractLink(source, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Link(source, target)
+}
+
+// attributes returns attributes of file which should be set after
+// extraction according to options.
+func (options ExtractOptions) attributes(
+	name string, header *tar.Header,
+) Attributes {
+	mode := header.FileInfo().Mode() &
+		(os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+	if options.ClearSetuid {
+		mode &^= os.ModeSetuid | os.ModeSetgid
+	}
+
+	attributes := Attributes{
+		Mode: mode &^ (options.Umask & os.ModePerm),
+		Uid:  header.Uid,
+		Gid:  header.Gid,
+	}
+
+	if options.MapAttributes != nil {
+		attributes = options.MapAttributes(name, attributes)
+	}
+
+	return attributes
+}
+
+// restoreAttributes sets ownership and mode of extracted file. Owner is
+// changed first, because chown clears setuid bits.
+func (options ExtractOptions) restoreAttributes(
+	target string, attributes Attributes,
+) error {
+	if options.Chown && os.Geteuid() == 0 {
+		err := os.Lchown(target, attributes.Uid, attributes.Gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Chmod(target, attributes.Mode)
+}
+
+func (fs *EmbedFs) extractFile(
+	entry *embedFsEntry, header *tar.Header, target string,
+	limiter *rateLimiter,
+) error {
+	targetFile, err := os.OpenFile(
+		target,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		header.FileInfo().Mode().Perm(),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(
+		targetFile, throttle(fs.newReader(entry, entry.name), limiter),
+	)
+	if err != nil {
+		targetFile.Close()
+		return err
+	}
+
+	return targetFile.Close()
+}
@@ -0,0 +1,393 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanExtractInParallel(t *testing.T) {
+	container := mockfile.New("lala10")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = fs.ExtractAll(dir, ExtractOptions{Parallelism: 4})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range []string{"a/1", "b/2"} {
+		expected, err := ioutil.ReadFile(filepath.Join("_test", name))
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			panic(err)
+		}
+
+		if string(actual) != string(expected) {
+			t.Fatalf("extracted file <%s> differs from original", name)
+		}
+	}
+}
+
+func TestCanExtractWithAttributesOptions(t *testing.T) {
+	source, err := ioutil.TempFile("", "embedfs-setuid")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(source.Name())
+
+	source.Close()
+
+	err = os.Chmod(source.Name(), 0777|os.ModeSetuid)
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala38")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(source.Name(), "/bin/tool")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = fs.ExtractAll(dir, ExtractOptions{ClearSetuid: true, Umask: 022})
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := os.Stat(filepath.Join(dir, "bin/tool"))
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Mode() != 0755 {
+		t.Fatalf("unexpected mode of extracted file: %s", stat.Mode())
+	}
+
+	err = fs.ExtractAll(dir, ExtractOptions{
+		MapAttributes: func(name string, attributes Attributes) Attributes {
+			if name != "/bin/tool" {
+				t.Fatalf("unexpected name passed to callback: %s", name)
+			}
+
+			attributes.Mode = 0600
+
+			return attributes
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err = os.Stat(filepath.Join(dir, "bin/tool"))
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Mode() != 0600 {
+		t.Fatalf("unexpected mode of mapped file: %s", stat.Mode())
+	}
+}
+
+func TestCanExtractHardlinks(t *testing.T) {
+	container := mockfile.New("lala40")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range []string{"/x", "/y"} {
+		err = embedder.EmbedFile("_test/a/1", name)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = embedder.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     "/z",
+		Linkname: "/x",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = fs.ExtractAll(dir, ExtractOptions{
+		Parallelism:    4,
+		LinkDuplicates: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := os.Stat(filepath.Join(dir, "x"))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range []string{"y", "z"} {
+		linked, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !os.SameFile(original, linked) {
+			t.Fatalf("file <%s> is not extracted as hardlink", name)
+		}
+	}
+
+	err = fs.Extract("/z", filepath.Join(dir, "copy"), ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "copy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "1\n" {
+		t.Fatalf("unexpected contents of hardlink copy: %q", data)
+	}
+}
+
+func TestCanExtractSingleFileAtomically(t *testing.T) {
+	container := mockfile.New("lala56")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "config", "1")
+
+	err = fs.ExtractFile("a/1", target, ExtractOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "1\n" {
+		t.Fatalf("unexpected contents: %q", actual)
+	}
+
+	expected, err := fs.Stat("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode() != expected.Mode() ||
+		!stat.ModTime().Equal(expected.ModTime()) {
+		t.Fatalf("unexpected attributes: %s %s", stat.Mode(), stat.ModTime())
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("temporary files are left: %d entries", len(entries))
+	}
+}
+
+func TestCanMaterializeTemp(t *testing.T) {
+	container := mockfile.New("lala58")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, cleanup, err := fs.MaterializeTemp("/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(filepath.Join(dir, "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "2\n" {
+		t.Fatalf("unexpected contents: %q", actual)
+	}
+
+	cleanup()
+
+	_, err = os.Stat(dir)
+	if !os.IsNotExist(err) {
+		t.Fatalf("temporary directory is not removed: %v", err)
+	}
+}
+
+func TestCanExtractWithoutSiblingsSharingPrefix(t *testing.T) {
+	container := mockfile.New("lala88")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/ab/g")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = fs.Extract("/a", dir, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "b", "g"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("sibling </ab/g> is extracted: %v", err)
+	}
+}
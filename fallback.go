@@ -0,0 +1,119 @@
+package embedfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultFallbackMaxFileSize limits size of downloaded file, when
+// FallbackOptions.MaxFileSize is not set.
+const defaultFallbackMaxFileSize = 64 << 20
+
+// FallbackOptions tunes downloading of files missing in primary fs.
+type FallbackOptions struct {
+	// Client is used to download files, nil means http.DefaultClient.
+	Client *http.Client
+
+	// MaxFileSize limits size of downloaded file in bytes, because it's
+	// kept in memory. Zero means 64 MiB.
+	MaxFileSize int64
+}
+
+type fallbackFs struct {
+	primary iofs.FS
+	baseURL string
+	options FallbackOptions
+}
+
+// FallbackFS returns fs.FS, which opens files from primary fs, and
+// downloads files missing there from specified base URL, so files can be
+// hotfixed on CDN after release. Nil client means http.DefaultClient.
+//
+// Downloaded files are kept in memory while opened. Only files can be
+// downloaded, directories are listed from primary fs only.
+func FallbackFS(
+	primary iofs.FS, baseURL string, client *http.Client,
+) iofs.FS {
+	return FallbackFSWithOptions(
+		primary, baseURL, FallbackOptions{Client: client},
+	)
+}
+
+// FallbackFSWithOptions works like FallbackFS, but allows to tune
+// downloading by specified options.
+func FallbackFSWithOptions(
+	primary iofs.FS, baseURL string, options FallbackOptions,
+) iofs.FS {
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	if options.MaxFileSize == 0 {
+		options.MaxFileSize = defaultFallbackMaxFileSize
+	}
+
+	return fallbackFs{
+		primary: primary,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		options: options,
+	}
+}
+
+func (fallback fallbackFs) Open(name string) (iofs.File, error) {
+	file, err := fallback.primary.Open(name)
+	if !errors.Is(err, iofs.ErrNotExist) || !iofs.ValidPath(name) {
+		return file, err
+	}
+
+	data, err := fallback.download(name)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &memoryFile{Reader: bytes.NewReader(data), name: name}, nil
+}
+
+func (fallback fallbackFs) download(name string) ([]byte, error) {
+	response, err := fallback.options.Client.Get(
+		fallback.baseURL + "/" + (&url.URL{Path: name}).EscapedPath(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return readLimited(response.Body, name, fallback.options.MaxFileSize)
+
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, ErrNoExist
+	}
+
+	return nil, fmt.Errorf(`can't fetch <%s>: %s`, name, response.Status)
+}
+
+// readLimited reads whole reader, failing if it has more than maxSize
+// bytes.
+func readLimited(r io.Reader, name string, maxSize int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf(
+			`%w: <%s> is more than %d bytes`, ErrLimitExceeded, name, maxSize,
+		)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,111 @@
+package embedfs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanFallbackToRemoteFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path != "/assets/hotfix.js" {
+				http.NotFound(writer, request)
+				return
+			}
+
+			writer.Write([]byte("hotfix\n"))
+		},
+	))
+
+	defer server.Close()
+
+	container := mockfile.New("lala47")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	fallback := FallbackFS(fs.FS(), server.URL+"/assets", nil)
+
+	data, err := iofs.ReadFile(fallback, "a/1")
+	if err != nil || string(data) != "1\n" {
+		t.Fatalf("embedded file is not read: %q, %v", data, err)
+	}
+
+	data, err = iofs.ReadFile(fallback, "hotfix.js")
+	if err != nil || string(data) != "hotfix\n" {
+		t.Fatalf("remote file is not read: %q, %v", data, err)
+	}
+
+	_, err = iofs.ReadFile(fallback, "missing.js")
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestCanNotFallbackToRemoteFilesLargerThanLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			writer.Write([]byte("hotfix\n"))
+		},
+	))
+
+	defer server.Close()
+
+	container := mockfile.New("lala101")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	fallback := FallbackFSWithOptions(fs.FS(), server.URL, FallbackOptions{
+		MaxFileSize: 7,
+	})
+
+	data, err := iofs.ReadFile(fallback, "hotfix.js")
+	if err != nil || string(data) != "hotfix\n" {
+		t.Fatalf("remote file is not read: %q, %v", data, err)
+	}
+
+	fallback = FallbackFSWithOptions(fs.FS(), server.URL, FallbackOptions{
+		MaxFileSize: 6,
+	})
+
+	_, err = iofs.ReadFile(fallback, "hotfix.js")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected limit exceeded error, got %v", err)
+	}
+}
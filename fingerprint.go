@@ -0,0 +1,96 @@
+package embedfs
+
+import (
+	"encoding/json"
+	"errors"
+	iofs "io/fs"
+	"path"
+	"strings"
+)
+
+const (
+	fingerprintsPath = internalDir + "/fingerprints.json"
+	fingerprintLen   = 8
+)
+
+// fingerprint returns name of file with hash inserted before extension, if
+// file matches patterns specified in EmbedOptions.Fingerprint.
+func (e *Embedder) fingerprint(name string, hash string) string {
+	base := path.Base(name)
+
+	for _, pattern := range e.options.Fingerprint {
+		matched, _ := path.Match(pattern, base)
+		if !matched {
+			continue
+		}
+
+		extension := path.Ext(base)
+		fingerprinted := path.Join(
+			path.Dir(name),
+			strings.TrimSuffix(base, extension)+"."+
+				hash[:fingerprintLen]+extension,
+		)
+
+		e.fingerprints[name] = fingerprinted
+
+		return fingerprinted
+	}
+
+	return name
+}
+
+func (e *Embedder) writeFingerprints() error {
+	if len(e.fingerprints) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(e.fingerprints)
+	if err != nil {
+		return err
+	}
+
+	return e.embedData(fingerprintsPath, data)
+}
+
+// Fingerprinted returns name under which specified file was embedded. For
+// files embedded with fingerprint it's the name containing hash of the
+// contents, for other files it's the same name.
+func (fs *EmbedFs) Fingerprinted(name string) (string, error) {
+	name = path.Clean("/" + name)
+
+	fingerprints, err := fs.fingerprints()
+	if err != nil {
+		return "", err
+	}
+
+	fingerprinted, ok := fingerprints[name]
+	if ok {
+		return fingerprinted, nil
+	}
+
+	_, err = fs.lookup(name)
+	if err != nil {
+		return "", &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return name, nil
+}
+
+func (fs *EmbedFs) fingerprints() (map[string]string, error) {
+	fs.fingerprintsOnce.Do(func() {
+		fs.fingerprintMap = map[string]string{}
+
+		data, err := fs.ReadFile(fingerprintsPath)
+		if err != nil {
+			if !errors.Is(err, ErrNoExist) {
+				fs.fingerprintsErr = err
+			}
+
+			return
+		}
+
+		fs.fingerprintsErr = json.Unmarshal(data, &fs.fingerprintMap)
+	})
+
+	return fs.fingerprintMap, fs.fingerprintsErr
+}
@@ -0,0 +1,56 @@
+package embedfs
+
+import (
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanFingerprintFiles(t *testing.T) {
+	container := mockfile.New("lala23")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Fingerprint: []string{"*.js"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/static/app.js")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/index.html")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	fingerprinted, err := fs.Fingerprinted("/static/app.js")
+	if err != nil {
+		panic(err)
+	}
+
+	if fingerprinted != "/static/app.4355a46b.js" {
+		t.Fatalf("unexpected fingerprinted name: %s", fingerprinted)
+	}
+
+	if !fs.IsFileExist(fingerprinted) {
+		t.Fatalf("file <%s> is not exist in embedfs", fingerprinted)
+	}
+
+	fingerprinted, err = fs.Fingerprinted("/index.html")
+	if err != nil || fingerprinted != "/index.html" {
+		t.Fatalf("unexpected fingerprinted name: %s (%v)", fingerprinted, err)
+	}
+}
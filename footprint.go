@@ -0,0 +1,62 @@
+package embedfs
+
+import (
+	"encoding/binary"
+)
+
+// Footprint describes footer, which is written in the end of embedfs and
+// points to its beginning.
+type Footprint struct {
+	// Signature identifies embedfs format and its version.
+	Signature string
+
+	// Offset is the offset of the beginning of embedfs in origin.
+	Offset int64
+
+	// Location is the offset of footprint itself, which is the end of
+	// embedfs data.
+	Location int64
+
+	// Size is the size of footprint in bytes.
+	Size int64
+}
+
+// ReadFootprint reads footprint written with default signature in the end
+// of origin, so tools can find out where embedfs is placed without opening
+// it. ErrNoFootprint is returned if origin doesn't end with embedfs.
+func ReadFootprint(origin file) (Footprint, error) {
+	stat, err := origin.Stat()
+	if err != nil {
+		return Footprint{}, err
+	}
+
+	footprint, location, err := findFootprint(
+		origin, stat.Size(), 0, signature,
+	)
+	if err != nil {
+		return Footprint{}, err
+	}
+
+	return Footprint{
+		Signature: string(footprint.Signature[:]),
+		Offset:    footprint.Offset,
+		Location:  location,
+		Size:      int64(binary.Size(footprint)),
+	}, nil
+}
+
+// Offset returns offset of the beginning of embedfs in origin.
+func (fs *EmbedFs) Offset() int64 {
+	return fs.offset
+}
+
+// Size returns number of bytes taken by embedfs in origin including its
+// footprint, so data appended after embedfs starts at Offset() + Size().
+// Sidecar volumes and external files are not counted.
+func (fs *EmbedFs) Size() int64 {
+	if fs.bare {
+		return fs.end - fs.offset
+	}
+
+	return fs.end - fs.offset + int64(binary.Size(embedFsFootprint{}))
+}
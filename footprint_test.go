@@ -0,0 +1,65 @@
+package embedfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanReadFootprint(t *testing.T) {
+	container := mockfile.New("lala86")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = ReadFootprint(container)
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := container.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	footprint, err := ReadFootprint(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if footprint.Offset != int64(len("binary")) ||
+		footprint.Location+footprint.Size != stat.Size() ||
+		footprint.Signature != string(signature[:]) {
+		t.Fatalf("unexpected footprint: %+v", footprint)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.Offset() != footprint.Offset ||
+		fs.Offset()+fs.Size() != stat.Size() {
+		t.Fatalf(
+			"embedfs is placed at %d of size %d", fs.Offset(), fs.Size(),
+		)
+	}
+}
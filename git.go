@@ -0,0 +1,176 @@
+package embedfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+type gitHandler struct {
+	fs   *EmbedFs
+	root string
+}
+
+// GitHandler returns HTTP handler which serves bare git repository embedded
+// under specified root using git dumb HTTP protocol, so it can be cloned
+// by `git clone http://...`.
+//
+// If repository was embedded without running `git update-server-info`,
+// info/refs and objects/info/packs files are generated on the fly.
+func GitHandler(fs *EmbedFs, root string) http.Handler {
+	return &gitHandler{
+		fs:   fs,
+		root: path.Clean("/" + root),
+	}
+}
+
+func (handler *gitHandler) ServeHTTP(
+	response http.ResponseWriter, request *http.Request,
+) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		http.Error(
+			response, "method is not allowed", http.StatusMethodNotAllowed,
+		)
+		return
+	}
+
+	name := path.Clean("/" + request.URL.Path)
+
+	response.Header().Set("Content-Type", gitContentType(name))
+
+	// files are served without reading them into memory, as packs can be
+	// huge, and ranges are supported for resumed fetches
+	file, err := handler.fs.Open(path.Join(handler.root, name))
+	if err == nil {
+		defer file.Close()
+
+		var modTime time.Time
+
+		stat, err := file.Stat()
+		if err == nil {
+			modTime = stat.ModTime()
+		}
+
+		http.ServeContent(response, request, name, modTime, file)
+
+		return
+	}
+
+	var data []byte
+
+	switch name {
+	case "/info/refs":
+		data, err = handler.generateRefs()
+	case "/objects/info/packs":
+		data, err = handler.generatePacks()
+	}
+
+	if err != nil || data == nil {
+		http.NotFound(response, request)
+		return
+	}
+
+	http.ServeContent(
+		response, request, name, time.Time{}, bytes.NewReader(data),
+	)
+}
+
+// generateRefs returns contents of info/refs file made from loose refs and
+// packed-refs file.
+func (handler *gitHandler) generateRefs() ([]byte, error) {
+	refs := map[string]string{}
+
+	packed, err := handler.fs.ReadFile(
+		path.Join(handler.root, "packed-refs"),
+	)
+	if err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(packed))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+				continue
+			}
+
+			fields := strings.Fields(line)
+			if len(fields) == 2 {
+				refs[fields[1]] = fields[0]
+			}
+		}
+	}
+
+	err = handler.fs.List(
+		path.Join(handler.root, "refs"),
+		func(entry Entry) error {
+			data, err := handler.fs.ReadFile(entry.Name())
+			if err != nil {
+				return err
+			}
+
+			hash := strings.TrimSpace(string(data))
+			if strings.HasPrefix(hash, "ref:") {
+				return nil
+			}
+
+			refs[strings.TrimPrefix(entry.Name(), handler.root+"/")] = hash
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range refs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	buffer := &bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(buffer, "%s\t%s\n", refs[name], name)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// generatePacks returns contents of objects/info/packs file listing all
+// embedded pack files.
+func (handler *gitHandler) generatePacks() ([]byte, error) {
+	packs, err := handler.fs.Glob(
+		path.Join(handler.root, "objects/pack/*.pack"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	for _, pack := range packs {
+		fmt.Fprintf(buffer, "P %s\n", path.Base(pack))
+	}
+
+	io.WriteString(buffer, "\n")
+
+	return buffer.Bytes(), nil
+}
+
+func gitContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".pack"):
+		return "application/x-git-packed-objects"
+	case strings.HasSuffix(name, ".idx"):
+		return "application/x-git-packed-objects-toc"
+	case strings.HasPrefix(name, "/objects/") &&
+		!strings.HasPrefix(name, "/objects/info/"):
+		return "application/x-git-loose-object"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
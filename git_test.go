@@ -0,0 +1,87 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanServeGitRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-git")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	hash := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+	err = os.MkdirAll(filepath.Join(dir, "refs", "heads"), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "refs", "heads", "master"), []byte(hash+"\n"), 0644,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "HEAD"), []byte("ref: refs/heads/master\n"), 0644,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala27")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory(dir, "/repo.git")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	handler := GitHandler(fs, "/repo.git")
+
+	for url, expected := range map[string]string{
+		"/info/refs?service=git-upload-pack": hash + "\trefs/heads/master\n",
+		"/HEAD":                              "ref: refs/heads/master\n",
+		"/objects/info/packs":                "\n",
+	} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", url, nil))
+
+		if response.Body.String() != expected {
+			t.Fatalf("unexpected response on <%s>: %q", url, response.Body)
+		}
+	}
+	request := httptest.NewRequest("GET", "/HEAD", nil)
+	request.Header.Set("Range", "bytes=5-8")
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != 206 || response.Body.String() != "refs" {
+		t.Fatalf("unexpected response on range: %q", response.Body)
+	}
+}
@@ -0,0 +1,97 @@
+package embedfs
+
+import (
+	"path"
+	"strings"
+)
+
+// Glob returns names of all files in embedded fs matching pattern. Pattern
+// syntax is the same as in path.Match and it's matched against full path.
+func (fs *EmbedFs) Glob(pattern string) ([]string, error) {
+	pattern = path.Clean("/" + pattern)
+
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+
+	err = fs.List("/", func(entry Entry) error {
+		matched, _ := path.Match(pattern, entry.Name())
+		if matched {
+			result = append(result, entry.Name())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueNames(result), nil
+}
+
+// matchPath returns true if name matches pattern, which syntax is the same
+// as in path.Match, except that trailing "/**" matches everything located
+// under the directory, like in "/secrets/**" or "/*/secrets/**".
+func matchPath(pattern, name string) bool {
+	pattern = path.Clean("/" + pattern)
+
+	dir, ok := strings.CutSuffix(pattern, "/**")
+	if !ok {
+		matched, _ := path.Match(pattern, name)
+
+		return matched
+	}
+
+	if dir == "" {
+		dir = "/"
+	}
+
+	// part before "/**" can contain wildcards too, so it's matched against
+	// every directory containing the file
+	for parent := path.Dir(name); ; parent = path.Dir(parent) {
+		matched, _ := path.Match(dir, parent)
+		if matched {
+			return true
+		}
+
+		if parent == "/" || parent == "." {
+			return false
+		}
+	}
+}
+
+// LoadTranslations reads all files matching pattern (e.g.
+// "/locales/*.json") and passes their contents to parse function. It's
+// meant to feed message catalogs of i18n libraries, for example:
+//
+//	fs.LoadTranslations("/locales/*.toml",
+//		func(data []byte, path string) error {
+//			_, err := bundle.ParseMessageFileBytes(data, path)
+//			return err
+//		},
+//	)
+func (fs *EmbedFs) LoadTranslations(
+	pattern string, parse func(data []byte, path string) error,
+) error {
+	names, err := fs.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		data, err := fs.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		err = parse(data, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
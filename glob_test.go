@@ -0,0 +1,92 @@
+package embedfs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanLoadTranslations(t *testing.T) {
+	container := mockfile.New("lala26")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/locales/en.json")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/locales/ru.json")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/b/2", "/templates/index.html")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := map[string]string{}
+
+	err = fs.LoadTranslations("/locales/*.json",
+		func(data []byte, path string) error {
+			loaded[path] = string(data)
+			return nil
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	expected := map[string]string{
+		"/locales/en.json": "1\n",
+		"/locales/ru.json": "2\n",
+	}
+
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Fatalf("unexpected translations: %v", loaded)
+	}
+}
+
+func TestCanMatchPathsUnderDirectories(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"/secrets/**", "/secrets/pass", true},
+		{"/secrets/**", "/secrets/db/pass", true},
+		{"/secrets/**", "/secrets", false},
+		{"/secrets/**", "/secretsx/pass", false},
+		{"/*/secrets/**", "/app/secrets/pass", true},
+		{"/*/secrets/**", "/app/secrets/db/pass", true},
+		{"/*/secrets/**", "/app/public/pass", false},
+		{"/*/secrets/**", "/secrets/pass", false},
+		{"/app/*/**", "/app/a/b/c", true},
+		{"/app/*/**", "/app/a", false},
+		{"/**", "/a/b", true},
+		{"/b/*", "/b/2", true},
+		{"/b/*", "/b/c/2", false},
+	} {
+		if matchPath(test.pattern, test.name) != test.matched {
+			t.Errorf(
+				"pattern <%s> matching <%s> should be %v",
+				test.pattern, test.name, test.matched,
+			)
+		}
+	}
+}
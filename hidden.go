@@ -0,0 +1,54 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"path"
+	"strings"
+)
+
+const paxHidden = paxPrefix + "hidden"
+
+// hide marks file, which is embedded under specified name, as hidden, if
+// its name matches patterns specified in EmbedOptions.Hidden.
+func (e *Embedder) hide(tarHeader *tar.Header, name string) {
+	for _, pattern := range e.options.Hidden {
+		if !matchPath(pattern, name) {
+			continue
+		}
+
+		if tarHeader.PAXRecords == nil {
+			tarHeader.PAXRecords = map[string]string{}
+		}
+
+		tarHeader.PAXRecords[paxHidden] = "true"
+
+		return
+	}
+}
+
+// isHidden returns true if file should not be listed. Internal files of
+// embedfs are always hidden.
+func isHidden(tarHeader *tar.Header) bool {
+	return isInternal(tarHeader.Name) || tarHeader.PAXRecords[paxHidden] != ""
+}
+
+// isInternal returns true if file is stored by embedfs for its own needs.
+func isInternal(name string) bool {
+	return strings.HasPrefix(path.Join("/", name), internalDir+"/")
+}
+
+// listAll returns names of all files with specified prefix, including
+// hidden ones.
+func (fs *EmbedFs) listAll(prefix string) ([]string, error) {
+	result := []string{}
+
+	err := fs.list(prefix, true, func(entry Entry) error {
+		result = append(result, entry.Name())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
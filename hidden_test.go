@@ -0,0 +1,81 @@
+package embedfs
+
+import (
+	iofs "io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanHideFilesFromListings(t *testing.T) {
+	container := mockfile.New("lala79")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Hidden: []string{"/b/*"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedBuildInfo()
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	names, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"/a/1"}) {
+		t.Fatalf("unexpected listing: %v", names)
+	}
+
+	names, err = fs.Glob("/b/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 0 {
+		t.Fatalf("hidden files are globbed: %v", names)
+	}
+
+	entries, err := iofs.ReadDir(fs.FS(), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "a" {
+		t.Fatalf("unexpected directory entries: %v", entries)
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("hidden file can't be read by exact path")
+	}
+
+	_, err = fs.BuildInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
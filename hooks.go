@@ -0,0 +1,82 @@
+package embedfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Hooks holds functions, which are called on access to embedded fs, so
+// usage of embedded content can be audited. Any of them can be nil.
+type Hooks struct {
+	// Loaded is called after index of embedfs is read; Err holds error of
+	// reading, if any.
+	Loaded func(Access)
+
+	// Opened is called after file is opened by Open method or via fs.FS.
+	Opened func(Access)
+
+	// Closed is called when opened file is closed.
+	Closed func(Access)
+}
+
+// Access describes single access to embedded fs.
+type Access struct {
+	// Path of the file in embedded fs, or "/" for the whole embedfs.
+	Path string
+
+	// Caller is the function and location of code outside of this package,
+	// which has caused access, like "main.serve (main.go:42)".
+	Caller string
+
+	Err error
+}
+
+// packageDir is the directory of source files of this package, used to skip
+// own frames while looking for caller.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+func (hooks Hooks) loaded(err error) {
+	if hooks.Loaded != nil {
+		hooks.Loaded(Access{Path: "/", Caller: caller(), Err: err})
+	}
+}
+
+func (hooks Hooks) opened(path string) {
+	if hooks.Opened != nil {
+		hooks.Opened(Access{Path: path, Caller: caller()})
+	}
+}
+
+func (hooks Hooks) closed(path string) {
+	if hooks.Closed != nil {
+		hooks.Closed(Access{Path: path, Caller: caller()})
+	}
+}
+
+// caller returns description of the first frame on the stack, which is not
+// located in this package.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if filepath.Dir(frame.File) != packageDir ||
+			strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf(
+				"%s (%s:%d)",
+				frame.Function, filepath.Base(frame.File), frame.Line,
+			)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package embedfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanHookAccess(t *testing.T) {
+	container := mockfile.New("lala67")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	accesses := []string{}
+	record := func(kind string) func(Access) {
+		return func(access Access) {
+			accesses = append(accesses, kind+" "+access.Path)
+
+			if !strings.Contains(access.Caller, ".TestCanHookAccess ") {
+				t.Errorf("unexpected caller: %q", access.Caller)
+			}
+		}
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{
+		Lazy: true,
+		Hooks: Hooks{
+			Loaded: record("loaded"),
+			Opened: record("opened"),
+			Closed: record("closed"),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.ReadFile("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "loaded /,opened /a/1,closed /a/1"
+	if strings.Join(accesses, ",") != expected {
+		t.Fatalf("unexpected accesses: %v", accesses)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package embedfs
+
+import (
+	"os"
+)
+
+// fileIdentity returns zero device and inode on systems without them, so
+// files are told apart by other attributes.
+func fileIdentity(info os.FileInfo) (uint64, uint64) {
+	return 0, 0
+}
+
+// fileOwner returns false on systems without user ids, so owner of files is
+// not checked.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
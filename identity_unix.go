@@ -0,0 +1,28 @@
+//go:build unix
+
+package embedfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns device and inode of the file, if they are known.
+func fileIdentity(info os.FileInfo) (uint64, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino)
+}
+
+// fileOwner returns user id of the owner of the file, if it's known.
+func fileOwner(info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return int(stat.Uid), true
+}
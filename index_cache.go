@@ -0,0 +1,311 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const indexCacheSuffix = ".embedfs-index"
+
+type indexCacheEntry struct {
+	Name         string
+	Offset       int64
+	Size         int64
+	HeaderOffset int64
+	External     string
+	URL          string
+	Checksum     string
+	Whiteout     string
+	Hidden       bool
+
+	// Nonce is set for encrypted entries, which data is stored at
+	// EncryptedOffset.
+	Nonce           string
+	EncryptedOffset int64
+
+	// Compressed is set for compressed entries, which data of
+	// CompressedSize bytes is stored at CompressedOffset of encrypted data,
+	// if entry is also encrypted, or of origin otherwise.
+	Compressed       bool
+	CompressedOffset int64
+	CompressedSize   int64
+	Dictionary       string
+
+	// Solid is set for solid blocks, which index is read from origin, so
+	// files packed into them are not cached.
+	Solid bool
+}
+
+func (fs *EmbedFs) loadIndexCache(path string) error {
+	cacheFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer cacheFile.Close()
+
+	cached := []indexCacheEntry{}
+
+	err = gob.NewDecoder(cacheFile).Decode(&cached)
+	if err != nil {
+		return err
+	}
+
+	for _, cachedEntry := range cached {
+		fs.entries++
+		fs.totalSize += cachedEntry.Size
+
+		err = fs.options.Limits.check(
+			fs.entries, fs.totalSize, &tar.Header{Name: cachedEntry.Name},
+		)
+		if err != nil {
+			return err
+		}
+
+		entry := &embedFsEntry{
+			name:         cachedEntry.Name,
+			offset:       cachedEntry.Offset,
+			size:         cachedEntry.Size,
+			headerOffset: cachedEntry.HeaderOffset,
+			whiteout:     cachedEntry.Whiteout,
+			hidden:       cachedEntry.Hidden || isInternal(cachedEntry.Name),
+		}
+
+		if cachedEntry.External != "" {
+			entry.external = fs.newExternalData(
+				cachedEntry.External, cachedEntry.Size,
+			)
+		}
+
+		if cachedEntry.URL != "" {
+			entry.external, err = fs.newRemoteData(
+				cachedEntry.URL, cachedEntry.Checksum, cachedEntry.Size,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		// headers are not cached, so they are read to be validated
+		if fs.options.Mode == ModeStrict {
+			header, err := fs.header(entry)
+			if err != nil {
+				return err
+			}
+
+			err = validateHeader(header)
+			if err != nil {
+				return &EntryError{
+					Name:   header.Name,
+					Offset: entry.headerOffset,
+					Err:    err,
+				}
+			}
+		}
+
+		if cachedEntry.Nonce != "" {
+			size := cachedEntry.Size
+			if cachedEntry.Compressed {
+				size = cachedEntry.CompressedSize
+			}
+
+			entry.encrypted, err = fs.newEncryptedData(
+				fs.originOf(entry), cachedEntry.EncryptedOffset, size,
+				cachedEntry.Nonce, entry.name,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cachedEntry.Compressed {
+			entry.compressed = &compressedData{
+				source:     fs.originOf(entry),
+				offset:     cachedEntry.CompressedOffset,
+				size:       cachedEntry.CompressedSize,
+				dictionary: cachedEntry.Dictionary,
+				fs:         fs,
+				plainSize:  cachedEntry.Size,
+			}
+		}
+
+		fs.files = append(fs.files, entry)
+
+		if cachedEntry.Solid {
+			header, err := fs.header(entry)
+			if err != nil {
+				return err
+			}
+
+			members, err := fs.expandSolid(entry, header)
+			if err != nil {
+				return err
+			}
+
+			fs.files = append(fs.files, members...)
+		}
+	}
+
+	return nil
+}
+
+func (fs *EmbedFs) writeIndexCache(path string) error {
+	cached := make([]indexCacheEntry, 0, len(fs.files))
+	for _, entry := range fs.files {
+		// packed files are expanded from solid blocks
+		if entry.member {
+			continue
+		}
+
+		cachedEntry := indexCacheEntry{
+			Name:         entry.name,
+			Offset:       entry.offset,
+			Size:         entry.size,
+			HeaderOffset: entry.headerOffset,
+			Whiteout:     entry.whiteout,
+			Hidden:       entry.hidden,
+			Solid:        entry.solid,
+		}
+
+		if entry.encrypted != nil {
+			cachedEntry.Nonce = hex.EncodeToString(entry.encrypted.nonce)
+			cachedEntry.EncryptedOffset = entry.encrypted.offset
+		}
+
+		if entry.compressed != nil {
+			cachedEntry.Compressed = true
+			cachedEntry.CompressedOffset = entry.compressed.offset
+			cachedEntry.CompressedSize = entry.compressed.size
+			cachedEntry.Dictionary = entry.compressed.dictionary
+		}
+
+		switch {
+		case entry.external == nil:
+		case entry.external.url != "":
+			cachedEntry.URL = entry.external.url
+			cachedEntry.Checksum = entry.external.hash
+		default:
+			cachedEntry.External = entry.external.reference
+		}
+
+		cached = append(cached, cachedEntry)
+	}
+
+	cacheFile, err := ioutil.TempFile(filepath.Dir(path), ".tmp-index-")
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(cacheFile).Encode(cached)
+	if err != nil {
+		cacheFile.Close()
+		os.Remove(cacheFile.Name())
+		return err
+	}
+
+	err = cacheFile.Close()
+	if err != nil {
+		os.Remove(cacheFile.Name())
+		return err
+	}
+
+	return os.Rename(cacheFile.Name(), path)
+}
+
+// indexCachePath returns path to the index cache file, which is keyed by
+// identity, size and modification time of origin along with location of
+// embedfs, its first header and footprint, so origin is not read as a
+// whole.
+func (fs *EmbedFs) indexCachePath() (string, error) {
+	stat, err := fs.origin.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	device, inode := fileIdentity(stat)
+
+	hash := sha256.New()
+
+	if origin, ok := fs.origin.(namedFile); ok {
+		fmt.Fprintf(hash, "%s\x00", origin.Name())
+	}
+
+	fmt.Fprintf(
+		hash, "%d\x00%d\x00%d\x00%d\x00%d\x00%d\x00",
+		device, inode, stat.Size(), stat.ModTime().UnixNano(),
+		fs.offset, fs.end,
+	)
+
+	footprintSize := int64(binary.Size(embedFsFootprint{}))
+
+	for _, region := range []ByteRange{
+		{Offset: fs.offset, Length: blockSize},
+		{Offset: fs.end, Length: footprintSize},
+	} {
+		_, err = io.Copy(
+			hash, io.NewSectionReader(fs.origin, region.Offset, region.Length),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(
+		fs.options.IndexCacheDir,
+		hex.EncodeToString(hash.Sum(nil))+indexCacheSuffix,
+	), nil
+}
+
+func (fs *EmbedFs) scanCached(ctx context.Context) error {
+	// entries stored in gzip members or SquashFS image are not cached
+	if isGzipped(fs.origin, fs.offset) || isSquashfs(fs.origin, fs.offset) {
+		return fs.scan(ctx)
+	}
+
+	cachePath, err := fs.indexCachePath()
+	if err != nil {
+		return err
+	}
+
+	err = fs.loadIndexCache(cachePath)
+	if err == nil {
+		fs.options.Metrics.CacheHit("/")
+		return nil
+	}
+
+	fs.options.Logger.Debug(
+		"index cache is not available, scanning embedfs",
+		"path", cachePath, "error", err,
+	)
+
+	fs.files = []*embedFsEntry{}
+	fs.entries = 0
+	fs.totalSize = 0
+
+	err = fs.scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(fs.errors) == 0 {
+		// cache is only an optimization, so it's ok to fail writing it
+		err = fs.writeIndexCache(cachePath)
+		if err != nil {
+			fs.options.Logger.Warn(
+				"can't write index cache",
+				"path", cachePath, "error", err,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanUseIndexCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "embedfs-cache")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(cacheDir)
+
+	container := mockfile.New("lala8")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	options := OpenOptions{IndexCacheDir: cacheDir}
+
+	_, err = OpenWithOptions(container, options)
+	if err != nil {
+		panic(err)
+	}
+
+	cached, _ := filepath.Glob(filepath.Join(cacheDir, "*"+indexCacheSuffix))
+	if len(cached) != 1 {
+		t.Fatalf("expected one index cache file, got %v", cached)
+	}
+
+	fs, err := OpenWithOptions(container, options)
+	if err != nil {
+		panic(err)
+	}
+
+	stat, err := fs.Stat("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Size() != 2 {
+		t.Fatalf("unexpected size of </b/2>: %d", stat.Size())
+	}
+}
+
+func TestCanEnforceLimitsWithIndexCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "embedfs-cache")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(cacheDir)
+
+	container := mockfile.New("lala91")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{IndexCacheDir: cacheDir})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		IndexCacheDir: cacheDir,
+		Limits:        Limits{MaxEntries: 1},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
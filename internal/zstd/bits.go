@@ -0,0 +1,164 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// bitsAt returns n bits of data starting from bit at specified position,
+// counting from the lowest bit of the first byte. Bits outside of data are
+// zeros.
+func bitsAt(data []byte, position int, n uint) uint64 {
+	value := uint64(0)
+
+	for i := uint(0); i < n; {
+		bit := position + int(i)
+
+		if bit < 0 || bit >= len(data)*8 {
+			i++
+			continue
+		}
+
+		shift := uint(bit & 7)
+
+		take := 8 - shift
+		if take > n-i {
+			take = n - i
+		}
+
+		value |= (uint64(data[bit>>3]>>shift) & (1<<take - 1)) << i
+		i += take
+	}
+
+	return value
+}
+
+// forwardReader reads bits from the lowest bit of the first byte.
+type forwardReader struct {
+	data     []byte
+	position int
+}
+
+func (reader *forwardReader) peek(n uint) uint64 {
+	return bitsAt(reader.data, reader.position, n)
+}
+
+func (reader *forwardReader) skip(n uint) {
+	reader.position += int(n)
+}
+
+func (reader *forwardReader) read(n uint) uint64 {
+	value := reader.peek(n)
+	reader.skip(n)
+
+	return value
+}
+
+// bytes returns number of bytes, which hold read bits.
+func (reader *forwardReader) bytes() int {
+	return (reader.position + 7) / 8
+}
+
+// backwardReader reads bitstream written backward: from the highest bit of
+// the last byte, which follows padding and marker bit, down to the lowest
+// bit of the first byte.
+type backwardReader struct {
+	data []byte
+
+	// position is the number of bits left to read
+	position int
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, corrupted("no end mark of bitstream")
+	}
+
+	return &backwardReader{
+		data:     data,
+		position: (len(data)-1)*8 + bits.Len8(data[len(data)-1]) - 1,
+	}, nil
+}
+
+func (reader *backwardReader) peek(n uint) uint64 {
+	return bitsAt(reader.data, reader.position-int(n), n)
+}
+
+func (reader *backwardReader) skip(n uint) {
+	reader.position -= int(n)
+}
+
+func (reader *backwardReader) read(n uint) uint64 {
+	value := reader.peek(n)
+	reader.skip(n)
+
+	return value
+}
+
+// overflow returns true if more bits are read than stream holds.
+func (reader *backwardReader) overflow() bool {
+	return reader.position < 0
+}
+
+// done returns true if all bits of stream are read.
+func (reader *backwardReader) done() bool {
+	return reader.position == 0
+}
+
+// bitWriter writes bits starting from the lowest bit of the first byte.
+type bitWriter struct {
+	data      []byte
+	container uint64
+	count     uint
+}
+
+// write writes n lowest bits of value, n should not be larger than 32.
+func (writer *bitWriter) write(value uint64, n uint) {
+	writer.container |= (value & (1<<n - 1)) << writer.count
+	writer.count += n
+
+	for writer.count >= 8 {
+		writer.data = append(writer.data, byte(writer.container))
+		writer.container >>= 8
+		writer.count -= 8
+	}
+}
+
+// bytes returns written bits padded by zeros to the whole byte.
+func (writer *bitWriter) bytes() []byte {
+	if writer.count > 0 {
+		writer.write(0, 8-writer.count)
+	}
+
+	return writer.data
+}
+
+// close writes end mark of bitstream, which is read backward, and returns
+// written bits.
+func (writer *bitWriter) close() []byte {
+	writer.write(1, 1)
+
+	return writer.bytes()
+}
+
+// bitField is the value of specified number of bits, which is collected to
+// be written into backward bitstream in reverse order.
+type bitField struct {
+	value uint64
+	bits  uint
+}
+
+// writeBackward writes fields in reverse order and closes stream, so they
+// are read by backwardReader in the order they are listed.
+func writeBackward(fields []bitField) []byte {
+	writer := &bitWriter{}
+	for i := len(fields) - 1; i >= 0; i-- {
+		writer.write(fields[i].value, fields[i].bits)
+	}
+
+	return writer.close()
+}
+
+// highBit returns position of the highest set bit of value.
+func highBit(value uint32) uint {
+	return uint(bits.Len32(value)) - 1
+}
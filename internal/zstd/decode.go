@@ -0,0 +1,639 @@
+package zstd
+
+import (
+	"encoding/binary"
+)
+
+// decoder holds state of decompression, which is shared by blocks of
+// frame.
+type decoder struct {
+	dict    *Dictionary
+	maxSize int
+
+	// out holds decompressed data of all frames, current one starts at
+	// frameStart
+	out        []byte
+	frameStart int
+
+	repeats repeatedOffsets
+
+	literals      *huffmanTable
+	literalLength *fseTable
+	matchLength   *fseTable
+	offset        *fseTable
+}
+
+// Decompress decompresses all frames of data, which may refer to content
+// of dictionary, if it's not nil. ErrTooLarge is returned if data
+// decompresses to more than maxSize bytes.
+func Decompress(data []byte, dict *Dictionary, maxSize int) ([]byte, error) {
+	if dict == nil {
+		dict = &Dictionary{repeats: initialRepeats}
+	}
+
+	decoder := &decoder{dict: dict, maxSize: maxSize, out: []byte{}}
+
+	if len(data) == 0 {
+		return nil, corrupted("no frames")
+	}
+
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, corrupted("frame is truncated")
+		}
+
+		magic := binary.LittleEndian.Uint32(data)
+
+		if magic&skippableMask == skippableMagic {
+			if len(data) < 8 {
+				return nil, corrupted("skippable frame is truncated")
+			}
+
+			size := int64(binary.LittleEndian.Uint32(data[4:]))
+			if int64(len(data)-8) < size {
+				return nil, corrupted("skippable frame is truncated")
+			}
+
+			data = data[8+size:]
+
+			continue
+		}
+
+		if magic != frameMagic {
+			return nil, corrupted("unknown frame magic %#x", magic)
+		}
+
+		var err error
+
+		data, err = decoder.frame(data[4:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decoder.out, nil
+}
+
+// frame decompresses frame, which follows magic, and returns the rest of
+// data.
+func (decoder *decoder) frame(data []byte) ([]byte, error) {
+	if len(data) < 1 {
+		return nil, corrupted("frame header is truncated")
+	}
+
+	descriptor := data[0]
+	data = data[1:]
+
+	if descriptor&0x08 != 0 {
+		return nil, corrupted("reserved bit of frame header is set")
+	}
+
+	singleSegment := descriptor&0x20 != 0
+	checksum := descriptor&0x04 != 0
+
+	dictionaryIDSize := []int{0, 1, 2, 4}[descriptor&3]
+
+	contentSizeSize := []int{0, 2, 4, 8}[descriptor>>6]
+	if contentSizeSize == 0 && singleSegment {
+		contentSizeSize = 1
+	}
+
+	headerSize := dictionaryIDSize + contentSizeSize
+	if !singleSegment {
+		headerSize++
+	}
+
+	if len(data) < headerSize {
+		return nil, corrupted("frame header is truncated")
+	}
+
+	// window size is not checked, because whole frame is decompressed
+	// into memory limited by maxSize anyway
+	if !singleSegment {
+		data = data[1:]
+	}
+
+	dictionaryID := uint32(littleEndian(data[:dictionaryIDSize]))
+	if dictionaryID != 0 && decoder.dict.id != 0 &&
+		dictionaryID != decoder.dict.id {
+		return nil, corrupted("frame requires dictionary %d", dictionaryID)
+	}
+
+	data = data[dictionaryIDSize:]
+
+	contentSize := int64(-1)
+	if contentSizeSize > 0 {
+		contentSize = int64(littleEndian(data[:contentSizeSize]))
+		if contentSizeSize == 2 {
+			contentSize += 256
+		}
+
+		if contentSize < 0 ||
+			contentSize > int64(decoder.maxSize-len(decoder.out)) {
+			return nil, ErrTooLarge
+		}
+	}
+
+	data = data[contentSizeSize:]
+
+	decoder.frameStart = len(decoder.out)
+	decoder.repeats = decoder.dict.repeats
+	decoder.literals = decoder.dict.literals
+	decoder.literalLength = decoder.dict.literalLength
+	decoder.matchLength = decoder.dict.matchLength
+	decoder.offset = decoder.dict.offset
+
+	for last := false; !last; {
+		if len(data) < 3 {
+			return nil, corrupted("block header is truncated")
+		}
+
+		header := int(littleEndian(data[:3]))
+		data = data[3:]
+
+		last = header&1 != 0
+		kind := header >> 1 & 3
+		size := header >> 3
+
+		var err error
+
+		switch kind {
+		case blockRaw:
+			if len(data) < size {
+				return nil, corrupted("block is truncated")
+			}
+
+			err = decoder.grow(size)
+			if err == nil {
+				decoder.out = append(decoder.out, data[:size]...)
+				data = data[size:]
+			}
+
+		case blockRLE:
+			if len(data) < 1 {
+				return nil, corrupted("block is truncated")
+			}
+
+			if size > maxBlockSize {
+				return nil, corrupted("block is too large")
+			}
+
+			err = decoder.grow(size)
+			for i := 0; i < size && err == nil; i++ {
+				decoder.out = append(decoder.out, data[0])
+			}
+
+			data = data[1:]
+
+		case blockCompressed:
+			if len(data) < size {
+				return nil, corrupted("block is truncated")
+			}
+
+			if size > maxBlockSize {
+				return nil, corrupted("block is too large")
+			}
+
+			err = decoder.block(data[:size])
+			data = data[size:]
+
+		default:
+			return nil, corrupted("reserved block type")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	content := decoder.out[decoder.frameStart:]
+
+	if contentSize >= 0 && int64(len(content)) != contentSize {
+		return nil, corrupted("frame content size mismatch")
+	}
+
+	if checksum {
+		if len(data) < 4 {
+			return nil, corrupted("frame checksum is truncated")
+		}
+
+		if uint32(xxhash64(content)) != binary.LittleEndian.Uint32(data) {
+			return nil, corrupted("frame checksum mismatch")
+		}
+
+		data = data[4:]
+	}
+
+	return data, nil
+}
+
+// grow checks that specified number of bytes can be appended to the
+// output.
+func (decoder *decoder) grow(size int) error {
+	if size > decoder.maxSize-len(decoder.out) {
+		return ErrTooLarge
+	}
+
+	return nil
+}
+
+// block decompresses compressed block.
+func (decoder *decoder) block(data []byte) error {
+	literals, read, err := decoder.readLiterals(data)
+	if err != nil {
+		return err
+	}
+
+	data = data[read:]
+
+	if len(data) == 0 {
+		return corrupted("sequences section is missing")
+	}
+
+	count := int(data[0])
+
+	switch {
+	case count == 0:
+		if len(data) != 1 {
+			return corrupted("data after empty sequences section")
+		}
+
+		err = decoder.grow(len(literals))
+		if err != nil {
+			return err
+		}
+
+		decoder.out = append(decoder.out, literals...)
+
+		return nil
+
+	case count < 128:
+		data = data[1:]
+
+	case count < 255:
+		if len(data) < 2 {
+			return corrupted("sequences section is truncated")
+		}
+
+		count = (count-128)<<8 + int(data[1])
+		data = data[2:]
+
+	default:
+		if len(data) < 3 {
+			return corrupted("sequences section is truncated")
+		}
+
+		count = int(data[1]) + int(data[2])<<8 + 0x7F00
+		data = data[3:]
+	}
+
+	if len(data) < 1 {
+		return corrupted("sequences section is truncated")
+	}
+
+	modes := data[0]
+	data = data[1:]
+
+	if modes&3 != 0 {
+		return corrupted("reserved bits of compression modes are set")
+	}
+
+	tables := []struct {
+		table      **fseTable
+		mode       byte
+		predefined *fseTable
+		maxSymbol  int
+		maxLog     uint
+	}{
+		{
+			&decoder.literalLength, modes >> 6, predefinedLiteralLengthTable,
+			maxLiteralLengthSymbol, maxLiteralLengthLog,
+		},
+		{
+			&decoder.offset, modes >> 4 & 3, predefinedOffsetTable,
+			maxOffsetSymbol, maxOffsetLog,
+		},
+		{
+			&decoder.matchLength, modes >> 2 & 3, predefinedMatchLengthTable,
+			maxMatchLengthSymbol, maxMatchLengthLog,
+		},
+	}
+
+	for _, table := range tables {
+		switch table.mode {
+		case modePredefined:
+			*table.table = table.predefined
+
+		case modeRLE:
+			if len(data) < 1 {
+				return corrupted("sequences section is truncated")
+			}
+
+			if int(data[0]) > table.maxSymbol {
+				return corrupted("invalid symbol of RLE table")
+			}
+
+			*table.table = rleFSETable(data[0])
+			data = data[1:]
+
+		case modeCompressed:
+			*table.table, read, err = readFSETable(
+				data, table.maxSymbol, table.maxLog,
+			)
+			if err != nil {
+				return err
+			}
+
+			data = data[read:]
+
+		case modeRepeat:
+			if *table.table == nil {
+				return corrupted("no table to repeat")
+			}
+		}
+	}
+
+	return decoder.sequences(data, count, literals)
+}
+
+// readLiterals reads literals section of block and returns literals along
+// with size of section.
+func (decoder *decoder) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corrupted("literals section is missing")
+	}
+
+	kind := data[0] & 3
+	format := data[0] >> 2 & 3
+
+	if kind == literalsRaw || kind == literalsRLE {
+		var size, header int
+
+		switch format {
+		case 0, 2:
+			size, header = int(data[0]>>3), 1
+		case 1:
+			header = 2
+		case 3:
+			header = 3
+		}
+
+		if len(data) < header {
+			return nil, 0, corrupted("literals header is truncated")
+		}
+
+		if header > 1 {
+			size = int(littleEndian(data[:header]) >> 4)
+		}
+
+		if size > maxBlockSize {
+			return nil, 0, corrupted("too many literals")
+		}
+
+		if kind == literalsRLE {
+			if len(data) < header+1 {
+				return nil, 0, corrupted("literals are truncated")
+			}
+
+			literals := make([]byte, size)
+			for i := range literals {
+				literals[i] = data[header]
+			}
+
+			return literals, header + 1, nil
+		}
+
+		if len(data) < header+size {
+			return nil, 0, corrupted("literals are truncated")
+		}
+
+		return data[header : header+size], header + size, nil
+	}
+
+	header, bits, streams := 3, uint(10), 4
+	switch format {
+	case 0:
+		streams = 1
+	case 2:
+		header, bits = 4, 14
+	case 3:
+		header, bits = 5, 18
+	}
+
+	if len(data) < header {
+		return nil, 0, corrupted("literals header is truncated")
+	}
+
+	sizes := littleEndian(data[:header]) >> 4
+	size := int(sizes & (1<<bits - 1))
+	compressedSize := int(sizes >> bits & (1<<bits - 1))
+
+	if size > maxBlockSize {
+		return nil, 0, corrupted("too many literals")
+	}
+
+	if len(data) < header+compressedSize {
+		return nil, 0, corrupted("literals are truncated")
+	}
+
+	compressed := data[header : header+compressedSize]
+
+	if kind == literalsCompressed {
+		table, read, err := readHuffmanTable(compressed)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		decoder.literals = table
+		compressed = compressed[read:]
+	}
+
+	if decoder.literals == nil {
+		return nil, 0, corrupted("no Huffman table to repeat")
+	}
+
+	literals := make([]byte, size)
+
+	if streams == 1 {
+		err := decoder.literals.decode(compressed, literals)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return literals, header + compressedSize, nil
+	}
+
+	if len(compressed) < 6 {
+		return nil, 0, corrupted("literals jump table is truncated")
+	}
+
+	segment := (size + 3) / 4
+	if segment*3 > size {
+		return nil, 0, corrupted("too few literals for four streams")
+	}
+
+	jumps := compressed[:6]
+	compressed = compressed[6:]
+
+	for i := 0; i < 4; i++ {
+		length := len(compressed)
+		if i < 3 {
+			length = int(binary.LittleEndian.Uint16(jumps[i*2:]))
+		}
+
+		if length > len(compressed) {
+			return nil, 0, corrupted("literals stream is truncated")
+		}
+
+		out := literals[i*segment:]
+		if i < 3 {
+			out = out[:segment]
+		}
+
+		err := decoder.literals.decode(compressed[:length], out)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		compressed = compressed[length:]
+	}
+
+	return literals, header + compressedSize, nil
+}
+
+// fseState is the current state of decoding by FSE table.
+type fseState struct {
+	table *fseTable
+	state int
+}
+
+func (state *fseState) init(reader *backwardReader) {
+	state.state = int(reader.read(state.table.log))
+}
+
+func (state *fseState) symbol() uint8 {
+	return state.table.entries[state.state].symbol
+}
+
+func (state *fseState) update(reader *backwardReader) {
+	entry := state.table.entries[state.state]
+	state.state = int(entry.base) + int(reader.read(uint(entry.bits)))
+}
+
+// sequences decodes and executes sequences of block.
+func (decoder *decoder) sequences(
+	data []byte, count int, literals []byte,
+) error {
+	reader, err := newBackwardReader(data)
+	if err != nil {
+		return err
+	}
+
+	literalLength := &fseState{table: decoder.literalLength}
+	offset := &fseState{table: decoder.offset}
+	matchLength := &fseState{table: decoder.matchLength}
+
+	literalLength.init(reader)
+	offset.init(reader)
+	matchLength.init(reader)
+
+	for i := 0; i < count; i++ {
+		offsetCode := offset.symbol()
+		matchCode := matchLength.symbol()
+		literalCode := literalLength.symbol()
+
+		if offsetCode > maxOffsetSymbol ||
+			matchCode > maxMatchLengthSymbol ||
+			literalCode > maxLiteralLengthSymbol {
+			return corrupted("invalid sequence code")
+		}
+
+		offsetValue := 1<<offsetCode + int(reader.read(uint(offsetCode)))
+
+		matchSize := matchLengthBase[matchCode] +
+			int(reader.read(matchLengthBits[matchCode]))
+
+		literalSize := literalLengthBase[literalCode] +
+			int(reader.read(literalLengthBits[literalCode]))
+
+		if i < count-1 {
+			literalLength.update(reader)
+			matchLength.update(reader)
+			offset.update(reader)
+		}
+
+		if reader.overflow() {
+			return corrupted("sequences bitstream is truncated")
+		}
+
+		distance := decoder.repeats.resolve(offsetValue, literalSize)
+
+		if literalSize > len(literals) {
+			return corrupted("sequence refers to missing literals")
+		}
+
+		err = decoder.grow(literalSize + matchSize)
+		if err != nil {
+			return err
+		}
+
+		decoder.out = append(decoder.out, literals[:literalSize]...)
+		literals = literals[literalSize:]
+
+		err = decoder.copyMatch(distance, matchSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !reader.done() {
+		return corrupted("sequences bitstream size mismatch")
+	}
+
+	err = decoder.grow(len(literals))
+	if err != nil {
+		return err
+	}
+
+	decoder.out = append(decoder.out, literals...)
+
+	return nil
+}
+
+// copyMatch appends data found at specified distance back from the end of
+// output, which may refer to dictionary content before the frame.
+func (decoder *decoder) copyMatch(distance int, size int) error {
+	content := decoder.dict.content
+
+	position := len(decoder.out) - distance
+	if distance <= 0 || position < decoder.frameStart-len(content) {
+		return corrupted("invalid match offset")
+	}
+
+	for ; size > 0 && position < decoder.frameStart; size-- {
+		decoder.out = append(
+			decoder.out,
+			content[len(content)-(decoder.frameStart-position)],
+		)
+
+		position++
+	}
+
+	for ; size > 0; size-- {
+		decoder.out = append(decoder.out, decoder.out[position])
+		position++
+	}
+
+	return nil
+}
+
+// littleEndian returns value of up to 8 bytes stored in little-endian
+// order.
+func littleEndian(data []byte) uint64 {
+	value := uint64(0)
+	for i := len(data) - 1; i >= 0; i-- {
+		value = value<<8 | uint64(data[i])
+	}
+
+	return value
+}
@@ -0,0 +1,580 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+const (
+	// hashLog is the size of hash table of match index.
+	hashLog = 16
+
+	// minMatch is the length of hashed prefix of match.
+	minMatch = 4
+
+	// maxChain is the number of earlier positions tried to find match.
+	maxChain = 32
+
+	// niceMatch is the length of match, which is good enough to stop
+	// searching for longer one.
+	niceMatch = 128
+
+	// maxDistance keeps offset codes within range of predefined table.
+	maxDistance = 1<<27 - 4
+
+	// minHuffmanLiterals is the number of literals, which are not worth
+	// building Huffman table for.
+	minHuffmanLiterals = 64
+)
+
+// matchIndex finds earlier positions, which start with the same minMatch
+// bytes, by hash chains.
+type matchIndex struct {
+	head  []int32
+	chain []int32
+}
+
+func newMatchIndex(size int) *matchIndex {
+	index := &matchIndex{
+		head:  make([]int32, 1<<hashLog),
+		chain: make([]int32, size),
+	}
+
+	for i := range index.head {
+		index.head[i] = -1
+	}
+
+	return index
+}
+
+func hashAt(data []byte, position int) uint32 {
+	return binary.LittleEndian.Uint32(data[position:]) * 2654435761 >>
+		(32 - hashLog)
+}
+
+func (index *matchIndex) insert(data []byte, position int) {
+	hash := hashAt(data, position)
+
+	index.chain[position] = index.head[hash]
+	index.head[hash] = int32(position)
+}
+
+// prepare builds index of dictionary content, which is copied by every
+// compression.
+func (dict *Dictionary) prepare() *matchIndex {
+	dict.indexOnce.Do(func() {
+		dict.index = newMatchIndex(len(dict.content))
+
+		for position := 0; position+minMatch <= len(dict.content); position++ {
+			dict.index.insert(dict.content, position)
+		}
+	})
+
+	return dict.index
+}
+
+// sequence copies literals and then match found at specified offset value.
+type sequence struct {
+	literalLength int
+	matchLength   int
+	offsetValue   int
+}
+
+// encoder holds state of compression of single frame.
+type encoder struct {
+	// buffer holds dictionary content followed by data
+	buffer []byte
+	index  *matchIndex
+
+	// indexed is the position, which is to be inserted into index next
+	indexed int
+
+	repeats repeatedOffsets
+}
+
+// Compress returns data compressed into single frame, which may refer to
+// content of dictionary, if it's not nil.
+func Compress(data []byte, dict *Dictionary) []byte {
+	if dict == nil {
+		dict = &Dictionary{repeats: initialRepeats}
+	}
+
+	prepared := dict.prepare()
+
+	start := len(dict.content)
+
+	encoder := &encoder{
+		buffer: append(append(
+			make([]byte, 0, start+len(data)), dict.content...,
+		), data...),
+		index: &matchIndex{
+			head:  append([]int32{}, prepared.head...),
+			chain: make([]int32, start+len(data)),
+		},
+		repeats: dict.repeats,
+	}
+
+	copy(encoder.index.chain, prepared.chain)
+
+	// positions which are hashed along with beginning of data are left
+	// out of dictionary index
+	encoder.indexed = start - minMatch + 1
+	if encoder.indexed < 0 {
+		encoder.indexed = 0
+	}
+
+	out := frameHeader(len(data))
+
+	if len(data) == 0 {
+		return appendBlockHeader(out, true, blockRaw, 0)
+	}
+
+	for block := start; block < len(encoder.buffer); block += maxBlockSize {
+		end := block + maxBlockSize
+		if end > len(encoder.buffer) {
+			end = len(encoder.buffer)
+		}
+
+		out = encoder.block(out, block, end, end == len(encoder.buffer))
+	}
+
+	return out
+}
+
+// frameHeader returns magic and header of single segment frame with
+// specified content size.
+func frameHeader(size int) []byte {
+	header := binary.LittleEndian.AppendUint32(nil, frameMagic)
+
+	switch {
+	case size < 256:
+		header = append(header, 0x20, byte(size))
+	case size < 65536+256:
+		header = append(header, 0x60)
+		header = binary.LittleEndian.AppendUint16(header, uint16(size-256))
+	case uint64(size) < 1<<32:
+		header = append(header, 0xA0)
+		header = binary.LittleEndian.AppendUint32(header, uint32(size))
+	default:
+		header = append(header, 0xE0)
+		header = binary.LittleEndian.AppendUint64(header, uint64(size))
+	}
+
+	return header
+}
+
+func appendBlockHeader(out []byte, last bool, kind int, size int) []byte {
+	header := size<<3 | kind<<1
+	if last {
+		header |= 1
+	}
+
+	return append(out, byte(header), byte(header>>8), byte(header>>16))
+}
+
+// block appends block holding data of buffer between specified positions,
+// which is compressed, unless that doesn't make it smaller.
+func (encoder *encoder) block(out []byte, start, end int, last bool) []byte {
+	data := encoder.buffer[start:end]
+
+	if isRLE(data) {
+		out = appendBlockHeader(out, last, blockRLE, len(data))
+
+		return append(out, data[0])
+	}
+
+	repeats := encoder.repeats
+
+	literals, sequences := encoder.parse(start, end)
+
+	compressed := encodeSequences(encodeLiterals(literals), sequences)
+	if len(compressed) >= len(data) {
+		encoder.repeats = repeats
+
+		out = appendBlockHeader(out, last, blockRaw, len(data))
+
+		return append(out, data...)
+	}
+
+	out = appendBlockHeader(out, last, blockCompressed, len(compressed))
+
+	return append(out, compressed...)
+}
+
+// parse splits data of buffer between specified positions into literals
+// and sequences, which copy them and matches found earlier in buffer.
+func (encoder *encoder) parse(start, end int) ([]byte, []sequence) {
+	literals := []byte{}
+	sequences := []sequence{}
+
+	anchor := start
+
+	for position := start; position+minMatch <= end; {
+		length, distance := encoder.match(position, end)
+		if length < minMatch {
+			position++
+			continue
+		}
+
+		// match starting at the next byte may be longer
+		if position+1+minMatch <= end {
+			next, _ := encoder.match(position+1, end)
+			if next > length+1 {
+				position++
+				continue
+			}
+		}
+
+		literalLength := position - anchor
+
+		literals = append(literals, encoder.buffer[anchor:position]...)
+		sequences = append(sequences, sequence{
+			literalLength: literalLength,
+			matchLength:   length,
+			offsetValue:   encoder.repeats.encode(distance, literalLength),
+		})
+
+		position += length
+		anchor = position
+	}
+
+	literals = append(literals, encoder.buffer[anchor:end]...)
+
+	return literals, sequences
+}
+
+// match returns the longest match for data at specified position, which
+// ends before end, and its distance.
+func (encoder *encoder) match(position, end int) (int, int) {
+	buffer := encoder.buffer
+
+	for ; encoder.indexed < position; encoder.indexed++ {
+		if encoder.indexed+minMatch <= len(buffer) {
+			encoder.index.insert(buffer, encoder.indexed)
+		}
+	}
+
+	best, bestDistance := 0, 0
+
+	try := func(distance int) bool {
+		length := 0
+		for position+length < end &&
+			buffer[position-distance+length] == buffer[position+length] {
+			length++
+		}
+
+		if length > best {
+			best, bestDistance = length, distance
+		}
+
+		return best >= niceMatch
+	}
+
+	// repeated offsets are cheaper, so they are tried first
+	for _, distance := range encoder.repeats {
+		if distance > 0 && distance <= position && try(distance) {
+			return best, bestDistance
+		}
+	}
+
+	candidate := encoder.index.head[hashAt(buffer, position)]
+
+	for depth := 0; candidate >= 0 && depth < maxChain; depth++ {
+		distance := position - int(candidate)
+		if distance > maxDistance || try(distance) {
+			break
+		}
+
+		candidate = encoder.index.chain[candidate]
+	}
+
+	return best, bestDistance
+}
+
+func isRLE(data []byte) bool {
+	for _, b := range data {
+		if b != data[0] {
+			return false
+		}
+	}
+
+	return len(data) > 0
+}
+
+// encodeLiterals returns literals section, which holds literals compressed
+// by Huffman code, if that makes them smaller.
+func encodeLiterals(literals []byte) []byte {
+	if isRLE(literals) {
+		return append(literalsHeader(literalsRLE, len(literals)), literals[0])
+	}
+
+	raw := append(literalsHeader(literalsRaw, len(literals)), literals...)
+
+	if len(literals) < minHuffmanLiterals {
+		return raw
+	}
+
+	compressed := compressLiterals(literals)
+	if compressed == nil || len(compressed) >= len(raw) {
+		return raw
+	}
+
+	return compressed
+}
+
+func literalsHeader(kind byte, size int) []byte {
+	switch {
+	case size < 32:
+		return []byte{kind | byte(size)<<3}
+	case size < 4096:
+		return []byte{kind | 1<<2 | byte(size)<<4, byte(size >> 4)}
+	default:
+		return []byte{
+			kind | 3<<2 | byte(size)<<4, byte(size >> 4), byte(size >> 12),
+		}
+	}
+}
+
+// compressLiterals returns literals section with literals compressed by
+// Huffman code, or nil if they can't be compressed.
+func compressLiterals(literals []byte) []byte {
+	counts := make([]int, 256)
+	for _, literal := range literals {
+		counts[literal]++
+	}
+
+	huffman := newHuffmanEncoder(counts)
+
+	table := huffman.writeTable()
+	if table == nil {
+		return nil
+	}
+
+	size := len(literals)
+
+	if size < 1024 {
+		stream := huffman.encode(literals)
+
+		if len(table)+len(stream) < 1024 {
+			return appendLiteralsHeader(
+				nil, 0, size, append(table, stream...),
+			)
+		}
+	}
+
+	streams := make([][]byte, 4)
+
+	segment := (size + 3) / 4
+	for i := range streams {
+		end := (i + 1) * segment
+		if end > size {
+			end = size
+		}
+
+		streams[i] = huffman.encode(literals[i*segment : end])
+	}
+
+	// sizes of the first three streams are stored in jump table
+	payload := append([]byte{}, table...)
+	for _, stream := range streams[:3] {
+		if len(stream) > 0xFFFF {
+			return nil
+		}
+
+		payload = binary.LittleEndian.AppendUint16(
+			payload, uint16(len(stream)),
+		)
+	}
+
+	for _, stream := range streams {
+		payload = append(payload, stream...)
+	}
+
+	format := 1
+	switch {
+	case size >= 1<<14 || len(payload) >= 1<<14:
+		format = 3
+	case size >= 1<<10 || len(payload) >= 1<<10:
+		format = 2
+	}
+
+	return appendLiteralsHeader(nil, format, size, payload)
+}
+
+// appendLiteralsHeader appends header of compressed literals section of
+// specified size format and payload.
+func appendLiteralsHeader(
+	out []byte, format int, size int, payload []byte,
+) []byte {
+	bits, length := uint(10), 3
+	switch format {
+	case 2:
+		bits, length = 14, 4
+	case 3:
+		bits, length = 18, 5
+	}
+
+	header := uint64(literalsCompressed) | uint64(format)<<2 |
+		uint64(size)<<4 | uint64(len(payload))<<(4+bits)
+
+	for i := 0; i < length; i++ {
+		out = append(out, byte(header>>(8*i)))
+	}
+
+	return append(out, payload...)
+}
+
+// encodeSequences appends sequences section to specified literals section.
+func encodeSequences(out []byte, sequences []sequence) []byte {
+	count := len(sequences)
+
+	switch {
+	case count < 128:
+		out = append(out, byte(count))
+	case count < 0x7F00:
+		out = append(out, byte(count>>8+128), byte(count))
+	default:
+		out = append(out, 255, byte(count-0x7F00), byte((count-0x7F00)>>8))
+	}
+
+	if count == 0 {
+		return out
+	}
+
+	literalCodes := make([]uint8, count)
+	offsetCodes := make([]uint8, count)
+	matchCodes := make([]uint8, count)
+
+	for i, sequence := range sequences {
+		literalCodes[i] = lengthCode(
+			literalLengthBase[:], sequence.literalLength,
+		)
+		offsetCodes[i] = uint8(highBit(uint32(sequence.offsetValue)))
+		matchCodes[i] = lengthCode(matchLengthBase[:], sequence.matchLength)
+	}
+
+	literalMode, literalTable, literalDescription := chooseTable(
+		literalCodes, maxLiteralLengthSymbol, maxLiteralLengthLog,
+		predefinedLiteralLengthTable, predefinedLiteralLengths,
+	)
+
+	offsetMode, offsetTable, offsetDescription := chooseTable(
+		offsetCodes, maxOffsetSymbol, maxOffsetLog,
+		predefinedOffsetTable, predefinedOffsets,
+	)
+
+	matchMode, matchTable, matchDescription := chooseTable(
+		matchCodes, maxMatchLengthSymbol, maxMatchLengthLog,
+		predefinedMatchLengthTable, predefinedMatchLengths,
+	)
+
+	out = append(out, literalMode<<6|offsetMode<<4|matchMode<<2)
+	out = append(out, literalDescription...)
+	out = append(out, offsetDescription...)
+	out = append(out, matchDescription...)
+
+	literalStates, literalUpdates := encodeStates(literalTable, literalCodes)
+	offsetStates, offsetUpdates := encodeStates(offsetTable, offsetCodes)
+	matchStates, matchUpdates := encodeStates(matchTable, matchCodes)
+
+	fields := []bitField{
+		{value: uint64(literalStates[0]), bits: literalTable.log},
+		{value: uint64(offsetStates[0]), bits: offsetTable.log},
+		{value: uint64(matchStates[0]), bits: matchTable.log},
+	}
+
+	for i, sequence := range sequences {
+		offsetCode := uint(offsetCodes[i])
+		matchCode := matchCodes[i]
+		literalCode := literalCodes[i]
+
+		fields = append(fields,
+			bitField{
+				value: uint64(sequence.offsetValue - 1<<offsetCode),
+				bits:  offsetCode,
+			},
+			bitField{
+				value: uint64(
+					sequence.matchLength - matchLengthBase[matchCode],
+				),
+				bits: matchLengthBits[matchCode],
+			},
+			bitField{
+				value: uint64(
+					sequence.literalLength - literalLengthBase[literalCode],
+				),
+				bits: literalLengthBits[literalCode],
+			},
+		)
+
+		if i < count-1 {
+			fields = append(fields,
+				literalUpdates[i], matchUpdates[i], offsetUpdates[i],
+			)
+		}
+	}
+
+	return append(out, writeBackward(fields)...)
+}
+
+// lengthCode returns code of length by baselines of codes.
+func lengthCode(base []int, length int) uint8 {
+	return uint8(sort.Search(len(base), func(i int) bool {
+		return base[i] > length
+	}) - 1)
+}
+
+// chooseTable returns compression mode, table and its description, which
+// encodes specified codes in the least number of bits.
+func chooseTable(
+	codes []uint8, maxSymbol int, maxLog uint,
+	predefined *fseTable, predefinedCounts []int16,
+) (byte, *fseTable, []byte) {
+	counts := make([]int, maxSymbol+1)
+	distinct := 0
+
+	for _, code := range codes {
+		if counts[code] == 0 {
+			distinct++
+		}
+
+		counts[code]++
+	}
+
+	if distinct == 1 {
+		return modeRLE, rleFSETable(codes[0]), []byte{codes[0]}
+	}
+
+	log := tableLog(len(codes), distinct, maxLog)
+	normalized := normalizeCounts(counts, len(codes), log)
+	description := writeFSETable(normalized, log)
+
+	if cost(counts, predefinedCounts, predefined.log) <=
+		float64(8*len(description))+cost(counts, normalized, log) {
+		return modePredefined, predefined, nil
+	}
+
+	table, err := buildFSETable(normalized, log)
+	if err != nil {
+		panic(err)
+	}
+
+	return modeCompressed, table, description
+}
+
+// encodeStates returns states, which decode specified symbols in order, and
+// bits, which are read to get from every state to the next one.
+func encodeStates(table *fseTable, symbols []uint8) ([]int, []bitField) {
+	encoder := newFSEEncoder(table)
+
+	count := len(symbols)
+	states := make([]int, count)
+	updates := make([]bitField, count)
+
+	states[count-1] = encoder.first(symbols[count-1])
+	for i := count - 2; i >= 0; i-- {
+		states[i], updates[i] = encoder.previous(symbols[i], states[i+1])
+	}
+
+	return states, updates
+}
@@ -0,0 +1,400 @@
+package zstd
+
+import (
+	"math"
+	"sort"
+)
+
+// fseTable is the decoding table of finite state entropy coding: state is
+// the index of entry, which holds decoded symbol, and next state is base
+// of entry plus specified number of bits read from bitstream.
+type fseTable struct {
+	log     uint
+	entries []fseEntry
+}
+
+type fseEntry struct {
+	symbol uint8
+	bits   uint8
+	base   uint16
+}
+
+// buildFSETable builds decoding table from normalized counts of symbols,
+// which sum up to size of table. Count -1 means probability lower than 1,
+// which takes single state at the end of table.
+func buildFSETable(counts []int16, log uint) (*fseTable, error) {
+	size := 1 << log
+
+	total := 0
+	for _, count := range counts {
+		switch {
+		case count == -1:
+			total++
+		case count > 0:
+			total += int(count)
+		case count < -1:
+			return nil, corrupted("invalid count of FSE symbol")
+		}
+	}
+
+	if total != size || len(counts) > 256 {
+		return nil, corrupted("invalid FSE distribution")
+	}
+
+	table := &fseTable{log: log, entries: make([]fseEntry, size)}
+
+	next := make([]int, len(counts))
+
+	high := size - 1
+	for symbol, count := range counts {
+		if count == -1 {
+			table.entries[high].symbol = uint8(symbol)
+			high--
+			next[symbol] = 1
+		}
+	}
+
+	position := 0
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+
+	for symbol, count := range counts {
+		if count <= 0 {
+			continue
+		}
+
+		next[symbol] = int(count)
+
+		for i := 0; i < int(count); i++ {
+			table.entries[position].symbol = uint8(symbol)
+
+			position = (position + step) & mask
+			for position > high {
+				position = (position + step) & mask
+			}
+		}
+	}
+
+	if position != 0 {
+		return nil, corrupted("invalid FSE distribution")
+	}
+
+	for i := range table.entries {
+		entry := &table.entries[i]
+
+		state := next[entry.symbol]
+		next[entry.symbol]++
+
+		entry.bits = uint8(log - highBit(uint32(state)))
+		entry.base = uint16(state<<entry.bits - size)
+	}
+
+	return table, nil
+}
+
+func mustBuildFSETable(counts []int16, log uint) *fseTable {
+	table, err := buildFSETable(counts, log)
+	if err != nil {
+		panic(err)
+	}
+
+	return table
+}
+
+// rleFSETable returns table, which decodes specified symbol without
+// reading any bits.
+func rleFSETable(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+// readFSETable reads description of FSE table and returns the table along
+// with number of read bytes.
+func readFSETable(
+	data []byte, maxSymbol int, maxLog uint,
+) (*fseTable, int, error) {
+	reader := &forwardReader{data: data}
+
+	log := uint(reader.read(4)) + 5
+	if log > maxLog {
+		return nil, 0, corrupted("accuracy log %d is too large", log)
+	}
+
+	counts := []int16{}
+
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	bits := log + 1
+	previousZero := false
+
+	for remaining > 1 {
+		if previousZero {
+			zeros := 0
+			for {
+				repeat := int(reader.read(2))
+				zeros += repeat
+
+				if repeat != 3 {
+					break
+				}
+
+				if reader.bytes() > len(data) {
+					return nil, 0, corrupted("FSE table is truncated")
+				}
+			}
+
+			for i := 0; i < zeros; i++ {
+				counts = append(counts, 0)
+			}
+		}
+
+		if len(counts) > maxSymbol {
+			return nil, 0, corrupted("too many symbols in FSE table")
+		}
+
+		limit := 2*threshold - 1 - remaining
+
+		value := int(reader.peek(bits - 1))
+		if value < limit {
+			reader.skip(bits - 1)
+		} else {
+			value = int(reader.read(bits)) & (2*threshold - 1)
+			if value >= threshold {
+				value -= limit
+			}
+		}
+
+		count := value - 1
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+
+		counts = append(counts, int16(count))
+		previousZero = count == 0
+
+		for remaining < threshold && threshold > 1 {
+			bits--
+			threshold >>= 1
+		}
+
+		if reader.bytes() > len(data) {
+			return nil, 0, corrupted("FSE table is truncated")
+		}
+	}
+
+	if remaining != 1 {
+		return nil, 0, corrupted("invalid FSE distribution")
+	}
+
+	table, err := buildFSETable(counts, log)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, reader.bytes(), nil
+}
+
+// writeFSETable writes description of table with specified normalized
+// counts of symbols.
+func writeFSETable(counts []int16, log uint) []byte {
+	writer := &bitWriter{}
+
+	writer.write(uint64(log-5), 4)
+
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	bits := log + 1
+	previousZero := false
+
+	for symbol := 0; remaining > 1; {
+		if previousZero {
+			start := symbol
+			for counts[symbol] == 0 {
+				symbol++
+			}
+
+			zeros := symbol - start
+			for ; zeros >= 3; zeros -= 3 {
+				writer.write(3, 2)
+			}
+
+			writer.write(uint64(zeros), 2)
+		}
+
+		count := int(counts[symbol])
+		symbol++
+
+		value := count + 1
+		limit := 2*threshold - 1 - remaining
+
+		switch {
+		case value < limit:
+			writer.write(uint64(value), bits-1)
+		case value < threshold:
+			writer.write(uint64(value), bits)
+		default:
+			writer.write(uint64(value+limit), bits)
+		}
+
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+
+		previousZero = count == 0
+
+		for remaining < threshold && threshold > 1 {
+			bits--
+			threshold >>= 1
+		}
+	}
+
+	return writer.bytes()
+}
+
+// normalizeCounts scales counts of symbols, so they sum up to size of table
+// with specified accuracy log, keeping every used symbol.
+func normalizeCounts(counts []int, total int, log uint) []int16 {
+	size := 1 << log
+
+	normalized := make([]int16, len(counts))
+	sum := 0
+	largest := 0
+
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+
+		scaled := count * size / total
+		if scaled == 0 {
+			scaled = 1
+		}
+
+		normalized[symbol] = int16(scaled)
+		sum += scaled
+
+		if count > counts[largest] {
+			largest = symbol
+		}
+	}
+
+	if sum < size {
+		normalized[largest] += int16(size - sum)
+	}
+
+	// rounding up of rare symbols is taken from the most frequent ones
+	for sum > size {
+		symbol := 0
+		for i := range normalized {
+			if normalized[i] > normalized[symbol] {
+				symbol = i
+			}
+		}
+
+		normalized[symbol]--
+		sum--
+	}
+
+	return normalized
+}
+
+// tableLog returns accuracy log of table for specified number of encoded
+// symbols, of which specified number is distinct.
+func tableLog(total int, distinct int, maxLog uint) uint {
+	log := uint(5)
+	for log < maxLog && (1<<log < total || 1<<log < 2*distinct) {
+		log++
+	}
+
+	return log
+}
+
+// cost returns estimated number of bits, which symbols with specified
+// counts take when encoded by table with specified normalized counts, or
+// infinity if some symbol can't be encoded.
+func cost(counts []int, normalized []int16, log uint) float64 {
+	bits := 0.0
+
+	for symbol, count := range counts {
+		if count == 0 {
+			continue
+		}
+
+		if symbol >= len(normalized) || normalized[symbol] == 0 {
+			return math.Inf(1)
+		}
+
+		probability := float64(normalized[symbol])
+		if probability < 0 {
+			probability = 1
+		}
+
+		bits += float64(count) * (float64(log) - math.Log2(probability))
+	}
+
+	return bits
+}
+
+// fseEncoder finds states, which encode symbols of table.
+type fseEncoder struct {
+	table *fseTable
+
+	// states lists indexes of entries of every symbol sorted by base
+	states [][]int
+}
+
+func newFSEEncoder(table *fseTable) *fseEncoder {
+	encoder := &fseEncoder{table: table, states: make([][]int, 256)}
+
+	for i, entry := range table.entries {
+		encoder.states[entry.symbol] = append(
+			encoder.states[entry.symbol], i,
+		)
+	}
+
+	for _, states := range encoder.states {
+		sort.Slice(states, func(i, j int) bool {
+			return table.entries[states[i]].base <
+				table.entries[states[j]].base
+		})
+	}
+
+	return encoder
+}
+
+// first returns state of symbol, which reads the most bits to get to the
+// next state, so it's the state encoded last.
+func (encoder *fseEncoder) first(symbol uint8) int {
+	states := encoder.states[symbol]
+
+	widest := states[0]
+	for _, state := range states {
+		if encoder.table.entries[state].bits >
+			encoder.table.entries[widest].bits {
+			widest = state
+		}
+	}
+
+	return widest
+}
+
+// previous returns state of symbol, which is followed by specified state,
+// and bits to be read to get there.
+func (encoder *fseEncoder) previous(symbol uint8, next int) (int, bitField) {
+	states := encoder.states[symbol]
+
+	index := sort.Search(len(states), func(i int) bool {
+		return int(encoder.table.entries[states[i]].base) > next
+	}) - 1
+
+	state := states[index]
+	entry := encoder.table.entries[state]
+
+	return state, bitField{
+		value: uint64(next - int(entry.base)),
+		bits:  uint(entry.bits),
+	}
+}
@@ -0,0 +1,455 @@
+package zstd
+
+import (
+	"sort"
+)
+
+// huffmanTable decodes literal by the next maxBits bits of stream, which
+// begin with its prefix code.
+type huffmanTable struct {
+	maxBits uint
+	entries []huffmanEntry
+}
+
+type huffmanEntry struct {
+	symbol uint8
+	bits   uint8
+}
+
+// readHuffmanTable reads description of Huffman table and returns the table
+// along with number of read bytes.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corrupted("no Huffman table")
+	}
+
+	header := int(data[0])
+
+	var weights []uint8
+	var read int
+
+	if header >= 128 {
+		count := header - 127
+		read = 1 + (count+1)/2
+
+		if len(data) < read {
+			return nil, 0, corrupted("Huffman table is truncated")
+		}
+
+		weights = make([]uint8, count)
+		for i := range weights {
+			weights[i] = data[1+i/2] >> 4
+			if i%2 == 1 {
+				weights[i] = data[1+i/2] & 15
+			}
+		}
+	} else {
+		read = 1 + header
+
+		if len(data) < read {
+			return nil, 0, corrupted("Huffman table is truncated")
+		}
+
+		var err error
+
+		weights, err = readHuffmanWeights(data[1:read])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	table, err := buildHuffmanTable(weights)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, read, nil
+}
+
+// readHuffmanWeights decodes weights compressed by FSE with two states,
+// which are used in turns, until bitstream is over.
+func readHuffmanWeights(data []byte) ([]uint8, error) {
+	table, read, err := readFSETable(data, maxWeightSymbol, maxWeightLog)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newBackwardReader(data[read:])
+	if err != nil {
+		return nil, err
+	}
+
+	states := [2]int{
+		int(reader.read(table.log)),
+		int(reader.read(table.log)),
+	}
+
+	if reader.overflow() {
+		return nil, corrupted("Huffman weights are truncated")
+	}
+
+	weights := []uint8{}
+
+	for turn := 0; ; turn = 1 - turn {
+		if len(weights) >= 255 {
+			return nil, corrupted("too many Huffman weights")
+		}
+
+		entry := table.entries[states[turn]]
+		weights = append(weights, entry.symbol)
+		states[turn] = int(entry.base) + int(reader.read(uint(entry.bits)))
+
+		if reader.overflow() {
+			weights = append(weights, table.entries[states[1-turn]].symbol)
+			break
+		}
+	}
+
+	return weights, nil
+}
+
+// buildHuffmanTable builds decoding table from weights of all literals but
+// the last one, which weight is implied.
+func buildHuffmanTable(weights []uint8) (*huffmanTable, error) {
+	total := 0
+	for _, weight := range weights {
+		if weight > maxHuffmanBits+1 {
+			return nil, corrupted("invalid Huffman weight")
+		}
+
+		if weight > 0 {
+			total += 1 << (weight - 1)
+		}
+	}
+
+	if total == 0 {
+		return nil, corrupted("invalid Huffman weights")
+	}
+
+	maxBits := highBit(uint32(total)) + 1
+	if maxBits > maxHuffmanBits {
+		return nil, corrupted("Huffman code is too long")
+	}
+
+	rest := 1<<maxBits - total
+	if rest&(rest-1) != 0 {
+		return nil, corrupted("invalid Huffman weights")
+	}
+
+	weights = append(weights[:len(weights):len(weights)],
+		uint8(highBit(uint32(rest))+1))
+
+	if len(weights) > 256 {
+		return nil, corrupted("too many Huffman weights")
+	}
+
+	starts := huffmanStarts(weights, maxBits)
+
+	table := &huffmanTable{
+		maxBits: maxBits,
+		entries: make([]huffmanEntry, 1<<maxBits),
+	}
+
+	for symbol, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+
+		length := 1 << (weight - 1)
+		for i := starts[weight]; i < starts[weight]+length; i++ {
+			table.entries[i] = huffmanEntry{
+				symbol: uint8(symbol),
+				bits:   uint8(maxBits + 1 - uint(weight)),
+			}
+		}
+
+		starts[weight] += length
+	}
+
+	return table, nil
+}
+
+// huffmanStarts returns index of the first entry of decoding table for
+// every weight: entries are ordered by weight and then by literal.
+func huffmanStarts(weights []uint8, maxBits uint) []int {
+	starts := make([]int, maxBits+2)
+
+	counts := make([]int, maxBits+2)
+	for _, weight := range weights {
+		counts[weight]++
+	}
+
+	for weight := 1; weight <= int(maxBits); weight++ {
+		starts[weight+1] = starts[weight] + counts[weight]<<(weight-1)
+	}
+
+	return starts
+}
+
+// decode decodes stream of literals until out is filled.
+func (table *huffmanTable) decode(stream []byte, out []byte) error {
+	reader, err := newBackwardReader(stream)
+	if err != nil {
+		return err
+	}
+
+	for i := range out {
+		entry := table.entries[reader.peek(table.maxBits)]
+
+		out[i] = entry.symbol
+		reader.skip(uint(entry.bits))
+	}
+
+	if !reader.done() {
+		return corrupted("literals stream size mismatch")
+	}
+
+	return nil
+}
+
+// huffmanCode is the prefix code of literal.
+type huffmanCode struct {
+	code uint16
+	bits uint8
+}
+
+// huffmanEncoder holds prefix codes of literals and weights, which
+// describe them.
+type huffmanEncoder struct {
+	codes   [256]huffmanCode
+	weights []uint8
+}
+
+// newHuffmanEncoder builds prefix codes for literals with specified
+// counts, at least two of which should be used.
+func newHuffmanEncoder(counts []int) *huffmanEncoder {
+	lengths := huffmanLengths(counts, maxHuffmanBits)
+
+	maxBits := uint(0)
+	last := 0
+
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+
+		last = symbol
+
+		if uint(length) > maxBits {
+			maxBits = uint(length)
+		}
+	}
+
+	weights := make([]uint8, last+1)
+	for symbol, length := range lengths[:last+1] {
+		if length > 0 {
+			weights[symbol] = uint8(maxBits + 1 - uint(length))
+		}
+	}
+
+	encoder := &huffmanEncoder{weights: weights[:last]}
+
+	starts := huffmanStarts(weights, maxBits)
+	for symbol, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+
+		encoder.codes[symbol] = huffmanCode{
+			code: uint16(starts[weight] >> (weight - 1)),
+			bits: lengths[symbol],
+		}
+
+		starts[weight] += 1 << (weight - 1)
+	}
+
+	return encoder
+}
+
+// huffmanLengths returns lengths of prefix codes, which are not longer than
+// limit. Counts are flattened until Huffman code fits.
+func huffmanLengths(counts []int, limit int) []uint8 {
+	counts = append([]int{}, counts...)
+
+	for {
+		lengths, longest := huffmanTree(counts)
+		if longest <= limit {
+			return lengths
+		}
+
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+// huffmanTree returns lengths of Huffman code for specified counts along
+// with the longest one.
+func huffmanTree(counts []int) ([]uint8, int) {
+	type node struct {
+		count  int
+		parent int
+	}
+
+	nodes := []node{}
+	leaves := []int{}
+
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, parent: -1})
+			leaves = append(leaves, symbol)
+		}
+	}
+
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return counts[leaves[i]] < counts[leaves[j]]
+	})
+
+	for i, symbol := range leaves {
+		nodes[i] = node{count: counts[symbol], parent: -1}
+	}
+
+	// leaves and inner nodes are both sorted by count, so the two smallest
+	// nodes are found at heads of these queues
+	leaf, inner := 0, len(leaves)
+
+	smallest := func() int {
+		if leaf < len(leaves) &&
+			(inner >= len(nodes) || nodes[leaf].count <= nodes[inner].count) {
+			leaf++
+			return leaf - 1
+		}
+
+		inner++
+		return inner - 1
+	}
+
+	for len(nodes)-inner+len(leaves)-leaf > 1 {
+		left := smallest()
+		right := smallest()
+
+		nodes = append(nodes, node{
+			count:  nodes[left].count + nodes[right].count,
+			parent: -1,
+		})
+
+		nodes[left].parent = len(nodes) - 1
+		nodes[right].parent = len(nodes) - 1
+	}
+
+	depths := make([]int, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depths[i] = depths[nodes[i].parent] + 1
+	}
+
+	lengths := make([]uint8, len(counts))
+	longest := 0
+
+	for i, symbol := range leaves {
+		lengths[symbol] = uint8(depths[i])
+		if depths[i] > longest {
+			longest = depths[i]
+		}
+	}
+
+	return lengths, longest
+}
+
+// writeTable returns the shortest description of Huffman table, or nil if
+// weights can't be described.
+func (encoder *huffmanEncoder) writeTable() []byte {
+	weights := encoder.weights
+
+	var description []byte
+
+	compressed := writeHuffmanWeights(weights)
+	if compressed != nil && len(compressed) < 128 {
+		description = append([]byte{byte(len(compressed))}, compressed...)
+	}
+
+	// weights of literals, which are used in text, are compressed well,
+	// but small number of weights is cheaper to store as is
+	if len(weights) <= 128 && (description == nil ||
+		len(description) > 1+(len(weights)+1)/2) {
+		description = make([]byte, 1+(len(weights)+1)/2)
+		description[0] = byte(127 + len(weights))
+
+		for i, weight := range weights {
+			if i%2 == 0 {
+				description[1+i/2] = weight << 4
+			} else {
+				description[1+i/2] |= weight
+			}
+		}
+	}
+
+	return description
+}
+
+// writeHuffmanWeights compresses weights by FSE with two states used in
+// turns, or returns nil if all weights are the same.
+func writeHuffmanWeights(weights []uint8) []byte {
+	counts := make([]int, maxWeightSymbol+1)
+	distinct := 0
+
+	for _, weight := range weights {
+		if counts[weight] == 0 {
+			distinct++
+		}
+
+		counts[weight]++
+	}
+
+	if distinct < 2 {
+		return nil
+	}
+
+	log := tableLog(len(weights), distinct, maxWeightLog)
+	normalized := normalizeCounts(counts, len(weights), log)
+
+	table, err := buildFSETable(normalized, log)
+	if err != nil {
+		panic(err)
+	}
+
+	encoder := newFSEEncoder(table)
+
+	// decoder uses states in turns and stops when it reads past the
+	// beginning of stream while getting state after the last but one
+	// weight, so states of two last weights are chosen freely, but the
+	// last but one should read at least one bit
+	count := len(weights)
+	states := make([]int, count)
+	updates := make([]bitField, count)
+
+	states[count-1] = encoder.first(weights[count-1])
+	states[count-2] = encoder.first(weights[count-2])
+
+	for i := count - 3; i >= 0; i-- {
+		states[i], updates[i] = encoder.previous(weights[i], states[i+2])
+	}
+
+	fields := []bitField{
+		{value: uint64(states[0]), bits: log},
+		{value: uint64(states[1]), bits: log},
+	}
+
+	fields = append(fields, updates[:count-2]...)
+
+	return append(writeFSETable(normalized, log), writeBackward(fields)...)
+}
+
+// encode writes literals into stream, which is decoded by table described
+// by weights of encoder.
+func (encoder *huffmanEncoder) encode(literals []byte) []byte {
+	writer := &bitWriter{}
+
+	for i := len(literals) - 1; i >= 0; i-- {
+		code := encoder.codes[literals[i]]
+		writer.write(uint64(code.code), uint(code.bits))
+	}
+
+	return writer.close()
+}
@@ -0,0 +1,80 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 returns XXH64 hash of data with zero seed, lower 32 bits of
+// which are used as checksum of frame content.
+func xxhash64(data []byte) uint64 {
+	length := uint64(len(data))
+
+	var hash uint64
+
+	if len(data) >= 32 {
+		v1 := prime1
+		v1 += prime2
+		v2 := prime2
+		v3 := uint64(0)
+		v4 := v3 - prime1
+
+		for ; len(data) >= 32; data = data[32:] {
+			v1 = xxhashRound(v1, binary.LittleEndian.Uint64(data[0:]))
+			v2 = xxhashRound(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxhashRound(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxhashRound(v4, binary.LittleEndian.Uint64(data[24:]))
+		}
+
+		hash = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+
+		for _, v := range []uint64{v1, v2, v3, v4} {
+			hash ^= xxhashRound(0, v)
+			hash = hash*prime1 + prime4
+		}
+	} else {
+		hash = prime5
+	}
+
+	hash += length
+
+	for ; len(data) >= 8; data = data[8:] {
+		hash ^= xxhashRound(0, binary.LittleEndian.Uint64(data))
+		hash = bits.RotateLeft64(hash, 27)*prime1 + prime4
+	}
+
+	if len(data) >= 4 {
+		hash ^= uint64(binary.LittleEndian.Uint32(data)) * prime1
+		hash = bits.RotateLeft64(hash, 23)*prime2 + prime3
+		data = data[4:]
+	}
+
+	for _, b := range data {
+		hash ^= uint64(b) * prime5
+		hash = bits.RotateLeft64(hash, 11) * prime1
+	}
+
+	hash ^= hash >> 33
+	hash *= prime2
+	hash ^= hash >> 29
+	hash *= prime3
+	hash ^= hash >> 32
+
+	return hash
+}
+
+func xxhashRound(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+
+	return acc * prime1
+}
@@ -0,0 +1,275 @@
+// Package zstd implements Zstandard compression format described in
+// RFC 8878, including raw and formatted dictionaries.
+//
+// Decompressor reads frames written by any conforming compressor.
+// Compressor is simple: it writes single frame with content size and
+// without checksum, finds matches by hash chains and doesn't reuse entropy
+// tables between blocks, so it suits separately compressed small files,
+// where dictionary matters more than compression level.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrCorrupted = errors.New("zstd: corrupted data")
+	ErrTooLarge  = errors.New("zstd: decompressed data is too large")
+)
+
+const (
+	frameMagic      = 0xFD2FB528
+	dictionaryMagic = 0xEC30A437
+
+	// skippable frames have magic with any value of the lowest 4 bits
+	skippableMagic = 0x184D2A50
+	skippableMask  = 0xFFFFFFF0
+
+	maxBlockSize = 128 * 1024
+
+	// maximum accuracy logs of FSE tables
+	maxLiteralLengthLog = 9
+	maxMatchLengthLog   = 9
+	maxOffsetLog        = 8
+	maxWeightLog        = 6
+
+	// maximum symbols of FSE tables
+	maxLiteralLengthSymbol = 35
+	maxMatchLengthSymbol   = 52
+	maxOffsetSymbol        = 31
+	maxWeightSymbol        = 12
+
+	// maxHuffmanBits is the maximum length of prefix code of literal.
+	maxHuffmanBits = 11
+)
+
+const (
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+	literalsTreeless   = 3
+
+	modePredefined = 0
+	modeRLE        = 1
+	modeCompressed = 2
+	modeRepeat     = 3
+)
+
+// literal length and match length codes map to baseline values and number
+// of additional bits.
+var (
+	literalLengthBase = [maxLiteralLengthSymbol + 1]int{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048,
+		4096, 8192, 16384, 32768, 65536,
+	}
+
+	literalLengthBits = [maxLiteralLengthSymbol + 1]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+
+	matchLengthBase = [maxMatchLengthSymbol + 1]int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027,
+		2051, 4099, 8195, 16387, 32771, 65539,
+	}
+
+	matchLengthBits = [maxMatchLengthSymbol + 1]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10,
+		11, 12, 13, 14, 15, 16,
+	}
+)
+
+// predefined distributions are used by sequences compressed in predefined
+// mode.
+var (
+	predefinedLiteralLengths = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+
+	predefinedMatchLengths = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+
+	predefinedOffsets = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	predefinedLiteralLengthTable = mustBuildFSETable(
+		predefinedLiteralLengths, 6,
+	)
+
+	predefinedMatchLengthTable = mustBuildFSETable(predefinedMatchLengths, 6)
+
+	predefinedOffsetTable = mustBuildFSETable(predefinedOffsets, 5)
+)
+
+// Dictionary holds content, which compressed data can refer to as if it
+// preceded the data, and, for formatted dictionaries, initial entropy
+// tables and repeated offsets.
+type Dictionary struct {
+	id      uint32
+	content []byte
+	repeats repeatedOffsets
+
+	literals      *huffmanTable
+	literalLength *fseTable
+	matchLength   *fseTable
+	offset        *fseTable
+
+	// index of content is built on first compression and shared by all
+	// following ones
+	indexOnce sync.Once
+	index     *matchIndex
+}
+
+// NewDictionary parses dictionary. Dictionaries which start with magic
+// number are formatted ones, like written by zstd --train, any other data
+// is used as raw content.
+func NewDictionary(data []byte) (*Dictionary, error) {
+	dict := &Dictionary{
+		content: data,
+		repeats: initialRepeats,
+	}
+
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictionaryMagic {
+		return dict, nil
+	}
+
+	dict.id = binary.LittleEndian.Uint32(data[4:])
+
+	rest := data[8:]
+
+	literals, read, err := readHuffmanTable(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	rest = rest[read:]
+
+	tables := []struct {
+		table     **fseTable
+		maxSymbol int
+		maxLog    uint
+	}{
+		{&dict.offset, maxOffsetSymbol, maxOffsetLog},
+		{&dict.matchLength, maxMatchLengthSymbol, maxMatchLengthLog},
+		{&dict.literalLength, maxLiteralLengthSymbol, maxLiteralLengthLog},
+	}
+
+	for _, table := range tables {
+		*table.table, read, err = readFSETable(
+			rest, table.maxSymbol, table.maxLog,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rest = rest[read:]
+	}
+
+	if len(rest) < 12 {
+		return nil, corrupted("dictionary is truncated")
+	}
+
+	dict.literals = literals
+	dict.content = rest[12:]
+
+	for i := range dict.repeats {
+		offset := int(binary.LittleEndian.Uint32(rest[i*4:]))
+		if offset == 0 || offset > len(dict.content) {
+			return nil, corrupted("invalid repeated offset of dictionary")
+		}
+
+		dict.repeats[i] = offset
+	}
+
+	return dict, nil
+}
+
+// repeatedOffsets holds last three offsets, which sequences can refer to
+// by short codes.
+type repeatedOffsets [3]int
+
+var initialRepeats = repeatedOffsets{1, 4, 8}
+
+// resolve returns offset encoded by specified offset value of sequence
+// with specified literal length and updates repeated offsets. Zero offset
+// is returned for invalid repeat.
+func (repeats *repeatedOffsets) resolve(value int, literalLength int) int {
+	if value > 3 {
+		repeats[2], repeats[1], repeats[0] = repeats[1], repeats[0], value-3
+
+		return value - 3
+	}
+
+	index := value - 1
+	if literalLength == 0 {
+		index++
+	}
+
+	var offset int
+
+	switch index {
+	case 0:
+		return repeats[0]
+	case 1:
+		offset = repeats[1]
+		repeats[1] = repeats[0]
+	case 2:
+		offset = repeats[2]
+		repeats[2] = repeats[1]
+		repeats[1] = repeats[0]
+	case 3:
+		offset = repeats[0] - 1
+		repeats[2] = repeats[1]
+		repeats[1] = repeats[0]
+	}
+
+	repeats[0] = offset
+
+	return offset
+}
+
+// encode returns offset value, which refers to specified offset by repeat
+// code if possible, and updates repeated offsets.
+func (repeats *repeatedOffsets) encode(offset int, literalLength int) int {
+	candidates := [3]int{repeats[0], repeats[1], repeats[2]}
+	if literalLength == 0 {
+		candidates = [3]int{repeats[1], repeats[2], repeats[0] - 1}
+	}
+
+	value := offset + 3
+	for i, candidate := range candidates {
+		if candidate == offset {
+			value = i + 1
+			break
+		}
+	}
+
+	repeats.resolve(value, literalLength)
+
+	return value
+}
+
+func corrupted(format string, args ...interface{}) error {
+	return fmt.Errorf(`%w: `+format, append([]interface{}{ErrCorrupted}, args...)...)
+}
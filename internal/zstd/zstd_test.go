@@ -0,0 +1,156 @@
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCanDecompressFramesOfReferenceCompressor(t *testing.T) {
+	dictionary, err := NewDictionary(readFixture("_test/sample.dict"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dictionary.id == 0 || dictionary.literals == nil {
+		t.Fatalf("dictionary is not parsed as formatted one")
+	}
+
+	tests := []struct {
+		compressed string
+		plain      string
+		dictionary *Dictionary
+	}{
+		{"_test/small.txt.zst", "_test/small.txt", nil},
+		{"_test/sample.json.zst", "_test/sample.json", dictionary},
+	}
+
+	for _, test := range tests {
+		plain := readFixture(test.plain)
+
+		decompressed, err := Decompress(
+			readFixture(test.compressed), test.dictionary, len(plain),
+		)
+		if err != nil {
+			t.Fatalf("%s: %s", test.compressed, err)
+		}
+
+		if !bytes.Equal(decompressed, plain) {
+			t.Fatalf("%s: unexpected contents: %q", test.compressed, decompressed)
+		}
+	}
+
+	_, err = Decompress(readFixture("_test/sample.json.zst"), nil, 1024)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("frame is decompressed without dictionary: %v", err)
+	}
+}
+
+func TestCanCompressAndDecompress(t *testing.T) {
+	random := make([]byte, 200*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	text := []byte(strings.Repeat(string(readFixture("_test/small.txt")), 1000))
+
+	inputs := map[string][]byte{
+		"empty":  {},
+		"byte":   {'x'},
+		"rle":    bytes.Repeat([]byte{'x'}, 300*1024),
+		"text":   text,
+		"random": random,
+		"mixed":  append(append([]byte{}, random[:100*1024]...), text...),
+	}
+
+	formatted, err := NewDictionary(readFixture("_test/sample.dict"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := NewDictionary(readFixture("_test/small.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, input := range inputs {
+		for _, dictionary := range []*Dictionary{nil, raw, formatted} {
+			compressed := Compress(input, dictionary)
+
+			decompressed, err := Decompress(compressed, dictionary, len(input))
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+
+			if !bytes.Equal(decompressed, input) {
+				t.Fatalf("%s: contents differ after decompression", name)
+			}
+		}
+	}
+
+	if size := len(Compress(text, nil)); size > len(text)/20 {
+		t.Fatalf("text is compressed poorly: %d bytes", size)
+	}
+}
+
+func TestCanCompressSmallFilesWithDictionary(t *testing.T) {
+	dictionary, err := NewDictionary(readFixture("_test/sample.dict"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := readFixture("_test/sample.json")
+
+	with := Compress(plain, dictionary)
+	without := Compress(plain, nil)
+
+	if len(with) >= len(without)/2 {
+		t.Fatalf(
+			"dictionary doesn't help: %d bytes with it, %d without",
+			len(with), len(without),
+		)
+	}
+}
+
+func TestCanNotDecompressMoreThanLimit(t *testing.T) {
+	plain := readFixture("_test/small.txt")
+
+	_, err := Decompress(Compress(plain, nil), nil, len(plain)-1)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = Decompress(readFixture("_test/small.txt.zst"), nil, len(plain)-1)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanNotDecompressCorruptedFrames(t *testing.T) {
+	compressed := readFixture("_test/small.txt.zst")
+
+	for i := range compressed {
+		corrupted := append([]byte{}, compressed...)
+		corrupted[i] ^= 0x55
+
+		decompressed, err := Decompress(corrupted, nil, 1024)
+		if err == nil && bytes.Equal(decompressed, readFixture("_test/small.txt")) {
+			t.Fatalf("corruption at %d is not noticed", i)
+		}
+	}
+
+	_, err := Decompress(compressed[:len(compressed)-1], nil, 1024)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func readFixture(path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
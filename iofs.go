@@ -0,0 +1,258 @@
+package embedfs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// files opened via fs.FS should be usable with http.ServeContent, which
+// requires io.ReadSeeker and real size of file for ranges handling
+var _ interface {
+	iofs.File
+	io.ReadSeeker
+	io.ReaderAt
+} = (*embedFileReader)(nil)
+
+type fsAdapter struct {
+	fs *EmbedFs
+}
+
+type fsDirectory struct {
+	info    fsDirectoryInfo
+	entries []iofs.DirEntry
+	offset  int
+}
+
+type fsDirectoryInfo struct {
+	name string
+}
+
+// FS returns embedded fs as fs.FS, so it can be used with functions from
+// standard library like fs.WalkDir, http.FS or template.ParseFS.
+//
+// Directories are derived from paths of embedded files and are listed in
+// lexical order. Opened files implement io.ReaderAt and io.Seeker and report
+// real sizes, so they can be served by http.ServeContent or http.FileServer
+// with correct Content-Length and range requests support.
+func (fs *EmbedFs) FS() iofs.FS {
+	return fsAdapter{fs: fs}
+}
+
+// OrDir returns fs.FS, which reads files from directory dir on disk if
+// useDisk is true, and from embedded fs otherwise, so application code is
+// the same in development, where files are edited in place, and in
+// production. Usually useDisk is set by command line flag or environment
+// variable.
+//
+// Embedded fs may be nil if useDisk is true, so development binaries can
+// be run without embedding anything.
+func OrDir(fs *EmbedFs, dir string, useDisk bool) iofs.FS {
+	if useDisk {
+		return os.DirFS(dir)
+	}
+
+	return fs.FS()
+}
+
+func (adapter fsAdapter) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{
+			Op: "open", Path: name, Err: iofs.ErrInvalid,
+		}
+	}
+
+	started := time.Now()
+	full := path.Join("/", name)
+
+	entry, err := adapter.fs.lookup(full)
+	if err == nil {
+		reader, err := adapter.fs.openEntry(entry, full, started)
+		if err != nil {
+			return nil, err
+		}
+
+		return reader, nil
+	}
+
+	if !errors.Is(err, ErrNoExist) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	entries, err := adapter.readDir(full)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &fsDirectory{
+		info:    fsDirectoryInfo{name: path.Base(name)},
+		entries: entries,
+	}, nil
+}
+
+func (adapter fsAdapter) ReadDir(name string) ([]iofs.DirEntry, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{
+			Op: "readdir", Path: name, Err: iofs.ErrInvalid,
+		}
+	}
+
+	entries, err := adapter.readDir(path.Join("/", name))
+	if err != nil {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+func (adapter fsAdapter) ReadFile(name string) ([]byte, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{
+			Op: "readfile", Path: name, Err: iofs.ErrInvalid,
+		}
+	}
+
+	return adapter.fs.ReadFile(path.Join("/", name))
+}
+
+func (adapter fsAdapter) Stat(name string) (iofs.FileInfo, error) {
+	file, err := adapter.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return file.Stat()
+}
+
+// readDir returns entries of specified directory, sorted by name. Entries
+// are found by looking for files which paths are prefixed by directory.
+func (adapter fsAdapter) readDir(dir string) ([]iofs.DirEntry, error) {
+	err := adapter.fs.load()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	index := adapter.fs.index
+
+	start := sort.Search(len(index), func(i int) bool {
+		return index[i].name >= prefix
+	})
+
+	entries := []iofs.DirEntry{}
+	positions := map[string]int{}
+
+	for _, entry := range index[start:] {
+		if !strings.HasPrefix(entry.name, prefix) {
+			break
+		}
+
+		if entry.hidden {
+			continue
+		}
+
+		child, _, isDirectory := strings.Cut(entry.name[len(prefix):], "/")
+
+		var dirEntry iofs.DirEntry
+		if isDirectory {
+			dirEntry = iofs.FileInfoToDirEntry(fsDirectoryInfo{name: child})
+		} else {
+			header, err := adapter.fs.header(entry)
+			if err != nil {
+				return nil, err
+			}
+
+			dirEntry = iofs.FileInfoToDirEntry(header.FileInfo())
+		}
+
+		// index is sorted stable, so later entry with the same name
+		// shadows previous one
+		position, seen := positions[child]
+		if seen {
+			if !isDirectory {
+				entries[position] = dirEntry
+			}
+
+			continue
+		}
+
+		positions[child] = len(entries)
+		entries = append(entries, dirEntry)
+	}
+
+	if len(entries) == 0 && dir != "/" {
+		return nil, ErrNoExist
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (directory *fsDirectory) Stat() (iofs.FileInfo, error) {
+	return directory.info, nil
+}
+
+func (directory *fsDirectory) Read([]byte) (int, error) {
+	return 0, &iofs.PathError{
+		Op: "read", Path: directory.info.name, Err: iofs.ErrInvalid,
+	}
+}
+
+func (directory *fsDirectory) Close() error {
+	return nil
+}
+
+func (directory *fsDirectory) ReadDir(count int) ([]iofs.DirEntry, error) {
+	rest := directory.entries[directory.offset:]
+
+	if count <= 0 {
+		directory.offset += len(rest)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(rest) {
+		count = len(rest)
+	}
+
+	directory.offset += count
+
+	return rest[:count], nil
+}
+
+func (info fsDirectoryInfo) Name() string {
+	return info.name
+}
+
+func (info fsDirectoryInfo) Size() int64 {
+	return 0
+}
+
+func (info fsDirectoryInfo) Mode() iofs.FileMode {
+	return iofs.ModeDir | 0555
+}
+
+func (info fsDirectoryInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (info fsDirectoryInfo) IsDir() bool {
+	return true
+}
+
+func (info fsDirectoryInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,138 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanUseAsStandardFs(t *testing.T) {
+	container := mockfile.New("lala28")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("embedfs.go", "/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fstest.TestFS(fs.FS(), "a/1", "b/2", "embedfs.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanServeRangesOfFiles(t *testing.T) {
+	container := mockfile.New("lala31")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("embedfs.go", "/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	expected, err := ioutil.ReadFile("embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	handler := http.FileServer(http.FS(fs.FS()))
+
+	request := httptest.NewRequest("GET", "/embedfs.go", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Header().Get("Content-Length") !=
+		strconv.Itoa(len(expected)) {
+		t.Fatalf(
+			"unexpected content length: %s",
+			recorder.Header().Get("Content-Length"),
+		)
+	}
+
+	request = httptest.NewRequest("GET", "/embedfs.go", nil)
+	request.Header.Set("Range", "bytes=100-199")
+	recorder = httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusPartialContent {
+		t.Fatalf("unexpected status code: %d", recorder.Code)
+	}
+
+	if recorder.Body.String() != string(expected[100:200]) {
+		t.Fatalf("unexpected range contents: %q", recorder.Body.String())
+	}
+}
+
+func TestCanSwitchBetweenDiskAndEmbedfs(t *testing.T) {
+	container := mockfile.New("lala78")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fstest.TestFS(OrDir(fs, "_test", false), "a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(OrDir(nil, "_test", true), "a/1", "b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
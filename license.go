@@ -0,0 +1,76 @@
+package embedfs
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// licensesDir is the directory where license files collected by
+// CollectLicenses option are stored under their original paths.
+const licensesDir = internalDir + "/licenses"
+
+var licenseNames = []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"}
+
+// collectLicense embeds copy of the file into licenses directory, if it's
+// license file and CollectLicenses option is set.
+func (e *Embedder) collectLicense(source, target string) error {
+	target = filepath.Join("/", target)
+
+	if !e.options.CollectLicenses || !isLicense(target) ||
+		strings.HasPrefix(target, licensesDir+"/") {
+		return nil
+	}
+
+	return e.EmbedFile(source, licensesDir+target)
+}
+
+func isLicense(name string) bool {
+	base := strings.ToUpper(path.Base(name))
+
+	for _, license := range licenseNames {
+		if strings.HasPrefix(base, license) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteLicenses writes contents of all license files collected by
+// CollectLicenses option, each preceded by its original path.
+func (fs *EmbedFs) WriteLicenses(w io.Writer) error {
+	names, err := fs.listAll(licensesDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range uniqueNames(names) {
+		_, err = fmt.Fprintf(w, "==> %s <==\n", strings.TrimPrefix(
+			name, licensesDir,
+		))
+		if err != nil {
+			return err
+		}
+
+		file, err := fs.Open(name)
+		if err != nil {
+			return err
+		}
+
+		_, err = copyBuffered(w, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package embedfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanCollectLicenses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-licenses")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, "vendor", "lib"), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	for name, contents := range map[string]string{
+		"vendor/lib/lib.js":      "lib()",
+		"vendor/lib/LICENSE.txt": "MIT",
+	} {
+		err = ioutil.WriteFile(
+			filepath.Join(dir, name), []byte(contents), 0644,
+		)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	container := mockfile.New("lala66")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		CollectLicenses: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory(dir, "/static")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	licenses := &bytes.Buffer{}
+
+	err = fs.WriteLicenses(licenses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "==> /static/vendor/lib/LICENSE.txt <==\nMIT\n"
+	if licenses.String() != expected {
+		t.Fatalf("unexpected licenses: %q", licenses.String())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package embedfs
+
+// lockFile is no-op on systems without flock, so concurrent extractions are
+// guarded only by atomic renames.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
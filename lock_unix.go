@@ -0,0 +1,28 @@
+//go:build unix
+
+package embedfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile acquires exclusive lock of the file with specified path, creating
+// it if needed, and returns function which releases the lock.
+func lockFile(path string) (func(), error) {
+	lock, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
@@ -0,0 +1,15 @@
+package embedfs
+
+// Logger receives messages about recoverable anomalies, like skipped
+// malformed entries or fallbacks taken. Arguments are key-value pairs.
+//
+// *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(message string, args ...interface{})
+	Warn(message string, args ...interface{})
+}
+
+type noLogger struct{}
+
+func (noLogger) Debug(string, ...interface{}) {}
+func (noLogger) Warn(string, ...interface{})  {}
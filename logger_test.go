@@ -0,0 +1,7 @@
+package embedfs
+
+import (
+	"log/slog"
+)
+
+var _ Logger = slog.Default()
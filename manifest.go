@@ -0,0 +1,52 @@
+package embedfs
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ManifestEntry describes single file in manifest written by ManifestJSON.
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modtime"`
+	SHA256  string    `json:"sha256,omitempty"`
+}
+
+// ManifestJSON writes JSON array describing all files in embedded fs, one
+// ManifestEntry per file, in the order they was added.
+func (fs *EmbedFs) ManifestJSON(w io.Writer) error {
+	names, err := fs.ListDir("/")
+	if err != nil {
+		return err
+	}
+
+	manifest := []ManifestEntry{}
+
+	for _, name := range uniqueNames(names) {
+		entry, err := fs.lookup(name)
+		if err != nil {
+			return err
+		}
+
+		header, err := fs.header(entry)
+		if err != nil {
+			return err
+		}
+
+		manifest = append(manifest, ManifestEntry{
+			Path:    name,
+			Size:    entry.size,
+			Mode:    header.FileInfo().Mode().String(),
+			ModTime: header.ModTime.UTC(),
+			SHA256:  header.PAXRecords[paxChecksum],
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(manifest)
+}
@@ -0,0 +1,57 @@
+package embedfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanWriteManifest(t *testing.T) {
+	container := mockfile.New("lala18")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	buffer := &bytes.Buffer{}
+
+	err = fs.ManifestJSON(buffer)
+	if err != nil {
+		panic(err)
+	}
+
+	manifest := []ManifestEntry{}
+
+	err = json.Unmarshal(buffer.Bytes(), &manifest)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(manifest) != 2 || manifest[0].Path != "/a/1" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	// sha256 of "1\n"
+	expected := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+	if manifest[0].SHA256 != expected {
+		t.Fatalf("unexpected checksum of </a/1>: %s", manifest[0].SHA256)
+	}
+}
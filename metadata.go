@@ -0,0 +1,25 @@
+package embedfs
+
+import (
+	"errors"
+)
+
+var ErrMetadataOnly = errors.New("embedfs is opened for metadata only")
+
+// metadataOnly is the source of data of all entries of embedfs opened by
+// OpenMetadata, which refuses to read anything.
+type metadataOnly struct{}
+
+// OpenMetadata works like Open, but only reads index of embedfs, so files
+// can be listed and stated, while reading of their contents fails with
+// ErrMetadataOnly. It's meant for audit and inventory tools, which inspect
+// lots of binaries and must not touch embedded data. Embedfs written with
+// Stargz option still has to be decompressed to be listed.
+func OpenMetadata(origin file) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{MetadataOnly: true})
+}
+
+// ReadAt always fails with ErrMetadataOnly.
+func (metadataOnly) ReadAt(p []byte, off int64) (int, error) {
+	return 0, ErrMetadataOnly
+}
@@ -0,0 +1,66 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanOpenMetadataOnly(t *testing.T) {
+	container := mockfile.New("lala87")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenMetadata(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 2 {
+		t.Fatalf("unexpected listing: %v", names)
+	}
+
+	info, err := fs.Stat("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 2 {
+		t.Fatalf("unexpected size of </a/1>: %d", info.Size())
+	}
+
+	file, err := fs.Open("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ioutil.ReadAll(file)
+	if !errors.Is(err, ErrMetadataOnly) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = fs.ReadFile("/b/2")
+	if !errors.Is(err, ErrMetadataOnly) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package embedfs
+
+import (
+	"strings"
+	"time"
+)
+
+// Metrics receives notifications about embedfs activity, so it can be
+// exported to the monitoring system like Prometheus.
+//
+// Implementations should aggregate paths by prefix (see PathPrefix) to
+// keep number of label values bounded.
+type Metrics interface {
+	// FileOpened is called after file is opened from embedded fs.
+	FileOpened(path string, latency time.Duration)
+
+	// BytesRead is called after data is read from embedded file.
+	BytesRead(path string, n int)
+
+	// CacheHit is called when data is served from cache instead of
+	// origin. Path is "/" for the index cache.
+	CacheHit(path string)
+
+	// VerificationFailed is called when checksum of embedded file does not
+	// match stored one.
+	VerificationFailed(path string)
+}
+
+type noMetrics struct{}
+
+func (noMetrics) FileOpened(string, time.Duration) {}
+func (noMetrics) BytesRead(string, int)            {}
+func (noMetrics) CacheHit(string)                  {}
+func (noMetrics) VerificationFailed(string)        {}
+
+// PathPrefix returns first depth components of the specified path, e.g.
+// PathPrefix("/static/js/app.js", 1) will return "/static".
+func PathPrefix(path string, depth int) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", depth+1)
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
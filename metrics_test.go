@@ -0,0 +1,104 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+type testMetrics struct {
+	sync.Mutex
+	opens int
+	bytes int
+}
+
+func (metrics *testMetrics) FileOpened(string, time.Duration) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	metrics.opens++
+}
+
+func (metrics *testMetrics) BytesRead(path string, n int) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	metrics.bytes += n
+}
+
+func (metrics *testMetrics) CacheHit(string)           {}
+func (metrics *testMetrics) VerificationFailed(string) {}
+
+func TestCanReportMetrics(t *testing.T) {
+	container := mockfile.New("lala12")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	metrics := &testMetrics{}
+
+	fs, err := OpenWithOptions(container, OpenOptions{Metrics: metrics})
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := fs.Open("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = ioutil.ReadAll(f)
+	if err != nil {
+		panic(err)
+	}
+
+	if metrics.opens != 1 || metrics.bytes != 2 {
+		t.Fatalf(
+			"unexpected metrics: %d opens, %d bytes",
+			metrics.opens, metrics.bytes,
+		)
+	}
+
+	// files opened via fs.FS are counted too
+	standard, err := fs.FS().Open("b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	standard.Close()
+
+	if metrics.opens != 2 {
+		t.Fatalf("unexpected metrics: %d opens", metrics.opens)
+	}
+}
+
+func TestCanGetPathPrefix(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/static/js/app.js": "/static",
+		"/app.js":           "/app.js",
+		"/":                 "/",
+	} {
+		actual := PathPrefix(path, 1)
+		if actual != expected {
+			t.Fatalf(
+				"prefix of <%s> is <%s>, expected <%s>",
+				path, actual, expected,
+			)
+		}
+	}
+}
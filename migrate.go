@@ -0,0 +1,214 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// LatestFormatVersion is the version of embedfs format written by this
+// library.
+const LatestFormatVersion = formatVersion
+
+// Migrate rewrites embedfs stored in the end of origin in the specified
+// format version, so payloads of already deployed binaries can be upgraded
+// in place. Every file is embedded again, so checksums and content types
+// are calculated for files which were embedded without them. Label, version,
+// build metadata and attributes of embedfs are preserved.
+//
+// Original embedfs is copied to temporary file first and is written back
+// to origin if migration fails. Multi-volume embedfs and embedfs with
+// encrypted or compressed files, or written with Stargz or Squashfs
+// option, can't be migrated.
+func Migrate(origin file, targetVersion int) error {
+	if targetVersion > formatVersion {
+		return &FormatVersionError{
+			Version:   targetVersion,
+			Supported: formatVersion,
+		}
+	}
+
+	if targetVersion < formatVersion {
+		return fmt.Errorf(
+			`%w: migration to older format version %03d`,
+			ErrNotImplemented, targetVersion,
+		)
+	}
+
+	fs, err := Open(origin)
+	if err != nil {
+		return err
+	}
+
+	err = fs.load()
+	if err != nil {
+		return err
+	}
+
+	if len(fs.volumes) > 0 {
+		return fmt.Errorf(
+			`%w: migration of multi-volume embedfs`, ErrNotImplemented,
+		)
+	}
+
+	for _, entry := range fs.files {
+		if entry.encrypted != nil || entry.compressed != nil ||
+			entry.gzipped != nil || entry.squashed != nil {
+			return fmt.Errorf(
+				`%w: migration of encoded file <%s>`,
+				ErrNotImplemented, entry.name,
+			)
+		}
+	}
+
+	records, err := readGlobalHeader(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return err
+	}
+
+	attributes := map[string]string{}
+	fs.readAttributes(attributes)
+
+	spool, err := ioutil.TempFile("", "embedfs-migrate")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	stat, err := origin.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(
+		spool, io.NewSectionReader(origin, fs.offset, stat.Size()-fs.offset),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = fs.rewrite(spool, records, attributes)
+	if err == nil {
+		return nil
+	}
+
+	restoreErr := restoreSpool(origin, spool, fs.offset)
+	if restoreErr != nil {
+		return fmt.Errorf(
+			`can't restore embedfs after failed migration: %s: %w`,
+			restoreErr, err,
+		)
+	}
+
+	return err
+}
+
+// rewrite truncates embedfs from origin and embeds all files again, reading
+// their contents from spool, which holds copy of original embedfs.
+func (fs *EmbedFs) rewrite(
+	spool io.ReaderAt, records map[string]string, attributes map[string]string,
+) error {
+	err := fs.origin.Truncate(fs.offset)
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.origin.Seek(fs.offset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	options := EmbedOptions{
+		Label:         records[paxLabel],
+		Version:       records[paxVersion],
+		BuildMetadata: map[string]string{},
+	}
+
+	for key, value := range records {
+		name := strings.TrimPrefix(key, paxMetadataPrefix)
+		if name != key {
+			options.BuildMetadata[name] = value
+		}
+	}
+
+	embedder, err := CreateWithOptions(fs.origin, options)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range attributes {
+		embedder.SetAttr(key, value)
+	}
+
+	for _, entry := range fs.files {
+		// packed files are stored along with their solid block
+		if entry.member {
+			continue
+		}
+
+		header, err := fs.header(entry)
+		if err != nil {
+			return err
+		}
+
+		migrated := *header
+		migrated.Format = tar.FormatUnknown
+		migrated.PAXRecords = map[string]string{}
+
+		// only regular files stored in origin have contents, which are
+		// described again; links and external files are stored as is
+		var content io.ReadSeeker
+		if entry.external == nil && header.Typeflag == tar.TypeReg {
+			content = io.NewSectionReader(
+				spool, entry.offset-fs.offset, entry.size,
+			)
+		}
+
+		if entry.external != nil {
+			migrated.Size = 0
+		}
+
+		for key, value := range header.PAXRecords {
+			if content != nil && (key == paxChecksum || key == paxContentType) {
+				continue
+			}
+
+			migrated.PAXRecords[key] = value
+		}
+
+		err = embedder.embedContent(&migrated, entry.name, entry.name, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return embedder.Close()
+}
+
+// restoreSpool writes original embedfs from spool back to origin at
+// specified offset.
+func restoreSpool(origin file, spool io.ReadSeeker, offset int64) error {
+	err := origin.Truncate(offset)
+	if err != nil {
+		return err
+	}
+
+	_, err = origin.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	_, err = spool.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(origin, spool)
+
+	return err
+}
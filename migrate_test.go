@@ -0,0 +1,116 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanMigrateEmbedfsWithoutChecksums(t *testing.T) {
+	container := mockfile.New("lala54")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	// embedfs written by early versions has no checksums
+	writer := tar.NewWriter(container)
+
+	err = writer.WriteHeader(&tar.Header{
+		Name:     "/a/1",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     2,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = writer.Write([]byte("1\n"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = writer.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{paxAttrPrefix + "channel": "beta"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	err = binary.Write(container, binary.BigEndian, embedFsFootprint{
+		signature, int64(len("binary")),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if !errors.Is(fs.Verify("/a/1"), ErrNoChecksum) {
+		t.Fatal("embedfs without checksums is expected")
+	}
+
+	err = Migrate(container, LatestFormatVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.Attr("channel") != "beta" {
+		t.Fatalf("attribute is not preserved: %q", fs.Attr("channel"))
+	}
+
+	err = fs.Verify("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := fs.ReadFile("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(contents) != "1\n" {
+		t.Fatalf("unexpected contents: %q", contents)
+	}
+
+	err = Truncate(container)
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	binary, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(binary) != "binary" {
+		t.Fatalf("unexpected origin contents: %q", binary)
+	}
+
+	err = Migrate(container, LatestFormatVersion+1)
+	if !errors.Is(err, ErrFormatTooNew) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// TarGzOptions holds settings which are used by OpenTarGzWithOptions.
+type TarGzOptions struct {
+	// Cache keeps decompressed archive in memory, so subsequent opens of the
+	// same archive will not decompress it again.
+	Cache bool
+}
+
+// memoryFile is in-memory origin for embedfs, which is built out of nested
+// archives.
+type memoryFile struct {
+	*bytes.Reader
+	name string
+}
+
+type memoryFileInfo struct {
+	name string
+	size int64
+}
+
+// OpenTarGz returns contents of tar.gz archive embedded as specified file as
+// fs.FS, so vendored archives can be used without extracting them first.
+//
+// Archive is decompressed into memory on open, and Limits of embedfs are
+// checked while it's decompressed, so they also bound memory taken by
+// archive. Only regular files are available, directories are derived from
+// paths of files.
+func (fs *EmbedFs) OpenTarGz(path string) (iofs.FS, error) {
+	return fs.OpenTarGzWithOptions(path, TarGzOptions{})
+}
+
+// OpenTarGzWithOptions works like OpenTarGz, but allows to tune opening
+// process by specified options.
+func (fs *EmbedFs) OpenTarGzWithOptions(
+	path string, options TarGzOptions,
+) (iofs.FS, error) {
+	if !options.Cache {
+		return fs.openTarGz(path)
+	}
+
+	name, err := cleanPath(path)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	fs.nestedMutex.Lock()
+	defer fs.nestedMutex.Unlock()
+
+	nested, ok := fs.nested[name]
+	if ok {
+		return nested, nil
+	}
+
+	nested, err = fs.openTarGz(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.nested == nil {
+		fs.nested = map[string]iofs.FS{}
+	}
+
+	fs.nested[name] = nested
+
+	return nested, nil
+}
+
+func (fs *EmbedFs) openTarGz(name string) (iofs.FS, error) {
+	section, err := fs.SectionReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressor, err := gzip.NewReader(section)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	defer decompressor.Close()
+
+	buffer := &bytes.Buffer{}
+
+	err = repackTar(buffer, tar.NewReader(decompressor), fs.options.Limits)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	err = binary.Write(buffer, binary.BigEndian, embedFsFootprint{
+		signature,
+		0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nested, err := OpenWithOptions(
+		&memoryFile{Reader: bytes.NewReader(buffer.Bytes()), name: name},
+		OpenOptions{
+			Mode:          fs.options.Mode,
+			Limits:        fs.options.Limits,
+			Metrics:       fs.options.Metrics,
+			Logger:        fs.options.Logger,
+			SortedListing: fs.options.SortedListing,
+		},
+	)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return nested.FS(), nil
+}
+
+// repackTar copies regular files from specified tar archive, converting
+// their names to the absolute ones as they are stored in embedfs. Limits
+// are checked before contents of every file are copied.
+func repackTar(writer io.Writer, reader *tar.Reader, limits Limits) error {
+	tarWriter := tar.NewWriter(writer)
+
+	var (
+		entries   int
+		totalSize int64
+	)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		header.Name = path.Join("/", header.Name)
+
+		stripInternalRecords(header)
+
+		entries++
+		totalSize += header.Size
+
+		err = limits.check(entries, totalSize, header)
+		if err != nil {
+			return err
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = copyBuffered(tarWriter, reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// Close is no-op for in-memory file.
+func (file *memoryFile) Close() error {
+	return nil
+}
+
+// Write operation is not supported. For interface compatibility only.
+func (file *memoryFile) Write([]byte) (int, error) {
+	return 0, ErrNotAvail
+}
+
+// Truncate operation is not supported. For interface compatibility only.
+func (file *memoryFile) Truncate(int64) error {
+	return ErrNotAvail
+}
+
+// Stat returns file info with size of in-memory data.
+func (file *memoryFile) Stat() (os.FileInfo, error) {
+	return memoryFileInfo{name: path.Base(file.name), size: file.Size()}, nil
+}
+
+func (info memoryFileInfo) Name() string {
+	return info.name
+}
+
+func (info memoryFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info memoryFileInfo) Mode() iofs.FileMode {
+	return 0444
+}
+
+func (info memoryFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (info memoryFileInfo) IsDir() bool {
+	return false
+}
+
+func (info memoryFileInfo) Sys() interface{} {
+	return nil
+}
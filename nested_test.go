@@ -0,0 +1,191 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	iofs "io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanOpenEmbeddedTarGz(t *testing.T) {
+	archive, err := ioutil.TempFile("", "embedfs-tar-gz")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(archive.Name())
+
+	compressor := gzip.NewWriter(archive)
+	writer := tar.NewWriter(compressor)
+
+	err = writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "vendor/",
+		Mode:     0755,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "vendor/lib.txt",
+		Mode:     0644,
+		Size:     7,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = writer.Write([]byte("vendor\n"))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, closer := range []interface{ Close() error }{
+		writer, compressor, archive,
+	} {
+		err = closer.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	container := mockfile.New("lala30")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(archive.Name(), "/vendor.tar.gz")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	nested, err := fs.OpenTarGzWithOptions(
+		"/vendor.tar.gz", TarGzOptions{Cache: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := iofs.ReadFile(nested, "vendor/lib.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "vendor\n" {
+		t.Fatalf("unexpected contents of nested file: %q", data)
+	}
+
+	cached, err := fs.OpenTarGzWithOptions(
+		"/vendor.tar.gz", TarGzOptions{Cache: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached != nested {
+		t.Fatal("cached archive should not be decompressed again")
+	}
+
+	cached, err = fs.OpenTarGzWithOptions(
+		"vendor.tar.gz", TarGzOptions{Cache: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached != nested {
+		t.Fatal("archive should be cached by clean path")
+	}
+
+	_, err = fs.OpenTarGz("/missing.tar.gz")
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestCanLimitSizeOfEmbeddedTarGz(t *testing.T) {
+	archive, err := ioutil.TempFile("", "embedfs-tar-gz")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(archive.Name())
+
+	compressor := gzip.NewWriter(archive)
+	writer := tar.NewWriter(compressor)
+
+	bomb := bytes.Repeat([]byte{0}, 1024*1024)
+
+	err = writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "bomb",
+		Mode:     0644,
+		Size:     int64(len(bomb)),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = writer.Write(bomb)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, closer := range []interface{ Close() error }{
+		writer, compressor, archive,
+	} {
+		err = closer.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	container := mockfile.New("lala92")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(archive.Name(), "/bomb.tar.gz")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxTotalSize: 64 * 1024},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.OpenTarGz("/bomb.tar.gz")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
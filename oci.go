@@ -0,0 +1,165 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// OCILayerMediaType is the media type of layers written by WriteOCILayer.
+const OCILayerMediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+// OCIDescriptor describes content written by WriteOCILayer as OCI content
+// descriptor, which can be put into image manifest as is.
+type OCIDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// WriteTar writes all files from embedded fs as tar archive with relative
+// names, adding entries for parent directories. Internal files of embedfs
+// are not written, as well as files shadowed by files with the same name.
+func (fs *EmbedFs) WriteTar(w io.Writer) error {
+	names, err := fs.listAll("/")
+	if err != nil {
+		return err
+	}
+
+	names = uniqueNames(names)
+	sort.Strings(names)
+
+	tarWriter := tar.NewWriter(w)
+	directories := map[string]bool{}
+
+	for _, name := range names {
+		if isInternal(name) {
+			continue
+		}
+
+		entry, err := fs.lookup(name)
+		if err != nil {
+			return err
+		}
+
+		header, err := fs.header(entry)
+		if err != nil {
+			return err
+		}
+
+		err = writeParents(tarWriter, name, header, directories)
+		if err != nil {
+			return err
+		}
+
+		exported := *header
+		exported.Name = strings.TrimPrefix(name, "/")
+		exported.Format = tar.FormatUnknown
+		exported.PAXRecords = map[string]string{}
+
+		for key, value := range header.PAXRecords {
+			if !strings.HasPrefix(key, paxPrefix) {
+				exported.PAXRecords[key] = value
+			}
+		}
+
+		err = tarWriter.WriteHeader(&exported)
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			directories[exported.Name] = true
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			_, err = copyBuffered(tarWriter, fs.newReader(entry, name))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// writeParents writes entries for parent directories of the file, which are
+// not written yet.
+func writeParents(
+	tarWriter *tar.Writer, name string, header *tar.Header,
+	directories map[string]bool,
+) error {
+	parts := strings.Split(strings.Trim(path.Dir(name), "/"), "/")
+
+	for i := range parts {
+		directory := strings.Join(parts[:i+1], "/")
+		if directory == "" || directories[directory] {
+			continue
+		}
+
+		directories[directory] = true
+
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     directory + "/",
+			Mode:     0755,
+			ModTime:  header.ModTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteOCILayer writes all files from embedded fs as gzipped tar archive,
+// which can be published as OCI image layer, and returns its descriptor
+// and diff ID, which is the digest of uncompressed archive required by
+// image configuration.
+func (fs *EmbedFs) WriteOCILayer(
+	w io.Writer,
+) (descriptor OCIDescriptor, diffID string, err error) {
+	compressed := sha256.New()
+	counter := &countingWriter{writer: io.MultiWriter(w, compressed)}
+
+	compressor := gzip.NewWriter(counter)
+	uncompressed := sha256.New()
+
+	err = fs.WriteTar(io.MultiWriter(compressor, uncompressed))
+	if err != nil {
+		return descriptor, "", err
+	}
+
+	err = compressor.Close()
+	if err != nil {
+		return descriptor, "", err
+	}
+
+	descriptor = OCIDescriptor{
+		MediaType: OCILayerMediaType,
+		Digest:    "sha256:" + hex.EncodeToString(compressed.Sum(nil)),
+		Size:      counter.written,
+	}
+
+	diffID = "sha256:" + hex.EncodeToString(uncompressed.Sum(nil))
+
+	return descriptor, diffID, nil
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (counter *countingWriter) Write(p []byte) (int, error) {
+	n, err := counter.writer.Write(p)
+	counter.written += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,91 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanWriteOCILayer(t *testing.T) {
+	container := mockfile.New("lala73")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	layer := &bytes.Buffer{}
+
+	descriptor, diffID, err := fs.WriteOCILayer(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(layer.Bytes())
+
+	if descriptor.Digest != "sha256:"+hex.EncodeToString(digest[:]) ||
+		descriptor.Size != int64(layer.Len()) ||
+		descriptor.MediaType != OCILayerMediaType {
+		t.Fatalf("unexpected descriptor: %+v", descriptor)
+	}
+
+	decompressor, err := gzip.NewReader(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := ioutil.ReadAll(decompressor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest = sha256.Sum256(archive)
+
+	if diffID != "sha256:"+hex.EncodeToString(digest[:]) {
+		t.Fatalf("unexpected diff ID: %s", diffID)
+	}
+
+	names := []string{}
+	reader := tar.NewReader(bytes.NewReader(archive))
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, header.Name)
+	}
+
+	expected := []string{"a/", "a/1", "b/", "b/2"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected layer contents: %v", names)
+	}
+}
@@ -0,0 +1,70 @@
+package embedfs
+
+import (
+	"sync"
+)
+
+// forEach calls fn for every specified name using specified number of
+// concurrent workers. It stops on first error and returns it.
+func forEach(workers int, names []string, fn func(name string) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		queue    = make(chan string)
+		failOnce sync.Once
+		failure  error
+		failed   = make(chan struct{})
+		group    sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+
+			for name := range queue {
+				err := fn(name)
+				if err != nil {
+					failOnce.Do(func() {
+						failure = err
+						close(failed)
+					})
+				}
+			}
+		}()
+	}
+
+enqueue:
+	for _, name := range names {
+		select {
+		case queue <- name:
+		case <-failed:
+			break enqueue
+		}
+	}
+
+	close(queue)
+	group.Wait()
+
+	return failure
+}
+
+// uniqueNames returns specified names without duplicates, preserving order.
+// Several entries can share the same name, but only the last one is visible.
+func uniqueNames(names []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		unique = append(unique, name)
+	}
+
+	return unique
+}
@@ -0,0 +1,38 @@
+package embedfs
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+// Pin reads files matching specified patterns into memory, so subsequent
+// opens of them are served from memory without accessing origin. Patterns
+// have the same syntax as in Glob. Already pinned files are not read again.
+func (fs *EmbedFs) Pin(patterns ...string) error {
+	for _, pattern := range patterns {
+		names, err := fs.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			entry, err := fs.lookup(name)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := fs.pinned.Load(entry); ok {
+				continue
+			}
+
+			data, err := ioutil.ReadAll(fs.newReader(entry, name))
+			if err != nil {
+				return err
+			}
+
+			fs.pinned.Store(entry, bytes.NewReader(data))
+		}
+	}
+
+	return nil
+}
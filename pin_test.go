@@ -0,0 +1,60 @@
+package embedfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanPinFilesInMemory(t *testing.T) {
+	container := mockfile.New("lala77")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	data, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	source := &countingSource{Reader: bytes.NewReader(data)}
+
+	fs, err := OpenReaderAt(source, int64(len(data)), OpenOptions{})
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.Pin("/a/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.offline = true
+
+	for i := 0; i < 2; i++ {
+		if string(fs.MustReadFile("/a/1")) != "1\n" {
+			t.Fatal("pinned file </a/1> is not read")
+		}
+	}
+
+	_, err = fs.ReadFile("/b/2")
+	if err == nil {
+		t.Fatal("file </b/2> is not expected to be pinned")
+	}
+}
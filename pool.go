@@ -0,0 +1,24 @@
+package embedfs
+
+import (
+	"io"
+	"sync"
+)
+
+const bufferSize = 32 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, bufferSize)
+		return &buffer
+	},
+}
+
+// copyBuffered works like io.Copy, but uses buffer from the pool instead of
+// allocating new one on every call.
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buffer)
+
+	return io.CopyBuffer(dst, src, *buffer)
+}
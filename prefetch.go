@@ -0,0 +1,82 @@
+package embedfs
+
+import (
+	"io"
+	iofs "io/fs"
+	"io/ioutil"
+)
+
+// Prefetch reads data of specified files, or of all files located under
+// specified directories, so they are loaded into page cache or block cache
+// before they are actually needed. It can be called in background.
+func (fs *EmbedFs) Prefetch(paths ...string) error {
+	for _, path := range paths {
+		name, err := cleanPath(path)
+		if err != nil {
+			return &iofs.PathError{Op: "prefetch", Path: path, Err: err}
+		}
+
+		found := false
+
+		err = fs.List(name, func(entry Entry) error {
+			found = true
+
+			_, err := copyBuffered(ioutil.Discard, io.NewSectionReader(
+				fs.originOf(entry.entry), entry.entry.offset, entry.entry.size,
+			))
+
+			return err
+		})
+		if err != nil {
+			return &iofs.PathError{Op: "prefetch", Path: path, Err: err}
+		}
+
+		if !found {
+			return &iofs.PathError{Op: "prefetch", Path: path, Err: ErrNoExist}
+		}
+	}
+
+	return nil
+}
+
+// readAhead reads data of the file from current offset into b. If Readahead
+// option is set, data is read from origin by chunks of specified size and
+// kept for subsequent reads.
+func (reader *embedFileReader) readAhead(b []byte) (int, error) {
+	size := reader.fs.options.Readahead
+	if size <= int64(len(b)) {
+		return reader.source.ReadAt(b, reader.start+reader.offset)
+	}
+
+	position := reader.offset - reader.aheadOffset
+	if position < 0 || position >= int64(len(reader.ahead)) {
+		if rest := reader.length - reader.offset; size > rest {
+			size = rest
+		}
+
+		if int64(cap(reader.ahead)) < size {
+			reader.ahead = make([]byte, size)
+		}
+
+		n, err := reader.source.ReadAt(
+			reader.ahead[:size], reader.start+reader.offset,
+		)
+
+		reader.ahead = reader.ahead[:n]
+		reader.aheadOffset = reader.offset
+		position = 0
+
+		if int64(n) < size {
+			n = copy(b, reader.ahead)
+			reader.ahead = reader.ahead[:0]
+
+			if n < len(b) {
+				return n, err
+			}
+
+			return n, nil
+		}
+	}
+
+	return copy(b, reader.ahead[position:]), nil
+}
@@ -0,0 +1,100 @@
+package embedfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanPrefetchAndReadAhead(t *testing.T) {
+	container := mockfile.New("lala76")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("embedfs.go", "/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	data, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	expected, err := ioutil.ReadFile("embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	source := &countingSource{Reader: bytes.NewReader(data)}
+
+	fs, err := OpenReaderAt(source, int64(len(data)), OpenOptions{
+		Readahead: 1024 * 1024,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	file, err := fs.Open("/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	reads := source.reads
+
+	actual, err := ioutil.ReadAll(iotest.OneByteReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Fatal("file </embedfs.go> is read incorrectly")
+	}
+
+	if source.reads-reads != 1 {
+		t.Fatalf("unexpected number of reads: %d", source.reads-reads)
+	}
+
+	cached, err := OpenReaderAt(
+		NewBlockCache(source, int64(len(data)), BlockCacheOptions{}),
+		int64(len(data)),
+		OpenOptions{},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cached.Prefetch("/embedfs.go", "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.offline = true
+
+	if string(cached.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not prefetched")
+	}
+
+	err = cached.Prefetch("/missing")
+	if err == nil {
+		t.Fatal("error is expected for missing file")
+	}
+}
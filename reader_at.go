@@ -0,0 +1,53 @@
+package embedfs
+
+import (
+	"io"
+	"os"
+)
+
+// readerAtFile is read-only origin for embedfs, which is backed by
+// io.ReaderAt, like remote object or block cache.
+type readerAtFile struct {
+	*io.SectionReader
+	source io.ReaderAt
+}
+
+// OpenReaderAt opens embedfs stored in the end of data of specified size,
+// which is read through io.ReaderAt, so containers located in object
+// storages or served over HTTP ranges can be opened without downloading
+// them. Source is closed on Close of embedfs, if it implements io.Closer.
+//
+// Source can be wrapped into NewBlockCache to avoid fetching the same
+// ranges repeatedly.
+func OpenReaderAt(
+	source io.ReaderAt, size int64, options OpenOptions,
+) (*EmbedFs, error) {
+	return OpenWithOptions(&readerAtFile{
+		SectionReader: io.NewSectionReader(source, 0, size),
+		source:        source,
+	}, options)
+}
+
+// Close closes source, if it's closable.
+func (file *readerAtFile) Close() error {
+	if closer, ok := file.source.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Write operation is not supported. For interface compatibility only.
+func (file *readerAtFile) Write([]byte) (int, error) {
+	return 0, ErrNotAvail
+}
+
+// Truncate operation is not supported. For interface compatibility only.
+func (file *readerAtFile) Truncate(int64) error {
+	return ErrNotAvail
+}
+
+// Stat returns file info with size of data.
+func (file *readerAtFile) Stat() (os.FileInfo, error) {
+	return memoryFileInfo{size: file.Size()}, nil
+}
@@ -0,0 +1,113 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Reembed replaces embedfs of file specified by path with the new one, which
+// is filled by build function. All existing embedfs sections are stripped
+// first, so file never contains stacked payloads.
+//
+// New file is written next to the original one and renamed over it only
+// when build succeeds, so file is either old or new one on disk.
+func Reembed(path string, build func(*Embedder) error) error {
+	return ReembedWithOptions(path, EmbedOptions{}, build)
+}
+
+// ReembedWithOptions works like Reembed, but allows to tune embedding
+// process by specified options. Sidecar volumes are not supported.
+func ReembedWithOptions(
+	path string, options EmbedOptions, build func(*Embedder) error,
+) error {
+	if options.VolumeSize != 0 {
+		return ErrNotImplemented
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	target, err := ioutil.TempFile(
+		filepath.Dir(path), "."+filepath.Base(path)+".embedfs",
+	)
+	if err != nil {
+		return err
+	}
+
+	err = reembed(source, target, options, build)
+	if err == nil {
+		err = target.Chmod(stat.Mode().Perm())
+	}
+
+	if err == nil {
+		err = target.Sync()
+	}
+
+	if err != nil {
+		target.Close()
+		os.Remove(target.Name())
+
+		return err
+	}
+
+	err = target.Close()
+	if err != nil {
+		os.Remove(target.Name())
+
+		return err
+	}
+
+	err = os.Rename(target.Name(), path)
+	if err != nil {
+		os.Remove(target.Name())
+	}
+
+	return err
+}
+
+func reembed(
+	source *os.File, target *os.File,
+	options EmbedOptions, build func(*Embedder) error,
+) error {
+	offset, err := copyBuffered(target, source)
+	if err != nil {
+		return err
+	}
+
+	options.Existing = ExistingReplace
+
+	for {
+		stripped, err := probeExisting(target, offset, options)
+		if err != nil {
+			return err
+		}
+
+		if stripped == offset {
+			break
+		}
+
+		offset = stripped
+	}
+
+	embedder, err := NewWriterWithOptions(target, offset, options)
+	if err != nil {
+		return err
+	}
+
+	err = build(embedder)
+	if err != nil {
+		return err
+	}
+
+	return embedder.Close()
+}
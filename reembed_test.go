@@ -0,0 +1,79 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCanReembedFile(t *testing.T) {
+	container, err := ioutil.TempFile("", "embedfs-reembed")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(container.Name())
+
+	_, err = container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = container.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	err = os.Chmod(container.Name(), 0751)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, source := range []string{"_test/a/1", "_test/b/2"} {
+		err = Reembed(container.Name(), func(embedder *Embedder) error {
+			return embedder.EmbedFile(source, "/file")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failure := errors.New("build failed")
+
+	err = Reembed(container.Name(), func(embedder *Embedder) error {
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	origin, err := os.Open(container.Name())
+	if err != nil {
+		panic(err)
+	}
+
+	defer origin.Close()
+
+	fs, err := Open(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "2\n" {
+		t.Fatal("embedfs is not replaced")
+	}
+
+	if fs.offset != int64(len("binary")) {
+		t.Fatalf("embedfs is stacked at offset %d", fs.offset)
+	}
+
+	stat, err := origin.Stat()
+	if err != nil {
+		panic(err)
+	}
+
+	if stat.Mode().Perm() != 0751 {
+		t.Fatalf("unexpected mode: %s", stat.Mode())
+	}
+}
@@ -0,0 +1,197 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var ErrUnsafeDir = errors.New("cache directory is not private")
+
+// EmbedURL embeds reference to the file located at specified URL instead of
+// its contents. Contents are downloaded on first read, verified by
+// specified SHA-256 checksum and size, and cached in RemoteCacheDir, so
+// heavy files can be pulled on demand.
+func (e *Embedder) EmbedURL(
+	url string, target string, hash string, size int64,
+) error {
+	e.options.emit(Event{Kind: EventStarted, Source: url, Target: target})
+
+	tarHeader := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		ModTime:  time.Now(),
+		PAXRecords: map[string]string{
+			paxChecksum:     hash,
+			paxContentType:  detectContentType(target, nil),
+			paxURL:          url,
+			paxExternalSize: strconv.FormatInt(size, 10),
+		},
+	}
+
+	return e.embedContent(tarHeader, url, target, nil)
+}
+
+func (fs *EmbedFs) newRemoteData(
+	url, hash string, size int64,
+) (*externalData, error) {
+	if !isChecksum(hash) {
+		return nil, fmt.Errorf(
+			`%w: invalid checksum of remote file <%s>`, ErrCorrupted, url,
+		)
+	}
+
+	dir := cacheDir(fs.options.RemoteCacheDir)
+
+	client := fs.options.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &externalData{
+		reference: url,
+		path:      filepath.Join(dir, hash),
+		size:      size,
+		url:       url,
+		hash:      hash,
+		client:    client,
+	}, nil
+}
+
+// download returns file with contents of remote file, fetching it into
+// cache, unless it's already cached. Contents are verified before they are
+// stored in cache, and cached contents are verified again, because cache
+// directory may be damaged. Returned file is the one which is verified, so
+// it can't be replaced after verification.
+func (data *externalData) download() (*os.File, error) {
+	err := privateDir(filepath.Dir(data.path))
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := os.Open(data.path)
+	if err == nil {
+		hash := sha256.New()
+
+		_, err = copyBuffered(hash, cached)
+		if err == nil && hex.EncodeToString(hash.Sum(nil)) == data.hash {
+			return cached, nil
+		}
+
+		cached.Close()
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	response, err := data.client.Get(data.url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			`can't fetch <%s>: %s`, data.url, response.Status,
+		)
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(data.path), ".tmp-remote-")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+
+	_, err = copyBuffered(
+		io.MultiWriter(temp, hash),
+		io.LimitReader(response.Body, data.size+1),
+	)
+
+	if err == nil && hex.EncodeToString(hash.Sum(nil)) != data.hash {
+		err = fmt.Errorf(
+			`%w: remote file <%s>`, ErrChecksumMismatch, data.url,
+		)
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), data.path)
+	}
+
+	if err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+
+		return nil, err
+	}
+
+	return temp, nil
+}
+
+// cacheDir returns specified directory or, if it's empty, embedfs directory
+// in user cache directory, or directory of the user in temporary directory
+// if there is no user cache directory.
+func cacheDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(
+			os.TempDir(), "embedfs-"+strconv.Itoa(os.Getuid()),
+		)
+	}
+
+	return filepath.Join(cache, "embedfs")
+}
+
+// privateDir creates directory, if it doesn't exist, and checks that it's
+// owned by current user and can't be written by others, so files can't be
+// replaced in it.
+func privateDir(dir string) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !stat.IsDir() {
+		return fmt.Errorf(`%w: <%s> is not a directory`, ErrUnsafeDir, dir)
+	}
+
+	// permissions are not checked on systems without owners of files
+	owner, ok := fileOwner(stat)
+	if !ok {
+		return nil
+	}
+
+	if owner != os.Getuid() {
+		return fmt.Errorf(
+			`%w: <%s> is owned by user %d`, ErrUnsafeDir, dir, owner,
+		)
+	}
+
+	if stat.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf(
+			`%w: <%s> is writable by others`, ErrUnsafeDir, dir,
+		)
+	}
+
+	return nil
+}
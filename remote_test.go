@@ -0,0 +1,119 @@
+package embedfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanEmbedURL(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			requests++
+			writer.Write([]byte("remote\n"))
+		},
+	))
+
+	defer server.Close()
+
+	cache, err := ioutil.TempDir("", "embedfs-remote")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(cache)
+
+	hash := sha256.Sum256([]byte("remote\n"))
+
+	container := mockfile.New("lala46")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedURL(
+		server.URL+"/remote", "/remote", hex.EncodeToString(hash[:]), 7,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedURL(
+		server.URL+"/broken", "/broken", hex.EncodeToString(make([]byte, 32)), 7,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		fs, err := OpenWithOptions(container, OpenOptions{
+			RemoteCacheDir: cache,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		if string(fs.MustReadFile("/remote")) != "remote\n" {
+			t.Fatal("remote file is not downloaded")
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("remote file should be downloaded once, got %d", requests)
+	}
+
+	// file of the same size planted in cache should not be trusted
+	err = ioutil.WriteFile(
+		filepath.Join(cache, hex.EncodeToString(hash[:])), []byte("pwned\n\n"),
+		0644,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{RemoteCacheDir: cache})
+	if err != nil {
+		panic(err)
+	}
+
+	if string(fs.MustReadFile("/remote")) != "remote\n" {
+		t.Fatal("planted file is read instead of remote one")
+	}
+
+	_, err = fs.ReadFile("/broken")
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	// files in cache writable by others can be replaced after verification
+	err = os.Chmod(cache, 0777)
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err = OpenWithOptions(container, OpenOptions{RemoteCacheDir: cache})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.ReadFile("/remote")
+	if !errors.Is(err, ErrUnsafeDir) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package embedfs
+
+import (
+	"fmt"
+	"os"
+)
+
+// reopenPath returns path which opens the same file as origin, even if
+// origin has been renamed or replaced since it was opened.
+func reopenPath(origin *os.File) string {
+	return fmt.Sprintf("/proc/self/fd/%d", origin.Fd())
+}
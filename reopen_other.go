@@ -0,0 +1,13 @@
+//go:build !linux
+
+package embedfs
+
+import (
+	"os"
+)
+
+// reopenPath returns name of origin on systems without /proc, so reopened
+// file should be compared with origin before use.
+func reopenPath(origin *os.File) string {
+	return origin.Name()
+}
@@ -0,0 +1,40 @@
+package embedfs
+
+// EmbedReport describes files embedded by Embedder, or files which would be
+// embedded in dry-run mode.
+type EmbedReport struct {
+	Files     []ReportedFile
+	TotalSize int64
+}
+
+// ReportedFile describes single embedded file.
+type ReportedFile struct {
+	// Source is the path to the source file.
+	Source string
+
+	// Target is the name of the file in embedded fs.
+	Target string
+
+	Size int64
+}
+
+// Report returns list of files embedded so far along with their total size.
+func (e *Embedder) Report() EmbedReport {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	report := e.report
+	report.Files = append([]ReportedFile{}, e.report.Files...)
+
+	return report
+}
+
+func (report *EmbedReport) add(source, target string, size int64) {
+	report.Files = append(report.Files, ReportedFile{
+		Source: source,
+		Target: target,
+		Size:   size,
+	})
+
+	report.TotalSize += size
+}
@@ -0,0 +1,111 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const paxLabel = paxPrefix + "label"
+
+var ErrNoSection = errors.New("no embedfs section with specified label")
+
+// OpenNamed opens section of origin with specified label, which was set by
+// Label option of CreateWithOptions.
+//
+// Sections are embedded one after another, so they are looked up from the
+// end of origin following their footprints.
+func OpenNamed(origin file, label string) (*EmbedFs, error) {
+	return OpenWithOptions(origin, OpenOptions{Label: label})
+}
+
+// Label returns label of embedfs or empty string if it has no label.
+func (fs *EmbedFs) Label() string {
+	records, err := readGlobalHeader(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return ""
+	}
+
+	return records[paxLabel]
+}
+
+// writeGlobalHeader writes attributes of the whole embedfs, if there are
+// any, as the first header of embedfs.
+func (e *Embedder) writeGlobalHeader() error {
+	records := map[string]string{}
+
+	if e.options.Label != "" {
+		records[paxLabel] = e.options.Label
+	}
+
+	if e.options.Version != "" {
+		records[paxVersion] = e.options.Version
+	}
+
+	for key, value := range e.options.BuildMetadata {
+		records[paxMetadataPrefix+key] = value
+	}
+
+	if len(records) == 0 || e.options.DryRun {
+		return nil
+	}
+
+	return e.writeHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: records,
+	})
+}
+
+// findSection walks footprints of sections from the end of origin until
+// section with specified label is found.
+func findSection(
+	origin file, footprint embedFsFootprint, end int64,
+	magic [signatureLen]byte, label string,
+) (embedFsFootprint, int64, error) {
+	for {
+		records, err := readGlobalHeader(origin, footprint.Offset, end)
+		if err != nil {
+			return footprint, 0, err
+		}
+
+		if records[paxLabel] == label {
+			return footprint, end, nil
+		}
+
+		footprint, end, err = findFootprint(origin, footprint.Offset, 0, magic)
+		if errors.Is(err, ErrNoFootprint) {
+			return footprint, 0, fmt.Errorf(`%w: <%s>`, ErrNoSection, label)
+		}
+
+		if err != nil {
+			return footprint, 0, err
+		}
+	}
+}
+
+// readGlobalHeader returns PAX records of global header of embedfs located
+// in the specified range of origin.
+func readGlobalHeader(
+	origin io.ReaderAt, offset, end int64,
+) (map[string]string, error) {
+	stream, err := tarStream(origin, offset, end)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := tar.NewReader(stream).Next()
+	if err == io.EOF {
+		return map[string]string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Typeflag != tar.TypeXGlobalHeader {
+		return map[string]string{}, nil
+	}
+
+	return header.PAXRecords, nil
+}
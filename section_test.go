@@ -0,0 +1,68 @@
+package embedfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanOpenNamedSections(t *testing.T) {
+	container := mockfile.New("lala52")
+
+	sections := map[string]string{
+		"assets":   "_test/a/1",
+		"licenses": "_test/b/2",
+	}
+
+	for _, label := range []string{"assets", "licenses"} {
+		embedder, err := CreateWithOptions(container, EmbedOptions{
+			Label: label,
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.EmbedFile(sections[label], "/file")
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	fs, err := OpenNamed(container, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "1\n" || fs.Label() != "assets" {
+		t.Fatal("section <assets> is not opened")
+	}
+
+	fs, err = Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/file")) != "2\n" || fs.Label() != "licenses" {
+		t.Fatal("last section is not opened by default")
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		panic(err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("global header should not be listed: %v", files)
+	}
+
+	_, err = OpenNamed(container, "models")
+	if !errors.Is(err, ErrNoSection) {
+		t.Fatalf("expected no section error, got %v", err)
+	}
+}
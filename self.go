@@ -0,0 +1,82 @@
+package embedfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+var (
+	defaultFs     *EmbedFs
+	defaultFsErr  error
+	defaultFsOnce sync.Once
+)
+
+// Default returns embedfs of the current executable. It's opened on the
+// first call only, all subsequent calls return the same embedfs, so it
+// can be used from any place of program without passing it around.
+func Default() (*EmbedFs, error) {
+	defaultFsOnce.Do(func() {
+		defaultFs, defaultFsErr = OpenSelf()
+	})
+
+	return defaultFs, defaultFsErr
+}
+
+// OpenSelf opens embedfs from the executable file of the current process.
+func OpenSelf() (*EmbedFs, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	origin, err := os.Open(executable)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := Open(origin)
+	if err != nil {
+		origin.Close()
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// MustOpenSelf works like OpenSelf, but panics on error. It's intended for
+// programs, which can't work without embedded data.
+func MustOpenSelf() *EmbedFs {
+	fs, err := OpenSelf()
+	if err != nil {
+		panic(fmt.Sprintf(
+			"embedfs: can't open embedded fs of current executable: %s", err,
+		))
+	}
+
+	return fs
+}
+
+// ReadFile returns whole contents of the specified file from embedded fs.
+func (fs *EmbedFs) ReadFile(path string) ([]byte, error) {
+	embeddedFile, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer embeddedFile.Close()
+
+	return ioutil.ReadAll(embeddedFile)
+}
+
+// MustReadFile works like ReadFile, but panics on error. It's intended for
+// initialization of package-level variables with embedded data.
+func (fs *EmbedFs) MustReadFile(path string) []byte {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("embedfs: can't read <%s>: %s", path, err))
+	}
+
+	return data
+}
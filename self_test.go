@@ -0,0 +1,67 @@
+package embedfs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanOpenDefaultFsOnce(t *testing.T) {
+	// test binary has nothing embedded
+	fs, err := Default()
+	if !errors.Is(err, ErrNoFootprint) {
+		t.Fatalf("expected no footprint error, got %v", err)
+	}
+
+	if fs != nil {
+		t.Fatal("embedfs is returned along with error")
+	}
+
+	_, cachedErr := Default()
+	if cachedErr != err {
+		t.Fatalf("error is not cached: %v", cachedErr)
+	}
+
+	container := mockfile.New("lala100")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	opened, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// executable is not opened again, so cached embedfs is returned
+	// whatever it is
+	defaultFs, defaultFsErr = opened, nil
+	defer func() {
+		defaultFs, defaultFsErr = nil, cachedErr
+	}()
+
+	fs, err = Default()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fs != opened {
+		t.Fatal("embedfs is opened again")
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("unexpected contents of </a/1>")
+	}
+}
@@ -0,0 +1,115 @@
+package embedfs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+)
+
+var ErrNotInOrigin = errors.New("file data is not stored in origin")
+
+// SectionReader returns reader for the specified file from embedded fs,
+// which reads data directly from the origin.
+func (fs *EmbedFs) SectionReader(path string) (*io.SectionReader, error) {
+	name, err := cleanPath(path)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return nil, &iofs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	return io.NewSectionReader(
+		fs.originOf(entry), entry.offset, entry.size,
+	), nil
+}
+
+// Extent returns absolute offset and length of data of the specified file
+// inside origin, so origin can be mmaped or passed to sendfile directly.
+//
+// ErrNotInOrigin is returned for files which are stored in sidecar volumes
+// or external files.
+func (fs *EmbedFs) Extent(path string) (offset int64, length int64, err error) {
+	name, err := cleanPath(path)
+	if err != nil {
+		return 0, 0, &iofs.PathError{Op: "extent", Path: path, Err: err}
+	}
+
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return 0, 0, &iofs.PathError{Op: "extent", Path: path, Err: err}
+	}
+
+	if entry.volume != nil || !entry.storedAsIs() {
+		return 0, 0, &iofs.PathError{
+			Op: "extent", Path: path, Err: ErrNotInOrigin,
+		}
+	}
+
+	return entry.offset, entry.size, nil
+}
+
+// sendFile passes data of embedded file to the writer as limited reader over
+// os.File, so net.TCPConn and os.File can use sendfile or splice to copy
+// data without passing it through userspace.
+//
+// Origin file is reopened, because sendfile uses current file offset. If
+// reopened file is not the same as origin, e.g. binary has been replaced on
+// upgrade, buffered copy is used instead.
+func (reader *embedFileReader) sendFile(
+	writer io.ReaderFrom, origin *os.File,
+) (int64, bool, error) {
+	duplicate, err := os.Open(reopenPath(origin))
+	if err != nil {
+		reader.fs.options.Logger.Debug(
+			"can't reopen origin, falling back to buffered copy",
+			"path", origin.Name(), "error", err,
+		)
+
+		return 0, false, nil
+	}
+
+	defer duplicate.Close()
+
+	if !sameFile(origin, duplicate) {
+		reader.fs.options.Logger.Debug(
+			"reopened origin is different file, falling back to buffered copy",
+			"path", origin.Name(),
+		)
+
+		return 0, false, nil
+	}
+
+	_, err = duplicate.Seek(reader.start+reader.offset, os.SEEK_SET)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	written, err := writer.ReadFrom(&io.LimitedReader{
+		R: duplicate,
+		N: reader.length - reader.offset,
+	})
+
+	reader.offset += written
+	reader.fs.options.Metrics.BytesRead(reader.name, int(written))
+
+	return written, true, err
+}
+
+// sameFile returns true if both files are known to be the same file.
+func sameFile(a, b *os.File) bool {
+	aInfo, err := a.Stat()
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := b.Stat()
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(aInfo, bInfo)
+}
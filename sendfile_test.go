@@ -0,0 +1,180 @@
+package embedfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCanCopyFileFromOsFile(t *testing.T) {
+	container, err := ioutil.TempFile("", "embedfs-container")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(container.Name())
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("embedfs.go", "embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	expected, err := ioutil.ReadFile("embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := fs.Open("/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	// first bytes are read without sendfile to check that offset is honored
+	head := make([]byte, 10)
+
+	_, err = io.ReadFull(f, head)
+	if err != nil {
+		panic(err)
+	}
+
+	actual := bytes.NewBuffer(head)
+
+	_, err = io.Copy(actual, f)
+	if err != nil {
+		panic(err)
+	}
+
+	if !bytes.Equal(actual.Bytes(), expected) {
+		t.Fatal("file from embedfs is not equal to actual file")
+	}
+
+	section, err := fs.SectionReader("/embedfs.go")
+	if err != nil {
+		panic(err)
+	}
+
+	if section.Size() != int64(len(expected)) {
+		t.Fatalf("unexpected section size: %d", section.Size())
+	}
+}
+
+func TestCanGetExtentOfFile(t *testing.T) {
+	container, err := ioutil.TempFile("", "embedfs-container")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(container.Name())
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	offset, length, err := fs.Extent("/b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, length)
+
+	_, err = container.ReadAt(data, offset)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(data) != "2\n" {
+		t.Fatalf("extent points to unexpected data: %q", data)
+	}
+}
+
+func TestCanCopyFileFromReplacedOsFile(t *testing.T) {
+	container, err := ioutil.TempFile("", "embedfs-container")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(container.Name())
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// binary is replaced on upgrade while old one is still running
+	err = os.Remove(container.Name())
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(container.Name(), bytes.Repeat([]byte("x"), 4096), 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := fs.Open("/a/1")
+	if err != nil {
+		panic(err)
+	}
+
+	actual := &bytes.Buffer{}
+
+	_, err = io.Copy(actual, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.String() != "1\n" {
+		t.Fatalf("unexpected contents: %q", actual.String())
+	}
+}
@@ -0,0 +1,203 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+const paxSolid = paxPrefix + "solid"
+
+const (
+	// solidBlocksDir is the internal directory, where solid blocks are
+	// stored.
+	solidBlocksDir = internalDir + "/blocks"
+
+	defaultSolidFileSize = 4 * 1024
+)
+
+// solidBlock holds contents of files packed together.
+type solidBlock struct {
+	data    bytes.Buffer
+	members []solidMember
+	names   map[string]bool
+}
+
+// solidMember describes file packed into solid block. Index of block is
+// stored in PAX record of the block as JSON array of members.
+type solidMember struct {
+	Name    string            `json:"name"`
+	Offset  int64             `json:"offset"`
+	Size    int64             `json:"size"`
+	Mode    int64             `json:"mode"`
+	ModTime int64             `json:"modtime"`
+	Records map[string]string `json:"records,omitempty"`
+}
+
+// packs returns true if file should be packed into solid block.
+func (e *Embedder) packs(tarHeader *tar.Header, name string) bool {
+	if e.options.SolidBlockSize == 0 || tarHeader.Typeflag != tar.TypeReg {
+		return false
+	}
+
+	maxSize := e.options.SolidFileSize
+	if maxSize == 0 {
+		maxSize = defaultSolidFileSize
+	}
+
+	return tarHeader.Size <= maxSize && !e.encrypts(name)
+}
+
+// pack appends file to the current solid block, writing the block when it
+// becomes full.
+func (e *Embedder) pack(tarHeader *tar.Header, content io.Reader) error {
+	if e.block == nil {
+		e.block = &solidBlock{names: map[string]bool{}}
+	}
+
+	size, err := copyBuffered(&e.block.data, content)
+	if err != nil {
+		return err
+	}
+
+	e.block.members = append(e.block.members, solidMember{
+		Name:    tarHeader.Name,
+		Offset:  int64(e.block.data.Len()) - size,
+		Size:    size,
+		Mode:    tarHeader.Mode,
+		ModTime: tarHeader.ModTime.UnixNano(),
+		Records: tarHeader.PAXRecords,
+	})
+
+	e.block.names[tarHeader.Name] = true
+
+	if int64(e.block.data.Len()) >= e.options.SolidBlockSize {
+		return e.flushBlock()
+	}
+
+	return nil
+}
+
+// flushBlock writes current solid block, if there is one.
+func (e *Embedder) flushBlock() error {
+	if e.block == nil {
+		return nil
+	}
+
+	block := e.block
+	e.block = nil
+
+	index, err := json.Marshal(block.members)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(block.data.Bytes())
+
+	tarHeader := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     fmt.Sprintf("%s/%d", solidBlocksDir, e.blocks),
+		Mode:     0644,
+		Size:     int64(block.data.Len()),
+		ModTime:  time.Now(),
+		PAXRecords: map[string]string{
+			paxChecksum: hex.EncodeToString(hash[:]),
+			paxSolid:    string(index),
+		},
+	}
+
+	e.blocks++
+
+	content, err := e.compress(tarHeader, bytes.NewReader(block.data.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	err = e.reserveVolume(tarHeader.Size)
+	if err != nil {
+		return err
+	}
+
+	err = e.writeHeader(tarHeader)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(e.writer, content)
+
+	return err
+}
+
+// expandSolid returns entries of files packed into solid block, if entry
+// is solid block. Data of files is read from data of the block.
+//
+// Packed files are checked against Limits like other entries.
+func (fs *EmbedFs) expandSolid(
+	block *embedFsEntry, header *tar.Header,
+) ([]*embedFsEntry, error) {
+	index, ok := header.PAXRecords[paxSolid]
+	if !ok {
+		return nil, nil
+	}
+
+	members := []solidMember{}
+
+	err := json.Unmarshal([]byte(index), &members)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: invalid index of solid block: %s`,
+			ErrCorrupted, err)
+	}
+
+	block.solid = true
+
+	expanded := make([]*embedFsEntry, len(members))
+	for i, member := range members {
+		// sum of offset and size of crafted member can overflow
+		if member.Offset < 0 || member.Size < 0 ||
+			member.Offset > block.size-member.Size {
+			return nil, fmt.Errorf(
+				`%w: file <%s> is out of bounds of solid block`,
+				ErrCorrupted, member.Name,
+			)
+		}
+
+		memberHeader := &tar.Header{
+			Typeflag:   tar.TypeReg,
+			Name:       member.Name,
+			Mode:       member.Mode,
+			Size:       member.Size,
+			ModTime:    time.Unix(0, member.ModTime),
+			PAXRecords: member.Records,
+		}
+
+		fs.entries++
+		fs.totalSize += member.Size
+
+		err := fs.options.Limits.check(fs.entries, fs.totalSize, memberHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		expanded[i] = &embedFsEntry{
+			name:         path.Clean("/" + member.Name),
+			offset:       block.offset + member.Offset,
+			size:         member.Size,
+			headerOffset: block.headerOffset,
+			header:       memberHeader,
+			hidden:       isHidden(memberHeader),
+			encrypted:    block.encrypted,
+			compressed:   block.compressed,
+			gzipped:      block.gzipped,
+			squashed:     block.squashed,
+			member:       true,
+		}
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,175 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanPackTinyFilesIntoSolidBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-solid")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 100; i++ {
+		err = ioutil.WriteFile(
+			filepath.Join(dir, fmt.Sprintf("%02d.svg", i)),
+			[]byte(fmt.Sprintf(`<svg><circle r="%d"/></svg>`, i)),
+			0640,
+		)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	embed := func(options EmbedOptions) (*mockfile.MockFile, int64) {
+		container := mockfile.New("lala82")
+
+		embedder, err := CreateWithOptions(container, options)
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.EmbedDirectory(dir, "/icons")
+		if err != nil {
+			panic(err)
+		}
+
+		// regular file shadows packed one
+		err = embedder.EmbedFile("_test/a/1", "/icons/00.svg")
+		if err != nil {
+			panic(err)
+		}
+
+		err = embedder.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		size, err := container.Seek(0, os.SEEK_END)
+		if err != nil {
+			panic(err)
+		}
+
+		return container, size
+	}
+
+	_, plainSize := embed(EmbedOptions{})
+
+	container, size := embed(EmbedOptions{SolidBlockSize: 1024})
+
+	if size >= plainSize/4 {
+		t.Fatalf("solid blocks don't help: %d vs %d bytes", size, plainSize)
+	}
+
+	cacheDir, err := ioutil.TempDir("", "embedfs-solid-cache")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(cacheDir)
+
+	// second open reads index from cache
+	for i := 0; i < 2; i++ {
+		fs, err := OpenWithOptions(container, OpenOptions{
+			IndexCacheDir: cacheDir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := fs.ListDir("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(uniqueNames(names)) != 100 {
+			t.Fatalf("unexpected number of files: %d", len(uniqueNames(names)))
+		}
+
+		if string(fs.MustReadFile("/icons/00.svg")) != "1\n" {
+			t.Fatal("packed file shadows file embedded after it")
+		}
+
+		contents := fs.MustReadFile("/icons/42.svg")
+		if string(contents) != `<svg><circle r="42"/></svg>` {
+			t.Fatalf("unexpected contents of packed file: %q", contents)
+		}
+
+		stat, err := fs.Stat("/icons/42.svg")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stat.Mode().Perm() != 0640 || stat.Size() != 27 {
+			t.Fatalf("unexpected stat of packed file: %s %d",
+				stat.Mode(), stat.Size())
+		}
+
+		err = fs.VerifyAll(VerifyOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCanLimitFilesPackedIntoSolidBlocks(t *testing.T) {
+	container := mockfile.New("lala95")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		SolidBlockSize: 1024,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxEntries: 2},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxTotalSize: 4},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanNotExpandMemberOutOfSolidBlock(t *testing.T) {
+	fs := &EmbedFs{}
+
+	_, err := fs.expandSolid(&embedFsEntry{size: 10}, &tar.Header{
+		PAXRecords: map[string]string{
+			paxSolid: fmt.Sprintf(
+				`[{"name": "/a", "offset": %d, "size": 5}]`,
+				int64(math.MaxInt64-1),
+			),
+		},
+	})
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
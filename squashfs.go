@@ -0,0 +1,2074 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	squashfsMagic = 0x73717368
+
+	// squashfsBlockSize is the size of data blocks, which are compressed
+	// separately, so reading any byte of file decompresses at most that
+	// much data.
+	squashfsBlockSize = 128 * 1024
+	squashfsBlockLog  = 17
+
+	// squashfsMetadataSize is the size of blocks of inode, directory and
+	// other tables before compression.
+	squashfsMetadataSize = 8192
+
+	squashfsCompressionGzip = 1
+
+	squashfsNoFragments = 0x0010
+	squashfsNoXattrs    = 0x0200
+
+	// squashfsUncompressed marks data blocks and metadata blocks, which are
+	// stored as is.
+	squashfsUncompressedBlock    = 1 << 24
+	squashfsUncompressedMetadata = 0x8000
+
+	squashfsNoTable    = math.MaxUint64
+	squashfsNoFragment = math.MaxUint32
+	squashfsNoXattr    = math.MaxUint32
+
+	// squashfsPadding is the alignment of the end of image, as loop devices
+	// ignore trailing partial sectors.
+	squashfsPadding = 4096
+
+	// squashfsMaxName is the maximum length of name of directory entry and
+	// squashfsMaxXattr is the maximum size of value of extended attribute.
+	squashfsMaxName  = 256
+	squashfsMaxXattr = 64 * 1024
+
+	// squashfsGlobalPrefix is the prefix of names of extended attributes of
+	// root directory, which hold PAX records of global headers.
+	squashfsGlobalPrefix = "global."
+)
+
+// Types of inodes. Directory entries always refer to basic types.
+const (
+	squashfsTypeDir             = 1
+	squashfsTypeFile            = 2
+	squashfsTypeSymlink         = 3
+	squashfsTypeExtendedDir     = 8
+	squashfsTypeExtendedFile    = 9
+	squashfsTypeExtendedSymlink = 10
+)
+
+// Namespaces of extended attributes.
+var squashfsXattrPrefixes = []string{"user.", "trusted.", "security."}
+
+type squashfsSuperblock struct {
+	Magic          uint32
+	InodeCount     uint32
+	ModTime        uint32
+	BlockSize      uint32
+	FragmentCount  uint32
+	Compression    uint16
+	BlockLog       uint16
+	Flags          uint16
+	IDCount        uint16
+	VersionMajor   uint16
+	VersionMinor   uint16
+	RootInode      uint64
+	BytesUsed      uint64
+	IDTable        uint64
+	XattrTable     uint64
+	InodeTable     uint64
+	DirectoryTable uint64
+	FragmentTable  uint64
+	ExportTable    uint64
+}
+
+type squashfsInodeHeader struct {
+	Type   uint16
+	Mode   uint16
+	UID    uint16
+	GID    uint16
+	MTime  uint32
+	Number uint32
+}
+
+type squashfsDirHeader struct {
+	Count  uint32
+	Start  uint32
+	Number uint32
+}
+
+type squashfsDirEntry struct {
+	Offset uint16
+	Number int16
+	Type   uint16
+	Size   uint16
+}
+
+type squashfsXattrID struct {
+	Ref   uint64
+	Count uint32
+	Size  uint32
+}
+
+// squashfsWriter spools tar stream written by Embedder and converts it into
+// SquashFS image, when Embedder is closed.
+type squashfsWriter struct {
+	target io.Writer
+	spool  *os.File
+}
+
+func newSquashfsWriter(w io.Writer) (*squashfsWriter, error) {
+	spool, err := ioutil.TempFile("", "embedfs-squashfs")
+	if err != nil {
+		return nil, err
+	}
+
+	return &squashfsWriter{target: w, spool: spool}, nil
+}
+
+func (writer *squashfsWriter) Write(p []byte) (int, error) {
+	return writer.spool.Write(p)
+}
+
+// close writes image made of spooled tar stream to the target.
+func (writer *squashfsWriter) close() error {
+	defer os.Remove(writer.spool.Name())
+	defer writer.spool.Close()
+
+	tree, err := readSquashfsTree(writer.spool)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.TempFile("", "embedfs-squashfs-data")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(data.Name())
+	defer data.Close()
+
+	image := &squashfsImageWriter{
+		tree:   tree,
+		spool:  writer.spool,
+		data:   data,
+		ids:    map[uint32]uint16{},
+		offset: int64(binary.Size(squashfsSuperblock{})),
+	}
+
+	return image.write(writer.target)
+}
+
+// squashfsNode is file or directory of the tree of image. Hard links are
+// the same node found under several names.
+type squashfsNode struct {
+	header   *tar.Header
+	children map[string]*squashfsNode
+
+	// offset is the offset of data of regular file in spool
+	offset int64
+
+	// number and links are counted before writing, ref is set once inode
+	// is written
+	number  uint32
+	links   uint32
+	written bool
+	ref     uint64
+}
+
+func (node *squashfsNode) isDir() bool {
+	return node.header.Typeflag == tar.TypeDir
+}
+
+// squashfsTree is the final state of files written into tar stream, where
+// later entries replace earlier ones with the same name and whiteouts are
+// applied.
+type squashfsTree struct {
+	root    *squashfsNode
+	global  map[string]string
+	modTime time.Time
+}
+
+// readSquashfsTree builds tree of entries of tar stream.
+func readSquashfsTree(spool io.ReadSeeker) (*squashfsTree, error) {
+	_, err := spool.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &squashfsTree{
+		root:   newSquashfsDir(nil),
+		global: map[string]string{},
+	}
+
+	tarReader := tar.NewReader(spool)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		offset, err := spool.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tree.add(header, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// implicit directories get time of the latest entry
+	tree.walk(tree.root, func(node *squashfsNode) {
+		if node.header.ModTime.IsZero() {
+			node.header.ModTime = tree.modTime
+		}
+	})
+
+	return tree, nil
+}
+
+func newSquashfsDir(header *tar.Header) *squashfsNode {
+	if header == nil {
+		header = &tar.Header{Mode: 0755}
+	}
+
+	header.Typeflag = tar.TypeDir
+
+	return &squashfsNode{header: header, children: map[string]*squashfsNode{}}
+}
+
+// add adds entry described by header, which data starts at specified
+// offset of spool.
+func (tree *squashfsTree) add(header *tar.Header, offset int64) error {
+	if header.Typeflag == tar.TypeXGlobalHeader {
+		for key, value := range header.PAXRecords {
+			tree.global[key] = value
+		}
+
+		return nil
+	}
+
+	name := path.Clean("/" + header.Name)
+
+	if kind, ok := header.PAXRecords[paxWhiteout]; ok {
+		tree.whiteout(name, kind)
+		return nil
+	}
+
+	if header.ModTime.After(tree.modTime) {
+		tree.modTime = header.ModTime
+	}
+
+	parent, base, err := tree.parent(name, true)
+	if err != nil {
+		return err
+	}
+
+	if len(base) > squashfsMaxName {
+		return fmt.Errorf(
+			`%w: name <%s> is longer than %d bytes in SquashFS image`,
+			ErrNotImplemented, name, squashfsMaxName,
+		)
+	}
+
+	existing := parent.children[base]
+	if base == "" {
+		existing = tree.root
+	}
+
+	if existing != nil && existing.isDir() != (header.Typeflag == tar.TypeDir) {
+		return fmt.Errorf(
+			`%w: <%s> is both directory and file in SquashFS image`,
+			ErrNotImplemented, name,
+		)
+	}
+
+	var node *squashfsNode
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		// directory embedded again keeps its contents
+		if existing != nil {
+			existing.header = header
+		} else {
+			node = newSquashfsDir(header)
+		}
+
+	case tar.TypeReg, tar.TypeSymlink:
+		node = &squashfsNode{header: header, offset: offset}
+
+	case tar.TypeLink:
+		source, err := tree.lookup(path.Clean("/" + header.Linkname))
+		if err != nil || source.isDir() {
+			return fmt.Errorf(
+				`%w: hard link <%s> to <%s> in SquashFS image`,
+				ErrNotImplemented, name, header.Linkname,
+			)
+		}
+
+		node = source
+
+	default:
+		return fmt.Errorf(
+			`%w: entry of type %q for <%s> in SquashFS image`,
+			ErrNotImplemented, header.Typeflag, name,
+		)
+	}
+
+	if node != nil {
+		parent.children[base] = node
+	}
+
+	return nil
+}
+
+// whiteout removes file with specified name or contents of directory,
+// depending on kind of whiteout.
+func (tree *squashfsTree) whiteout(name string, kind string) {
+	parent, base, err := tree.parent(name, false)
+	if err != nil || parent == nil {
+		return
+	}
+
+	if base == "" {
+		tree.root.children = map[string]*squashfsNode{}
+		return
+	}
+
+	existing := parent.children[base]
+
+	switch {
+	case kind == whiteoutFile:
+		delete(parent.children, base)
+	case existing != nil && existing.isDir():
+		existing.children = map[string]*squashfsNode{}
+	}
+}
+
+// parent returns directory, which holds entry with specified name, along
+// with base name of entry. Missing directories are created if create is
+// true, otherwise nil directory is returned. Base name of root directory is
+// empty.
+func (tree *squashfsTree) parent(
+	name string, create bool,
+) (*squashfsNode, string, error) {
+	if name == "/" {
+		return tree.root, "", nil
+	}
+
+	dir := tree.root
+	parts := strings.Split(name[1:], "/")
+
+	for i, part := range parts[:len(parts)-1] {
+		child, ok := dir.children[part]
+		switch {
+		case !ok && !create:
+			return nil, "", nil
+		case !ok:
+			child = newSquashfsDir(nil)
+			dir.children[part] = child
+		}
+
+		if !child.isDir() {
+			return nil, "", fmt.Errorf(
+				`%w: <%s> is both directory and file in SquashFS image`,
+				ErrNotImplemented, "/"+strings.Join(parts[:i+1], "/"),
+			)
+		}
+
+		dir = child
+	}
+
+	return dir, parts[len(parts)-1], nil
+}
+
+func (tree *squashfsTree) lookup(name string) (*squashfsNode, error) {
+	parent, base, err := tree.parent(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent == nil {
+		return nil, os.ErrNotExist
+	}
+
+	if base == "" {
+		return tree.root, nil
+	}
+
+	node, ok := parent.children[base]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return node, nil
+}
+
+// walk calls function for every node, children of directories first, in
+// the order inodes are written.
+func (tree *squashfsTree) walk(
+	node *squashfsNode, function func(*squashfsNode),
+) {
+	for _, name := range sortedChildren(node) {
+		child := node.children[name]
+		if child.isDir() {
+			tree.walk(child, function)
+		} else {
+			function(child)
+		}
+	}
+
+	function(node)
+}
+
+func sortedChildren(node *squashfsNode) []string {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// squashfsImageWriter writes data blocks into temporary file and tables
+// into memory, as superblock, which is written first, points to tables.
+type squashfsImageWriter struct {
+	tree  *squashfsTree
+	spool io.ReaderAt
+	data  *os.File
+
+	// offset is the offset of the next data block in image
+	offset int64
+
+	inodes      squashfsMetadata
+	directories squashfsMetadata
+	xattrs      squashfsMetadata
+	xattrIDs    squashfsMetadata
+	xattrCount  uint32
+
+	ids      map[uint32]uint16
+	idValues []uint32
+}
+
+func (image *squashfsImageWriter) write(w io.Writer) error {
+	count := uint32(0)
+
+	image.tree.walk(image.tree.root, func(node *squashfsNode) {
+		node.links++
+
+		if node.number == 0 {
+			count++
+			node.number = count
+		}
+	})
+
+	// directory is linked from parent, itself and its subdirectories, hard
+	// links from every directory entry; root was counted as a child
+	image.tree.walk(image.tree.root, func(node *squashfsNode) {
+		if !node.isDir() {
+			return
+		}
+
+		node.links = 2
+		for _, child := range node.children {
+			if child.isDir() {
+				node.links++
+			}
+		}
+	})
+
+	err := image.writeDir(image.tree.root, count+1)
+	if err != nil {
+		return err
+	}
+
+	return image.finish(w, count)
+}
+
+// writeDir writes inodes of directory contents, then listing and inode of
+// directory itself.
+func (image *squashfsImageWriter) writeDir(
+	dir *squashfsNode, parent uint32,
+) error {
+	names := sortedChildren(dir)
+
+	for _, name := range names {
+		child := dir.children[name]
+
+		var err error
+
+		switch {
+		case child.isDir():
+			err = image.writeDir(child, dir.number)
+		case !child.written:
+			err = image.writeInode(child)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	listing := squashfsListing(dir, names)
+
+	ref := image.directories.position()
+	image.directories.write(listing)
+
+	xattr, err := image.writeXattrs(dir)
+	if err != nil {
+		return err
+	}
+
+	header, err := image.inodeHeader(dir, squashfsTypeDir)
+	if err != nil {
+		return err
+	}
+
+	size := uint32(len(listing) + 3)
+
+	dir.ref = image.inodes.position()
+	dir.written = true
+
+	if xattr == squashfsNoXattr && size <= math.MaxUint16 {
+		image.inodes.writeStruct(header, struct {
+			Block  uint32
+			Links  uint32
+			Size   uint16
+			Offset uint16
+			Parent uint32
+		}{uint32(ref >> 16), dir.links, uint16(size), uint16(ref), parent})
+
+		return nil
+	}
+
+	header.Type = squashfsTypeExtendedDir
+
+	image.inodes.writeStruct(header, struct {
+		Links   uint32
+		Size    uint32
+		Block   uint32
+		Parent  uint32
+		Indexes uint16
+		Offset  uint16
+		Xattr   uint32
+	}{dir.links, size, uint32(ref >> 16), parent, 0, uint16(ref), xattr})
+
+	return nil
+}
+
+// squashfsListing returns directory listing of entries with specified
+// names. Entries are grouped under headers, which share metadata block of
+// inodes and base inode number.
+func squashfsListing(dir *squashfsNode, names []string) []byte {
+	listing := &bytes.Buffer{}
+
+	var (
+		header  squashfsDirHeader
+		entries = &bytes.Buffer{}
+		count   = 0
+	)
+
+	flush := func() {
+		if count == 0 {
+			return
+		}
+
+		header.Count = uint32(count - 1)
+		binary.Write(listing, binary.LittleEndian, header)
+		listing.Write(entries.Bytes())
+
+		entries.Reset()
+		count = 0
+	}
+
+	for _, name := range names {
+		child := dir.children[name]
+
+		difference := int64(child.number) - int64(header.Number)
+		if count == 256 || uint32(child.ref>>16) != header.Start ||
+			difference > math.MaxInt16 || difference < math.MinInt16 {
+			flush()
+		}
+
+		if count == 0 {
+			header.Start = uint32(child.ref >> 16)
+			header.Number = child.number
+		}
+
+		kind := squashfsTypeFile
+		switch child.header.Typeflag {
+		case tar.TypeDir:
+			kind = squashfsTypeDir
+		case tar.TypeSymlink:
+			kind = squashfsTypeSymlink
+		}
+
+		binary.Write(entries, binary.LittleEndian, squashfsDirEntry{
+			Offset: uint16(child.ref),
+			Number: int16(int64(child.number) - int64(header.Number)),
+			Type:   uint16(kind),
+			Size:   uint16(len(name) - 1),
+		})
+
+		entries.WriteString(name)
+		count++
+	}
+
+	flush()
+
+	return listing.Bytes()
+}
+
+// writeInode writes inode of regular file, along with its data, or of
+// symlink.
+func (image *squashfsImageWriter) writeInode(node *squashfsNode) error {
+	xattr, err := image.writeXattrs(node)
+	if err != nil {
+		return err
+	}
+
+	kind := squashfsTypeFile
+	if node.header.Typeflag == tar.TypeSymlink {
+		kind = squashfsTypeSymlink
+	}
+
+	header, err := image.inodeHeader(node, kind)
+	if err != nil {
+		return err
+	}
+
+	node.ref = image.inodes.position()
+	node.written = true
+
+	if kind == squashfsTypeSymlink {
+		if xattr != squashfsNoXattr {
+			header.Type = squashfsTypeExtendedSymlink
+		}
+
+		target := node.header.Linkname
+
+		image.inodes.writeStruct(header, struct {
+			Links uint32
+			Size  uint32
+		}{node.links, uint32(len(target))})
+
+		image.inodes.write([]byte(target))
+
+		if xattr != squashfsNoXattr {
+			image.inodes.writeStruct(xattr)
+		}
+
+		return nil
+	}
+
+	start := image.offset
+
+	sizes, err := image.writeData(node)
+	if err != nil {
+		return err
+	}
+
+	size := node.header.Size
+
+	if xattr == squashfsNoXattr && node.links == 1 &&
+		start <= math.MaxUint32 && size <= math.MaxUint32 {
+		image.inodes.writeStruct(header, struct {
+			Start    uint32
+			Fragment uint32
+			Offset   uint32
+			Size     uint32
+		}{uint32(start), squashfsNoFragment, 0, uint32(size)})
+	} else {
+		header.Type = squashfsTypeExtendedFile
+
+		image.inodes.writeStruct(header, struct {
+			Start    uint64
+			Size     uint64
+			Sparse   uint64
+			Links    uint32
+			Fragment uint32
+			Offset   uint32
+			Xattr    uint32
+		}{
+			uint64(start), uint64(size), 0, node.links,
+			squashfsNoFragment, 0, xattr,
+		})
+	}
+
+	image.inodes.writeStruct(sizes)
+
+	return nil
+}
+
+// writeData writes data of regular file in blocks, which are compressed if
+// that makes them smaller, and returns sizes of stored blocks.
+func (image *squashfsImageWriter) writeData(
+	node *squashfsNode,
+) ([]uint32, error) {
+	sizes := []uint32{}
+	block := make([]byte, squashfsBlockSize)
+
+	for offset := int64(0); offset < node.header.Size; {
+		length := node.header.Size - offset
+		if length > squashfsBlockSize {
+			length = squashfsBlockSize
+		}
+
+		_, err := image.spool.ReadAt(block[:length], node.offset+offset)
+		if err != nil {
+			return nil, err
+		}
+
+		stored := squashfsCompress(block[:length])
+		size := uint32(len(stored))
+
+		if len(stored) >= int(length) {
+			stored = block[:length]
+			size = uint32(length) | squashfsUncompressedBlock
+		}
+
+		_, err = image.data.Write(stored)
+		if err != nil {
+			return nil, err
+		}
+
+		sizes = append(sizes, size)
+		image.offset += int64(len(stored))
+		offset += length
+	}
+
+	return sizes, nil
+}
+
+// inodeHeader returns common header of inode of specified type.
+func (image *squashfsImageWriter) inodeHeader(
+	node *squashfsNode, kind int,
+) (squashfsInodeHeader, error) {
+	uid, err := image.id(node.header.Uid)
+	if err != nil {
+		return squashfsInodeHeader{}, err
+	}
+
+	gid, err := image.id(node.header.Gid)
+	if err != nil {
+		return squashfsInodeHeader{}, err
+	}
+
+	return squashfsInodeHeader{
+		Type:   uint16(kind),
+		Mode:   uint16(node.header.Mode & 07777),
+		UID:    uid,
+		GID:    gid,
+		MTime:  squashfsTime(node.header.ModTime),
+		Number: node.number,
+	}, nil
+}
+
+// id returns index of owner id in id table.
+func (image *squashfsImageWriter) id(id int) (uint16, error) {
+	if id < 0 || id > math.MaxUint32 {
+		return 0, fmt.Errorf(
+			`%w: owner id %d in SquashFS image`, ErrNotImplemented, id,
+		)
+	}
+
+	index, ok := image.ids[uint32(id)]
+	if ok {
+		return index, nil
+	}
+
+	if len(image.idValues) > math.MaxUint16 {
+		return 0, fmt.Errorf(
+			`%w: more than %d owner ids in SquashFS image`,
+			ErrNotImplemented, math.MaxUint16+1,
+		)
+	}
+
+	index = uint16(len(image.idValues))
+
+	image.ids[uint32(id)] = index
+	image.idValues = append(image.idValues, uint32(id))
+
+	return index, nil
+}
+
+// writeXattrs writes PAX records of embedfs and extended attributes of node
+// as extended attributes of inode, returning their index or squashfsNoXattr.
+func (image *squashfsImageWriter) writeXattrs(
+	node *squashfsNode,
+) (uint32, error) {
+	records := map[string]string{}
+
+	for key, value := range node.header.PAXRecords {
+		switch {
+		case strings.HasPrefix(key, paxPrefix):
+			records["user."+key] = value
+		case strings.HasPrefix(key, "SCHILY.xattr."):
+			records[strings.TrimPrefix(key, "SCHILY.xattr.")] = value
+		}
+	}
+
+	if node == image.tree.root {
+		for key, value := range image.tree.global {
+			records["user."+squashfsGlobalPrefix+key] = value
+		}
+	}
+
+	keys := []string{}
+	for key := range records {
+		if squashfsXattrType(key) >= 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return squashfsNoXattr, nil
+	}
+
+	sort.Strings(keys)
+
+	id := squashfsXattrID{Ref: image.xattrs.position()}
+
+	for _, key := range keys {
+		kind := squashfsXattrType(key)
+		name := strings.TrimPrefix(key, squashfsXattrPrefixes[kind])
+		value := records[key]
+
+		if len(value) > squashfsMaxXattr {
+			return 0, fmt.Errorf(
+				`%w: record <%s> of <%s> is longer than %d bytes`,
+				ErrNotImplemented, key, node.header.Name, squashfsMaxXattr,
+			)
+		}
+
+		image.xattrs.writeStruct(uint16(kind), uint16(len(name)))
+		image.xattrs.write([]byte(name))
+		image.xattrs.writeStruct(uint32(len(value)))
+		image.xattrs.write([]byte(value))
+
+		id.Count++
+		id.Size += uint32(8 + len(name) + len(value))
+	}
+
+	image.xattrIDs.writeStruct(id)
+	image.xattrCount++
+
+	return image.xattrCount - 1, nil
+}
+
+// squashfsXattrType returns type of namespace of extended attribute with
+// specified name, or -1 if it can't be stored.
+func squashfsXattrType(name string) int {
+	for kind, prefix := range squashfsXattrPrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return kind
+		}
+	}
+
+	return -1
+}
+
+// finish writes superblock, data blocks and tables.
+func (image *squashfsImageWriter) finish(w io.Writer, count uint32) error {
+	inodes := image.inodes.bytes()
+	directories := image.directories.bytes()
+
+	ids := squashfsMetadata{}
+	ids.writeStruct(image.idValues)
+
+	idBlocks := ids.bytes()
+
+	super := squashfsSuperblock{
+		Magic:         squashfsMagic,
+		InodeCount:    count,
+		ModTime:       squashfsTime(image.tree.modTime),
+		BlockSize:     squashfsBlockSize,
+		Compression:   squashfsCompressionGzip,
+		BlockLog:      squashfsBlockLog,
+		Flags:         squashfsNoFragments,
+		IDCount:       uint16(len(image.idValues)),
+		VersionMajor:  4,
+		RootInode:     image.tree.root.ref,
+		XattrTable:    squashfsNoTable,
+		FragmentTable: squashfsNoTable,
+		ExportTable:   squashfsNoTable,
+	}
+
+	position := uint64(image.offset)
+
+	super.InodeTable = position
+	position += uint64(len(inodes))
+
+	super.DirectoryTable = position
+	position += uint64(len(directories))
+
+	tables := [][]byte{inodes, directories, idBlocks}
+
+	// lookup tables hold locations of metadata blocks of tables
+	lookup := func(metadata *squashfsMetadata, start uint64) []byte {
+		locations := make([]uint64, len(metadata.blocks))
+		for i, block := range metadata.blocks {
+			locations[i] = start + uint64(block)
+		}
+
+		buffer := &bytes.Buffer{}
+		binary.Write(buffer, binary.LittleEndian, locations)
+
+		return buffer.Bytes()
+	}
+
+	idLookup := lookup(&ids, position)
+	position += uint64(len(idBlocks))
+
+	super.IDTable = position
+	position += uint64(len(idLookup))
+
+	tables = append(tables, idLookup)
+
+	if image.xattrCount == 0 {
+		super.Flags |= squashfsNoXattrs
+	} else {
+		xattrs := image.xattrs.bytes()
+		xattrIDs := image.xattrIDs.bytes()
+
+		xattrStart := position
+		position += uint64(len(xattrs))
+
+		xattrLookup := &bytes.Buffer{}
+		binary.Write(xattrLookup, binary.LittleEndian, struct {
+			Start  uint64
+			Count  uint32
+			Unused uint32
+		}{xattrStart, image.xattrCount, 0})
+
+		xattrLookup.Write(lookup(&image.xattrIDs, position))
+		position += uint64(len(xattrIDs))
+
+		super.XattrTable = position
+		position += uint64(xattrLookup.Len())
+
+		tables = append(tables, xattrs, xattrIDs, xattrLookup.Bytes())
+	}
+
+	super.BytesUsed = position
+
+	err := binary.Write(w, binary.LittleEndian, super)
+	if err != nil {
+		return err
+	}
+
+	_, err = image.data.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(w, image.data)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		_, err = w.Write(table)
+		if err != nil {
+			return err
+		}
+	}
+
+	padding := (squashfsPadding - position%squashfsPadding) % squashfsPadding
+
+	_, err = w.Write(make([]byte, padding))
+
+	return err
+}
+
+func squashfsTime(modTime time.Time) uint32 {
+	seconds := modTime.Unix()
+
+	switch {
+	case seconds < 0:
+		return 0
+	case seconds > math.MaxUint32:
+		return math.MaxUint32
+	}
+
+	return uint32(seconds)
+}
+
+// squashfsCompress returns data compressed by zlib, which is what SquashFS
+// calls gzip compression.
+func squashfsCompress(data []byte) []byte {
+	compressed := &bytes.Buffer{}
+
+	compressor, _ := zlib.NewWriterLevel(compressed, zlib.BestCompression)
+	compressor.Write(data)
+	compressor.Close()
+
+	return compressed.Bytes()
+}
+
+// squashfsMetadata is table written in metadata blocks, which are
+// compressed separately.
+type squashfsMetadata struct {
+	data    []byte
+	pending []byte
+
+	// blocks holds offsets of metadata blocks in data
+	blocks []int
+}
+
+// position returns reference to the next written byte: offset of its
+// metadata block in the upper bits and its offset in decompressed block in
+// the lower 16 bits.
+func (metadata *squashfsMetadata) position() uint64 {
+	return uint64(len(metadata.data))<<16 | uint64(len(metadata.pending))
+}
+
+func (metadata *squashfsMetadata) write(p []byte) {
+	metadata.pending = append(metadata.pending, p...)
+
+	for len(metadata.pending) >= squashfsMetadataSize {
+		metadata.flush(squashfsMetadataSize)
+	}
+}
+
+func (metadata *squashfsMetadata) writeStruct(values ...interface{}) {
+	buffer := &bytes.Buffer{}
+	for _, value := range values {
+		binary.Write(buffer, binary.LittleEndian, value)
+	}
+
+	metadata.write(buffer.Bytes())
+}
+
+// flush writes metadata block of specified size of pending data.
+func (metadata *squashfsMetadata) flush(size int) {
+	block := metadata.pending[:size]
+
+	header := uint16(0)
+
+	stored := squashfsCompress(block)
+	if len(stored) < size {
+		header = uint16(len(stored))
+	} else {
+		stored = block
+		header = uint16(size) | squashfsUncompressedMetadata
+	}
+
+	metadata.blocks = append(metadata.blocks, len(metadata.data))
+	metadata.data = binary.LittleEndian.AppendUint16(metadata.data, header)
+	metadata.data = append(metadata.data, stored...)
+	metadata.pending = append([]byte{}, metadata.pending[size:]...)
+}
+
+// bytes returns written table.
+func (metadata *squashfsMetadata) bytes() []byte {
+	if len(metadata.pending) > 0 {
+		metadata.flush(len(metadata.pending))
+	}
+
+	return metadata.data
+}
+
+// isSquashfs returns true if data at specified offset of origin starts with
+// SquashFS magic, which means that embedfs is written with Squashfs option.
+func isSquashfs(origin io.ReaderAt, offset int64) bool {
+	magic := make([]byte, 4)
+
+	_, err := origin.ReadAt(magic, offset)
+	if err != nil {
+		return false
+	}
+
+	return binary.LittleEndian.Uint32(magic) == squashfsMagic
+}
+
+// squashfsImage reads SquashFS image, which starts at offset of origin.
+type squashfsImage struct {
+	origin io.ReaderAt
+	offset int64
+	super  squashfsSuperblock
+	ids    []uint32
+
+	// xattrStart is the location of extended attributes and xattrBlocks
+	// are locations of metadata blocks of their index
+	xattrStart  uint64
+	xattrBlocks []uint64
+	xattrCount  uint32
+
+	// metadata holds decompressed metadata blocks by their location
+	metadata map[uint64]squashfsBlock
+}
+
+type squashfsBlock struct {
+	data []byte
+	next uint64
+}
+
+// squashfsInode is inode of any supported type.
+type squashfsInode struct {
+	squashfsInodeHeader
+
+	xattr uint32
+
+	// dirRef is the reference to directory listing of dirSize bytes
+	dirRef  uint64
+	dirSize uint32
+
+	// file data is stored in blocks of specified sizes starting from start
+	start  uint64
+	size   uint64
+	blocks []uint32
+
+	target string
+}
+
+func openSquashfs(origin io.ReaderAt, offset, end int64) (*squashfsImage, error) {
+	image := &squashfsImage{
+		origin:   origin,
+		offset:   offset,
+		metadata: map[uint64]squashfsBlock{},
+	}
+
+	super := &image.super
+
+	err := binary.Read(
+		io.NewSectionReader(origin, offset, end-offset),
+		binary.LittleEndian, super,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't read SquashFS superblock: %s`,
+			ErrCorrupted, err)
+	}
+
+	switch {
+	case super.Magic != squashfsMagic || super.VersionMajor != 4:
+		return nil, fmt.Errorf(`%w: invalid SquashFS superblock`, ErrCorrupted)
+
+	case super.BlockLog < 12 || super.BlockLog > 20 ||
+		super.BlockSize != 1<<super.BlockLog:
+		return nil, fmt.Errorf(`%w: invalid SquashFS block size`, ErrCorrupted)
+
+	case super.BytesUsed > uint64(end-offset) ||
+		super.InodeTable >= super.DirectoryTable ||
+		super.DirectoryTable > super.IDTable ||
+		super.IDTable >= super.BytesUsed:
+		return nil, fmt.Errorf(`%w: invalid SquashFS tables`, ErrCorrupted)
+
+	case super.Compression != squashfsCompressionGzip:
+		return nil, fmt.Errorf(
+			`%w: SquashFS compression %d`, ErrNotImplemented, super.Compression,
+		)
+	}
+
+	err = image.readIDs()
+	if err == nil {
+		err = image.readXattrIndex()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// readIDs reads table of owner ids.
+func (image *squashfsImage) readIDs() error {
+	super := image.super
+
+	var first uint64
+
+	err := image.readAt(&first, super.IDTable)
+	if err != nil {
+		return err
+	}
+
+	if super.IDCount == 0 || first < super.DirectoryTable || first >= super.IDTable {
+		return fmt.Errorf(`%w: invalid SquashFS id table`, ErrCorrupted)
+	}
+
+	image.ids = make([]uint32, super.IDCount)
+
+	return binary.Read(
+		image.cursor(first, super.IDTable, 0), binary.LittleEndian, image.ids,
+	)
+}
+
+// readXattrIndex reads location of metadata blocks of index of extended
+// attributes.
+func (image *squashfsImage) readXattrIndex() error {
+	super := image.super
+
+	if super.XattrTable == squashfsNoTable {
+		return nil
+	}
+
+	var table struct {
+		Start  uint64
+		Count  uint32
+		Unused uint32
+	}
+
+	err := image.readAt(&table, super.XattrTable)
+	if err != nil {
+		return err
+	}
+
+	blocks := (uint64(table.Count)*16 + squashfsMetadataSize - 1) /
+		squashfsMetadataSize
+
+	if table.Count == 0 || table.Start <= super.IDTable ||
+		table.Start >= super.XattrTable ||
+		super.XattrTable+16+blocks*8 > super.BytesUsed {
+		return fmt.Errorf(`%w: invalid SquashFS xattr table`, ErrCorrupted)
+	}
+
+	image.xattrStart = table.Start
+	image.xattrCount = table.Count
+	image.xattrBlocks = make([]uint64, blocks)
+
+	return image.readAt(image.xattrBlocks, super.XattrTable+16)
+}
+
+// readAt reads value stored at specified location of image.
+func (image *squashfsImage) readAt(value interface{}, location uint64) error {
+	size := uint64(binary.Size(value))
+	if location > image.super.BytesUsed-size {
+		return fmt.Errorf(`%w: SquashFS table is out of image`, ErrCorrupted)
+	}
+
+	data := make([]byte, size)
+
+	_, err := image.origin.ReadAt(data, image.offset+int64(location))
+	if err != nil {
+		return err
+	}
+
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, value)
+}
+
+// block returns decompressed metadata block stored at specified location,
+// which should end before end.
+func (image *squashfsImage) block(location, end uint64) (squashfsBlock, error) {
+	block, ok := image.metadata[location]
+	if ok {
+		return block, nil
+	}
+
+	var header uint16
+
+	err := image.readAt(&header, location)
+	if err != nil {
+		return block, err
+	}
+
+	size := uint64(header &^ squashfsUncompressedMetadata)
+	if size == 0 || size > squashfsMetadataSize || location+2+size > end {
+		return block, fmt.Errorf(
+			`%w: invalid SquashFS metadata block at %d`, ErrCorrupted, location,
+		)
+	}
+
+	block.data = make([]byte, size)
+	block.next = location + 2 + size
+
+	_, err = image.origin.ReadAt(block.data, image.offset+int64(location)+2)
+	if err != nil {
+		return block, err
+	}
+
+	if header&squashfsUncompressedMetadata == 0 {
+		block.data, err = squashfsDecompress(block.data, squashfsMetadataSize)
+		if err != nil {
+			return block, err
+		}
+	}
+
+	image.metadata[location] = block
+
+	return block, nil
+}
+
+// cursor returns reader of table, which consists of metadata blocks
+// starting at start and ending before end, starting from specified
+// reference.
+func (image *squashfsImage) cursor(start, end uint64, ref uint64) io.Reader {
+	return &squashfsCursor{
+		image:    image,
+		location: start + ref>>16,
+		offset:   int(ref & 0xFFFF),
+		end:      end,
+	}
+}
+
+type squashfsCursor struct {
+	image    *squashfsImage
+	location uint64
+	offset   int
+	end      uint64
+}
+
+func (cursor *squashfsCursor) Read(p []byte) (int, error) {
+	read := 0
+
+	for read < len(p) {
+		block, err := cursor.image.block(cursor.location, cursor.end)
+		if err != nil {
+			return read, err
+		}
+
+		if cursor.offset > len(block.data) {
+			return read, fmt.Errorf(
+				`%w: SquashFS reference is out of metadata block`, ErrCorrupted,
+			)
+		}
+
+		copied := copy(p[read:], block.data[cursor.offset:])
+		read += copied
+		cursor.offset += copied
+
+		if cursor.offset == len(block.data) {
+			cursor.location = block.next
+			cursor.offset = 0
+		}
+	}
+
+	return read, nil
+}
+
+// inode reads inode with specified reference.
+func (image *squashfsImage) inode(ref uint64) (*squashfsInode, error) {
+	super := image.super
+
+	reader := image.cursor(super.InodeTable, super.DirectoryTable, ref)
+
+	inode := &squashfsInode{xattr: squashfsNoXattr}
+
+	err := binary.Read(reader, binary.LittleEndian, &inode.squashfsInodeHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var fragment uint32
+
+	switch inode.Type {
+	case squashfsTypeDir:
+		var dir struct {
+			Block  uint32
+			Links  uint32
+			Size   uint16
+			Offset uint16
+			Parent uint32
+		}
+
+		err = binary.Read(reader, binary.LittleEndian, &dir)
+
+		inode.dirRef = uint64(dir.Block)<<16 | uint64(dir.Offset)
+		inode.dirSize = uint32(dir.Size)
+
+	case squashfsTypeExtendedDir:
+		var dir struct {
+			Links   uint32
+			Size    uint32
+			Block   uint32
+			Parent  uint32
+			Indexes uint16
+			Offset  uint16
+			Xattr   uint32
+		}
+
+		// index of large directory is not used, as listing is read whole
+		err = binary.Read(reader, binary.LittleEndian, &dir)
+
+		inode.dirRef = uint64(dir.Block)<<16 | uint64(dir.Offset)
+		inode.dirSize = dir.Size
+		inode.xattr = dir.Xattr
+
+	case squashfsTypeFile:
+		var file struct {
+			Start    uint32
+			Fragment uint32
+			Offset   uint32
+			Size     uint32
+		}
+
+		err = binary.Read(reader, binary.LittleEndian, &file)
+
+		inode.start = uint64(file.Start)
+		inode.size = uint64(file.Size)
+		fragment = file.Fragment
+
+	case squashfsTypeExtendedFile:
+		var file struct {
+			Start    uint64
+			Size     uint64
+			Sparse   uint64
+			Links    uint32
+			Fragment uint32
+			Offset   uint32
+			Xattr    uint32
+		}
+
+		err = binary.Read(reader, binary.LittleEndian, &file)
+
+		inode.start = file.Start
+		inode.size = file.Size
+		inode.xattr = file.Xattr
+		fragment = file.Fragment
+
+	case squashfsTypeSymlink, squashfsTypeExtendedSymlink:
+		var symlink struct {
+			Links uint32
+			Size  uint32
+		}
+
+		err = binary.Read(reader, binary.LittleEndian, &symlink)
+		if err != nil {
+			break
+		}
+
+		if symlink.Size > squashfsMetadataSize {
+			return nil, fmt.Errorf(`%w: invalid SquashFS symlink`, ErrCorrupted)
+		}
+
+		target := make([]byte, symlink.Size)
+
+		_, err = io.ReadFull(reader, target)
+		inode.target = string(target)
+
+		if err == nil && inode.Type == squashfsTypeExtendedSymlink {
+			err = binary.Read(reader, binary.LittleEndian, &inode.xattr)
+		}
+
+	default:
+		return nil, fmt.Errorf(
+			`%w: SquashFS inode of type %d`, ErrNotImplemented, inode.Type,
+		)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if inode.Type == squashfsTypeFile || inode.Type == squashfsTypeExtendedFile {
+		if fragment != squashfsNoFragment {
+			return nil, fmt.Errorf(
+				`%w: SquashFS fragments`, ErrNotImplemented,
+			)
+		}
+
+		err = image.readBlocks(inode, reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if int(inode.UID) >= len(image.ids) || int(inode.GID) >= len(image.ids) {
+		return nil, fmt.Errorf(`%w: invalid SquashFS owner`, ErrCorrupted)
+	}
+
+	return inode, nil
+}
+
+// readBlocks reads sizes of data blocks of file, which follow its inode.
+func (image *squashfsImage) readBlocks(
+	inode *squashfsInode, reader io.Reader,
+) error {
+	blockSize := uint64(image.super.BlockSize)
+
+	count := (inode.size + blockSize - 1) / blockSize
+	stored := uint64(0)
+
+	// sizes are read in chunks, so crafted size of file can't make them
+	// to take more memory than the inode table does
+	for uint64(len(inode.blocks)) < count {
+		chunk := count - uint64(len(inode.blocks))
+		if chunk > squashfsMetadataSize {
+			chunk = squashfsMetadataSize
+		}
+
+		sizes := make([]uint32, chunk)
+
+		err := binary.Read(reader, binary.LittleEndian, sizes)
+		if err != nil {
+			return err
+		}
+
+		for _, size := range sizes {
+			size &^= squashfsUncompressedBlock
+			if uint64(size) > blockSize {
+				return fmt.Errorf(`%w: invalid SquashFS block size`, ErrCorrupted)
+			}
+
+			stored += uint64(size)
+		}
+
+		inode.blocks = append(inode.blocks, sizes...)
+	}
+
+	if inode.start > image.super.InodeTable ||
+		stored > image.super.InodeTable-inode.start {
+		return fmt.Errorf(`%w: SquashFS file data is out of image`, ErrCorrupted)
+	}
+
+	return nil
+}
+
+// directory returns names and inode references of entries listed in
+// directory.
+func (image *squashfsImage) directory(
+	inode *squashfsInode,
+) ([]string, []uint64, error) {
+	if inode.dirSize < 3 {
+		return nil, nil, fmt.Errorf(`%w: invalid SquashFS directory`, ErrCorrupted)
+	}
+
+	super := image.super
+
+	reader := &io.LimitedReader{
+		R: image.cursor(super.DirectoryTable, super.IDTable, inode.dirRef),
+		N: int64(inode.dirSize - 3),
+	}
+
+	names := []string{}
+	refs := []uint64{}
+
+	for reader.N > 0 {
+		var header squashfsDirHeader
+
+		err := binary.Read(reader, binary.LittleEndian, &header)
+		if err != nil {
+			return nil, nil, squashfsTruncated(err)
+		}
+
+		if header.Count >= 256 {
+			return nil, nil, fmt.Errorf(
+				`%w: invalid SquashFS directory`, ErrCorrupted,
+			)
+		}
+
+		for i := uint32(0); i <= header.Count; i++ {
+			var entry squashfsDirEntry
+
+			err := binary.Read(reader, binary.LittleEndian, &entry)
+			if err != nil {
+				return nil, nil, squashfsTruncated(err)
+			}
+
+			name := make([]byte, int(entry.Size)+1)
+
+			_, err = io.ReadFull(reader, name)
+			if err != nil {
+				return nil, nil, squashfsTruncated(err)
+			}
+
+			if len(name) > squashfsMaxName || bytes.IndexByte(name, '/') >= 0 ||
+				string(name) == "." || string(name) == ".." {
+				return nil, nil, fmt.Errorf(
+					`%w: invalid name %q in SquashFS directory`,
+					ErrCorrupted, name,
+				)
+			}
+
+			names = append(names, string(name))
+			refs = append(refs, uint64(header.Start)<<16|uint64(entry.Offset))
+		}
+	}
+
+	return names, refs, nil
+}
+
+func squashfsTruncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf(`%w: SquashFS table is truncated`, ErrCorrupted)
+	}
+
+	return err
+}
+
+// xattrs returns extended attributes of inode.
+func (image *squashfsImage) xattrs(inode *squashfsInode) (map[string]string, error) {
+	if inode.xattr == squashfsNoXattr {
+		return nil, nil
+	}
+
+	if inode.xattr >= image.xattrCount {
+		return nil, fmt.Errorf(`%w: invalid SquashFS xattr index`, ErrCorrupted)
+	}
+
+	var id squashfsXattrID
+
+	position := uint64(inode.xattr) * 16
+	block := image.xattrBlocks[position/squashfsMetadataSize]
+
+	err := binary.Read(
+		image.cursor(block, image.super.XattrTable,
+			position%squashfsMetadataSize),
+		binary.LittleEndian, &id,
+	)
+	if err != nil {
+		return nil, squashfsTruncated(err)
+	}
+
+	reader := image.cursor(image.xattrStart, image.super.XattrTable, id.Ref)
+
+	xattrs := map[string]string{}
+
+	for i := uint32(0); i < id.Count; i++ {
+		var key struct {
+			Type uint16
+			Size uint16
+		}
+
+		err := binary.Read(reader, binary.LittleEndian, &key)
+		if err != nil {
+			return nil, squashfsTruncated(err)
+		}
+
+		kind := int(key.Type & 0xFF)
+		if kind >= len(squashfsXattrPrefixes) {
+			return nil, fmt.Errorf(`%w: invalid SquashFS xattr`, ErrCorrupted)
+		}
+
+		name := make([]byte, key.Size)
+
+		_, err = io.ReadFull(reader, name)
+		if err != nil {
+			return nil, squashfsTruncated(err)
+		}
+
+		value, err := image.xattrValue(reader, key.Type&0x100 != 0)
+		if err != nil {
+			return nil, err
+		}
+
+		xattrs[squashfsXattrPrefixes[kind]+string(name)] = value
+	}
+
+	return xattrs, nil
+}
+
+// xattrValue reads value of extended attribute, which is either stored in
+// place, or referred.
+func (image *squashfsImage) xattrValue(
+	reader io.Reader, referred bool,
+) (string, error) {
+	var size uint32
+
+	err := binary.Read(reader, binary.LittleEndian, &size)
+	if err != nil {
+		return "", squashfsTruncated(err)
+	}
+
+	if referred {
+		var ref uint64
+
+		err = binary.Read(reader, binary.LittleEndian, &ref)
+		if err != nil {
+			return "", squashfsTruncated(err)
+		}
+
+		return image.xattrValue(
+			image.cursor(image.xattrStart, image.super.XattrTable, ref), false,
+		)
+	}
+
+	if size > squashfsMaxXattr {
+		return "", fmt.Errorf(`%w: SquashFS xattr is too large`, ErrCorrupted)
+	}
+
+	value := make([]byte, size)
+
+	_, err = io.ReadFull(reader, value)
+	if err != nil {
+		return "", squashfsTruncated(err)
+	}
+
+	return string(value), nil
+}
+
+// header returns tar header of file with specified inode. Extended
+// attributes are turned back into PAX records.
+func (image *squashfsImage) header(
+	inode *squashfsInode, name string,
+) (*tar.Header, error) {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(inode.Mode & 07777),
+		Uid:     int(image.ids[inode.UID]),
+		Gid:     int(image.ids[inode.GID]),
+		ModTime: time.Unix(int64(inode.MTime), 0),
+		Format:  tar.FormatPAX,
+	}
+
+	switch inode.Type {
+	case squashfsTypeDir, squashfsTypeExtendedDir:
+		header.Typeflag = tar.TypeDir
+	case squashfsTypeSymlink, squashfsTypeExtendedSymlink:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = inode.target
+	default:
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(inode.size)
+	}
+
+	xattrs, err := image.xattrs(inode)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range xattrs {
+		record := strings.TrimPrefix(key, "user.")
+
+		switch {
+		case strings.HasPrefix(record, squashfsGlobalPrefix):
+			continue
+		case record == key || !strings.HasPrefix(record, paxPrefix):
+			record = "SCHILY.xattr." + key
+		}
+
+		if header.PAXRecords == nil {
+			header.PAXRecords = map[string]string{}
+		}
+
+		header.PAXRecords[record] = value
+	}
+
+	return header, nil
+}
+
+// globalRecords returns PAX records of global headers, which are stored in
+// extended attributes of root directory.
+func (image *squashfsImage) globalRecords() (map[string]string, error) {
+	root, err := image.inode(image.super.RootInode)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs, err := image.xattrs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string]string{}
+
+	for key, value := range xattrs {
+		if strings.HasPrefix(key, "user."+squashfsGlobalPrefix) {
+			records[strings.TrimPrefix(key, "user."+squashfsGlobalPrefix)] = value
+		}
+	}
+
+	return records, nil
+}
+
+// squashfsGlobalStream returns tar stream, which holds only global header
+// with PAX records of global headers of embedfs written as SquashFS image.
+func squashfsGlobalStream(
+	origin io.ReaderAt, offset, end int64,
+) (io.Reader, error) {
+	image, err := openSquashfs(origin, offset, end)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := image.globalRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(stream)
+
+	if len(records) > 0 {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			PAXRecords: records,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// scanSquashfs reads index of embedfs written with Squashfs option by
+// walking its directories. Files, which are hard linked, are listed as
+// hard links to the first name found.
+func (fs *EmbedFs) scanSquashfs(ctx context.Context) error {
+	image, err := openSquashfs(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return err
+	}
+
+	root, err := image.inode(image.super.RootInode)
+	if err != nil {
+		return err
+	}
+
+	if root.Type != squashfsTypeDir && root.Type != squashfsTypeExtendedDir {
+		return fmt.Errorf(`%w: SquashFS root is not directory`, ErrCorrupted)
+	}
+
+	walker := &squashfsWalker{
+		fs:      fs,
+		image:   image,
+		visited: map[uint64]bool{image.super.RootInode: true},
+		names:   map[uint64]string{},
+	}
+
+	return walker.walk(ctx, root, "/")
+}
+
+type squashfsWalker struct {
+	fs    *EmbedFs
+	image *squashfsImage
+
+	// visited holds directories, which are already walked, and names
+	// holds names of files by their inodes
+	visited map[uint64]bool
+	names   map[uint64]string
+}
+
+func (walker *squashfsWalker) walk(
+	ctx context.Context, dir *squashfsInode, dirName string,
+) error {
+	names, refs, err := walker.image.directory(dir)
+	if err != nil {
+		return err
+	}
+
+	for i, ref := range refs {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		name := path.Join(dirName, names[i])
+		offset := walker.fs.offset + int64(walker.image.super.InodeTable+ref>>16)
+
+		inode, err := walker.image.inode(ref)
+		if err != nil {
+			return &EntryError{Name: name, Offset: offset, Err: err}
+		}
+
+		header, err := walker.image.header(inode, name)
+		if err != nil {
+			return &EntryError{Name: name, Offset: offset, Err: err}
+		}
+
+		entry := &embedFsEntry{name: name, headerOffset: offset}
+
+		isDir := header.Typeflag == tar.TypeDir
+
+		switch source, linked := walker.names[ref]; {
+		case isDir && walker.visited[ref]:
+			return &EntryError{
+				Name:   name,
+				Offset: offset,
+				Err:    fmt.Errorf(`%w: SquashFS directory loop`, ErrCorrupted),
+			}
+
+		case isDir:
+			walker.visited[ref] = true
+
+		// records of inode, like checksum, belong to the first name
+		case linked:
+			header.Typeflag = tar.TypeLink
+			header.Linkname = source
+			header.Size = 0
+			header.PAXRecords = nil
+
+		default:
+			walker.names[ref] = name
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			entry.size = header.Size
+			entry.squashed = walker.image.file(inode)
+		}
+
+		err = walker.fs.addEntry(entry, header)
+		if err != nil {
+			return err
+		}
+
+		if isDir {
+			err = walker.walk(ctx, inode, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// squashfsFile is the source of data of file stored in SquashFS image. The
+// last decompressed block is kept, so file can be read by small chunks.
+type squashfsFile struct {
+	origin    io.ReaderAt
+	blockSize int64
+	size      int64
+
+	// offsets holds locations of blocks in origin, the last one is the end
+	// of the last block
+	offsets []int64
+	blocks  []uint32
+
+	mutex  sync.Mutex
+	cached int
+	data   []byte
+}
+
+func (image *squashfsImage) file(inode *squashfsInode) *squashfsFile {
+	file := &squashfsFile{
+		origin:    image.origin,
+		blockSize: int64(image.super.BlockSize),
+		size:      int64(inode.size),
+		blocks:    inode.blocks,
+		offsets:   make([]int64, len(inode.blocks)+1),
+		cached:    -1,
+	}
+
+	file.offsets[0] = image.offset + int64(inode.start)
+	for i, size := range inode.blocks {
+		file.offsets[i+1] = file.offsets[i] +
+			int64(size&^squashfsUncompressedBlock)
+	}
+
+	return file
+}
+
+// ReadAt reads decompressed data of file starting from specified offset.
+func (file *squashfsFile) ReadAt(p []byte, off int64) (int, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	read := 0
+
+	for read < len(p) && off < file.size {
+		index := int(off / file.blockSize)
+
+		err := file.load(index)
+		if err != nil {
+			return read, err
+		}
+
+		copied := copy(p[read:], file.data[off-int64(index)*file.blockSize:])
+		read += copied
+		off += int64(copied)
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// load decompresses block with specified index.
+func (file *squashfsFile) load(index int) error {
+	if file.cached == index {
+		return nil
+	}
+
+	length := file.size - int64(index)*file.blockSize
+	if length > file.blockSize {
+		length = file.blockSize
+	}
+
+	size := file.blocks[index]
+
+	// blocks of zero size are holes of sparse files
+	if size == 0 {
+		file.data = make([]byte, length)
+		file.cached = index
+
+		return nil
+	}
+
+	stored := make([]byte, file.offsets[index+1]-file.offsets[index])
+
+	_, err := file.origin.ReadAt(stored, file.offsets[index])
+	if err != nil {
+		return err
+	}
+
+	data := stored
+	if size&squashfsUncompressedBlock == 0 {
+		data, err = squashfsDecompress(stored, int(length))
+		if err != nil {
+			return err
+		}
+	}
+
+	if int64(len(data)) != length {
+		return fmt.Errorf(
+			`%w: SquashFS block size differs from expected`, ErrCorrupted,
+		)
+	}
+
+	file.data = data
+	file.cached = index
+
+	return nil
+}
+
+// storedRange returns range of origin, which holds blocks of file.
+func (file *squashfsFile) storedRange() ByteRange {
+	return ByteRange{
+		Offset: file.offsets[0],
+		Length: file.offsets[len(file.offsets)-1] - file.offsets[0],
+	}
+}
+
+// squashfsDecompress decompresses zlib stream, which should not be larger
+// than specified size.
+func squashfsDecompress(data []byte, size int) ([]byte, error) {
+	decompressor, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted, err)
+	}
+
+	decompressed, err := ioutil.ReadAll(
+		io.LimitReader(decompressor, int64(size)+1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted, err)
+	}
+
+	if len(decompressed) > size {
+		return nil, fmt.Errorf(
+			`%w: SquashFS block is larger than %d bytes`, ErrCorrupted, size,
+		)
+	}
+
+	return decompressed, nil
+}
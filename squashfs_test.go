@@ -0,0 +1,302 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func writeSquashfsSample() string {
+	sample, err := ioutil.TempFile("", "embedfs-squashfs")
+	if err != nil {
+		panic(err)
+	}
+
+	defer sample.Close()
+
+	// several blocks, which are compressed separately
+	for i := 0; i < 40000; i++ {
+		_, err = fmt.Fprintf(sample, "line %d\n", i)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return sample.Name()
+}
+
+func TestCanWriteSquashfsImage(t *testing.T) {
+	sample := writeSquashfsSample()
+	defer os.Remove(sample)
+
+	container := mockfile.New("lala105")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Squashfs: true,
+		Version:  "1.0",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(sample, "/sample")
+	if err != nil {
+		panic(err)
+	}
+
+	embedder.SetAttr("channel", "beta")
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from SquashFS image")
+	}
+
+	if fs.Version() != "1.0" || fs.Attr("channel") != "beta" {
+		t.Fatal("global headers are not read from SquashFS image")
+	}
+
+	expected, err := ioutil.ReadFile(sample)
+	if err != nil {
+		panic(err)
+	}
+
+	reader, err := fs.Open("/sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chunk spanning the end of the first block
+	data := make([]byte, 100)
+	offset := int64(squashfsBlockSize - 50)
+
+	_, err = reader.ReadAt(data, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected[offset:offset+100]) {
+		t.Fatal("wrong chunk read across blocks")
+	}
+
+	if !bytes.Equal(fs.MustReadFile("/sample"), expected) {
+		t.Fatal("file </sample> differs from embedded one")
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container.Seek(0, 0)
+
+	raw, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// image is everything between binary and footprint
+	image := raw[len("binary") : len(raw)-20]
+
+	if string(image[:4]) != "hsqs" || len(image)%squashfsPadding != 0 {
+		t.Fatal("embedfs is not written as padded SquashFS image")
+	}
+
+	if len(image) > len(expected)/2 {
+		t.Fatalf("image is not compressed: %d bytes", len(image))
+	}
+
+	_, err = CreateWithOptions(mockfile.New("lala106"), EmbedOptions{
+		Squashfs:   true,
+		VolumeSize: 1024,
+	})
+	if !errors.Is(err, ErrNotImplemented) {
+		t.Fatalf("sidecar volumes are accepted: %v", err)
+	}
+}
+
+func TestCanApplyWhiteoutsAndHardlinksInSquashfsImage(t *testing.T) {
+	base := &bytes.Buffer{}
+	writeLayer(base, map[string]string{
+		"a/1": "1\n",
+		"b/2": "2\n",
+		"c/3": "3\n",
+	})
+
+	top := &bytes.Buffer{}
+	writeLayer(top, map[string]string{
+		"a/.wh.1":        "",
+		"b/.wh..wh..opq": "",
+		"b/new":          "new\n",
+	})
+
+	container := mockfile.New("lala107")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{Squashfs: true})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, layer := range []*bytes.Buffer{base, top} {
+		err = embedder.EmbedOCILayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = embedder.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     "/c/link",
+		Linkname: "/c/3",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/a/1", "/b/2"} {
+		_, err = fs.Open(name)
+		if !errors.Is(err, ErrNoExist) {
+			t.Fatalf("file <%s> is not removed by whiteout: %v", name, err)
+		}
+	}
+
+	if string(fs.MustReadFile("/b/new")) != "new\n" {
+		t.Fatal("file </b/new> is not read from SquashFS image")
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "embedfs-extract")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	err = fs.ExtractAll(dir, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := os.Stat(filepath.Join(dir, "c", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linked, err := os.Stat(filepath.Join(dir, "c", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(original, linked) {
+		t.Fatal("file </c/link> is not extracted as hardlink")
+	}
+}
+
+func TestCanCheckDamagedSquashfsImage(t *testing.T) {
+	sample := writeSquashfsSample()
+	defer os.Remove(sample)
+
+	container := mockfile.New("lala108")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{Squashfs: true})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(sample, "/sample")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	damaged, err := fs.lookup("/sample")
+	if err != nil {
+		panic(err)
+	}
+
+	stored, _ := damaged.storedRange()
+
+	_, err = container.Seek(stored.Offset+stored.Length/2, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!', '!', '!', '!'})
+	if err != nil {
+		panic(err)
+	}
+
+	report, err := Check(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Damaged) != 1 || report.Damaged[0].Name != "/sample" ||
+		report.Damaged[0].Range != stored {
+		t.Fatalf("unexpected damaged files: %v", report.Damaged)
+	}
+
+	for _, name := range []string{"/a/1", "/b/2"} {
+		found := false
+		for _, intact := range report.Intact {
+			found = found || intact == name
+		}
+
+		if !found {
+			t.Fatalf("file <%s> is not intact", name)
+		}
+	}
+}
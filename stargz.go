@@ -0,0 +1,549 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// stargzTOCName is the name of tar entry, which holds table of contents
+	// of eStargz blob.
+	stargzTOCName = "stargz.index.json"
+
+	// stargzFooterSize is the size of empty gzip member in the end of
+	// eStargz blob, which holds offset of table of contents.
+	stargzFooterSize = 51
+)
+
+// stargzTOC is the table of contents of eStargz blob, which is used by
+// container runtimes to fetch files lazily.
+type stargzTOC struct {
+	Version int            `json:"version"`
+	Entries []*stargzEntry `json:"entries"`
+}
+
+type stargzEntry struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Size        int64  `json:"size,omitempty"`
+	ModTime     string `json:"modtime,omitempty"`
+	LinkName    string `json:"linkName,omitempty"`
+	Mode        int64  `json:"mode,omitempty"`
+	UID         int    `json:"uid,omitempty"`
+	GID         int    `json:"gid,omitempty"`
+	Offset      int64  `json:"offset,omitempty"`
+	Digest      string `json:"digest,omitempty"`
+	ChunkDigest string `json:"chunkDigest,omitempty"`
+}
+
+// stargzWriter writes every tar entry into gzip member of its own and
+// collects table of contents.
+type stargzWriter struct {
+	counter *countingWriter
+	entries []*stargzEntry
+
+	// member is the current gzip member, which starts at offset start of
+	// the blob.
+	member *gzip.Writer
+	start  int64
+
+	// digest is the hash of contents of the last regular file, which still
+	// has remaining bytes to be written.
+	digest    hash.Hash
+	remaining int64
+
+	tocDigest string
+}
+
+// gzipMember is the source of data of entry stored in gzip member. Data is
+// decompressed sequentially, so reading backwards starts decompression of
+// the member over again.
+type gzipMember struct {
+	source io.ReaderAt
+	offset int64
+	size   int64
+
+	mutex    sync.Mutex
+	reader   io.Reader
+	position int64
+}
+
+func newStargzWriter(w io.Writer) *stargzWriter {
+	return &stargzWriter{counter: &countingWriter{writer: w}}
+}
+
+func (writer *stargzWriter) Write(p []byte) (int, error) {
+	// tar writer writes empty padding, which should not start gzip member
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if writer.member == nil {
+		writer.begin()
+	}
+
+	if writer.digest != nil && writer.remaining > 0 {
+		hashed := int64(len(p))
+		if hashed > writer.remaining {
+			hashed = writer.remaining
+		}
+
+		writer.digest.Write(p[:hashed])
+		writer.remaining -= hashed
+	}
+
+	return writer.member.Write(p)
+}
+
+// next finishes gzip member of the previous entry and starts new one for
+// the entry which header will be written by tarWriter.
+func (writer *stargzWriter) next(tarWriter *tar.Writer) error {
+	// padding of the previous entry belongs to its member
+	err := tarWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = writer.finish()
+	if err != nil {
+		return err
+	}
+
+	writer.begin()
+
+	return nil
+}
+
+// begin starts new gzip member.
+func (writer *stargzWriter) begin() {
+	writer.member = gzip.NewWriter(writer.counter)
+	writer.start = writer.counter.written
+}
+
+// finish closes current gzip member, if any.
+func (writer *stargzWriter) finish() error {
+	if writer.digest != nil {
+		entry := writer.entries[len(writer.entries)-1]
+		entry.Digest = "sha256:" + hex.EncodeToString(writer.digest.Sum(nil))
+		entry.ChunkDigest = entry.Digest
+
+		writer.digest = nil
+	}
+
+	if writer.member == nil {
+		return nil
+	}
+
+	err := writer.member.Close()
+	writer.member = nil
+
+	return err
+}
+
+// record adds entry described by header, which has been written into the
+// current gzip member, to table of contents.
+func (writer *stargzWriter) record(header *tar.Header) {
+	types := map[byte]string{
+		tar.TypeReg:     "reg",
+		tar.TypeDir:     "dir",
+		tar.TypeSymlink: "symlink",
+		tar.TypeLink:    "hardlink",
+		tar.TypeChar:    "char",
+		tar.TypeBlock:   "block",
+		tar.TypeFifo:    "fifo",
+	}
+
+	kind, ok := types[header.Typeflag]
+	if !ok {
+		return
+	}
+
+	entry := &stargzEntry{
+		Name:     strings.TrimPrefix(header.Name, "/"),
+		Type:     kind,
+		LinkName: strings.TrimPrefix(header.Linkname, "/"),
+		Mode:     header.Mode,
+		UID:      header.Uid,
+		GID:      header.Gid,
+		Offset:   writer.start,
+	}
+
+	if !header.ModTime.IsZero() {
+		entry.ModTime = header.ModTime.UTC().Format(time.RFC3339)
+	}
+
+	if header.Typeflag == tar.TypeReg {
+		entry.Size = header.Size
+
+		writer.digest = sha256.New()
+		writer.remaining = header.Size
+	}
+
+	writer.entries = append(writer.entries, entry)
+}
+
+// close writes table of contents and footer, finishing tar stream.
+func (writer *stargzWriter) close(tarWriter *tar.Writer) error {
+	err := writer.next(tarWriter)
+	if err != nil {
+		return err
+	}
+
+	tocOffset := writer.start
+
+	toc, err := json.Marshal(stargzTOC{Version: 1, Entries: writer.entries})
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     stargzTOCName,
+		Mode:     0644,
+		Size:     int64(len(toc)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tarWriter.Write(toc)
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	err = writer.finish()
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(toc)
+	writer.tocDigest = "sha256:" + hex.EncodeToString(hash[:])
+
+	_, err = writer.counter.Write(stargzFooter(tocOffset))
+
+	return err
+}
+
+// stargzFooter returns empty gzip member, which holds offset of table of
+// contents in its extra field. Member is built by hand, because size of
+// empty deflate stream written by compress/flate is not fixed.
+func stargzFooter(tocOffset int64) []byte {
+	subfield := fmt.Sprintf("%016xSTARGZ", tocOffset)
+
+	footer := &bytes.Buffer{}
+
+	// magic, deflate method, FEXTRA flag, zero mtime, unknown OS
+	footer.Write([]byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff})
+
+	binary.Write(footer, binary.LittleEndian, uint16(4+len(subfield)))
+	footer.WriteString("SG")
+	binary.Write(footer, binary.LittleEndian, uint16(len(subfield)))
+	footer.WriteString(subfield)
+
+	// empty stored block, zero CRC-32 and size
+	footer.Write([]byte{1, 0, 0, 0xff, 0xff})
+	footer.Write(make([]byte, 8))
+
+	return footer.Bytes()
+}
+
+// TOCDigest returns digest of table of contents of eStargz blob written
+// with Stargz option, which should be set as
+// containerd.io/snapshot/stargz/toc.digest annotation of the layer. It's
+// empty until Embedder is closed.
+func (e *Embedder) TOCDigest() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.stargz == nil {
+		return ""
+	}
+
+	return e.stargz.tocDigest
+}
+
+// isGzipped returns true if data at specified offset of origin starts with
+// gzip magic, which means that embedfs is written with Stargz option.
+func isGzipped(origin io.ReaderAt, offset int64) bool {
+	magic := make([]byte, 2)
+
+	_, err := origin.ReadAt(magic, offset)
+	if err != nil {
+		return false
+	}
+
+	return magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// tarStream returns tar stream of embedfs located in specified range of
+// origin, decompressing it if needed. Stream of SquashFS image holds only
+// its global header.
+func tarStream(origin io.ReaderAt, offset, end int64) (io.Reader, error) {
+	if isSquashfs(origin, offset) {
+		return squashfsGlobalStream(origin, offset, end)
+	}
+
+	section := io.NewSectionReader(origin, offset, end-offset)
+
+	if !isGzipped(origin, offset) {
+		return section, nil
+	}
+
+	return gzip.NewReader(section)
+}
+
+// scanStargz reads index of embedfs written with Stargz option. Offsets of
+// gzip members are taken from table of contents, which is found by footer,
+// so only tar headers of entries are decompressed.
+func (fs *EmbedFs) scanStargz(ctx context.Context) error {
+	tocOffset, err := fs.readStargzFooter()
+	if err != nil {
+		return err
+	}
+
+	toc, err := fs.readStargzTOC(tocOffset)
+	if err != nil {
+		return err
+	}
+
+	entries := []*stargzEntry{}
+	for _, entry := range toc.Entries {
+		// chunks of large files are written by other tools only
+		if entry.Type == "chunk" {
+			continue
+		}
+
+		if entry.Offset < 0 || entry.Offset >= tocOffset ||
+			len(entries) > 0 && entry.Offset <= entries[len(entries)-1].Offset {
+			return fmt.Errorf(
+				`%w: invalid offset of <%s> in eStargz TOC`,
+				ErrCorrupted, entry.Name,
+			)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	for i, entry := range entries {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		// member lasts until the next one, which is either member of the
+		// next entry or table of contents; members of global headers in
+		// between are ignored
+		end := tocOffset
+		if i+1 < len(entries) {
+			end = entries[i+1].Offset
+		}
+
+		member := &gzipMember{
+			source: fs.origin,
+			offset: fs.offset + entry.Offset,
+			size:   end - entry.Offset,
+		}
+
+		tarHeader, err := member.header()
+		if err != nil {
+			return &EntryError{
+				Name:   entry.Name,
+				Offset: member.offset,
+				Err:    err,
+			}
+		}
+
+		if strings.TrimPrefix(tarHeader.Name, "/") != entry.Name {
+			return &EntryError{
+				Name:   entry.Name,
+				Offset: member.offset,
+				Err: fmt.Errorf(
+					`%w: entry <%s> is listed in eStargz TOC instead`,
+					ErrCorrupted, tarHeader.Name,
+				),
+			}
+		}
+
+		err = fs.addEntry(&embedFsEntry{
+			name:         tarHeader.Name,
+			size:         tarHeader.Size,
+			headerOffset: member.offset,
+			gzipped:      member,
+		}, tarHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readStargzFooter returns offset of table of contents relative to the
+// beginning of eStargz blob, which is stored in its footer.
+func (fs *EmbedFs) readStargzFooter() (int64, error) {
+	if fs.end-fs.offset < stargzFooterSize {
+		return 0, fmt.Errorf(`%w: no eStargz footer`, ErrCorrupted)
+	}
+
+	footer, err := gzip.NewReader(io.NewSectionReader(
+		fs.origin, fs.end-stargzFooterSize, stargzFooterSize,
+	))
+	if err != nil {
+		return 0, fmt.Errorf(`%w: invalid eStargz footer: %s`, ErrCorrupted, err)
+	}
+
+	// extra field holds single subfield "SG" with TOC offset in hex
+	extra := footer.Header.Extra
+	if len(extra) != 26 || string(extra[:2]) != "SG" ||
+		string(extra[20:]) != "STARGZ" {
+		return 0, fmt.Errorf(`%w: invalid eStargz footer`, ErrCorrupted)
+	}
+
+	tocOffset, err := strconv.ParseInt(string(extra[4:20]), 16, 64)
+	if err != nil || tocOffset < 0 ||
+		tocOffset > fs.end-fs.offset-stargzFooterSize {
+		return 0, fmt.Errorf(`%w: invalid eStargz TOC offset`, ErrCorrupted)
+	}
+
+	return tocOffset, nil
+}
+
+// readStargzTOC reads table of contents stored at specified offset of
+// eStargz blob.
+func (fs *EmbedFs) readStargzTOC(tocOffset int64) (*stargzTOC, error) {
+	decompressor, err := gzip.NewReader(io.NewSectionReader(
+		fs.origin, fs.offset+tocOffset,
+		fs.end-stargzFooterSize-fs.offset-tocOffset,
+	))
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't read eStargz TOC: %s`,
+			ErrCorrupted, err)
+	}
+
+	decompressor.Multistream(false)
+
+	tarReader := tar.NewReader(decompressor)
+
+	tarHeader, err := tarReader.Next()
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't read eStargz TOC: %s`,
+			ErrCorrupted, err)
+	}
+
+	if tarHeader.Name != stargzTOCName {
+		return nil, fmt.Errorf(
+			`%w: entry <%s> is found instead of eStargz TOC`,
+			ErrCorrupted, tarHeader.Name,
+		)
+	}
+
+	toc := &stargzTOC{}
+
+	err = json.NewDecoder(tarReader).Decode(toc)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: can't read eStargz TOC: %s`,
+			ErrCorrupted, err)
+	}
+
+	return toc, nil
+}
+
+// header decompresses tar header of entry stored in the member, leaving
+// its contents compressed.
+func (member *gzipMember) header() (*tar.Header, error) {
+	decompressor, err := gzip.NewReader(
+		io.NewSectionReader(member.source, member.offset, member.size),
+	)
+	if err != nil {
+		return nil, fmt.Errorf(`%w: %s`, ErrCorrupted, err)
+	}
+
+	decompressor.Multistream(false)
+
+	tarHeader, err := tar.NewReader(decompressor).Next()
+	if err != nil {
+		return nil, fmt.Errorf(`%w: %s`, ErrCorrupted, err)
+	}
+
+	return tarHeader, nil
+}
+
+// ReadAt reads decompressed data of entry starting from specified offset.
+func (member *gzipMember) ReadAt(p []byte, off int64) (int, error) {
+	member.mutex.Lock()
+	defer member.mutex.Unlock()
+
+	if member.reader == nil || off < member.position {
+		err := member.rewind()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err := io.CopyN(ioutil.Discard, member.reader, off-member.position)
+	if err != nil {
+		member.reader = nil
+		return 0, err
+	}
+
+	member.position = off
+
+	read, err := io.ReadFull(member.reader, p)
+	member.position += int64(read)
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	if err != nil && err != io.EOF {
+		member.reader = nil
+
+		return read, fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted, err)
+	}
+
+	return read, err
+}
+
+// rewind starts decompression of the member from the beginning of entry
+// contents.
+func (member *gzipMember) rewind() error {
+	decompressor, err := gzip.NewReader(
+		io.NewSectionReader(member.source, member.offset, member.size),
+	)
+	if err != nil {
+		return fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted, err)
+	}
+
+	decompressor.Multistream(false)
+
+	tarReader := tar.NewReader(decompressor)
+
+	_, err = tarReader.Next()
+	if err != nil {
+		return fmt.Errorf(`%w: can't decompress: %s`, ErrCorrupted, err)
+	}
+
+	member.reader = tarReader
+	member.position = 0
+
+	return nil
+}
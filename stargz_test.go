@@ -0,0 +1,236 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanWriteStargzLayout(t *testing.T) {
+	container := mockfile.New("lala84")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Stargz:  true,
+		Version: "1.0",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	embedder.SetAttr("channel", "beta")
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from gzip member")
+	}
+
+	if fs.Version() != "1.0" || fs.Attr("channel") != "beta" {
+		t.Fatal("global headers are not read from gzip members")
+	}
+
+	reader, err := fs.Open("/b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1)
+	for _, offset := range []int64{1, 0} {
+		_, err = reader.ReadAt(data, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data[0] != "2\n"[offset] {
+			t.Fatalf("wrong byte read at offset %d", offset)
+		}
+	}
+
+	container.Seek(0, 0)
+
+	raw, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// layer is everything between binary and footprint
+	layer := raw[len("binary") : len(raw)-20]
+
+	footer, err := gzip.NewReader(
+		bytes.NewReader(layer[len(layer)-stargzFooterSize:]),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extra := footer.Header.Extra
+	if len(extra) != 26 || string(extra[:2]) != "SG" ||
+		string(extra[20:]) != "STARGZ" {
+		t.Fatalf("invalid eStargz footer: %q", extra)
+	}
+
+	tocOffset, err := strconv.ParseInt(string(extra[4:20]), 16, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	member, err := gzip.NewReader(bytes.NewReader(layer[tocOffset:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarReader := tar.NewReader(member)
+
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Name != stargzTOCName {
+		t.Fatalf("unexpected entry <%s> instead of TOC", header.Name)
+	}
+
+	toc := stargzTOC{}
+
+	err = json.NewDecoder(tarReader).Decode(&toc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := sha256.Sum256([]byte("1\n"))
+	digest := "sha256:" + hex.EncodeToString(hash[:])
+
+	found := false
+	for _, entry := range toc.Entries {
+		if entry.Name == "a/1" {
+			found = entry.Type == "reg" && entry.Digest == digest
+		}
+	}
+
+	if !found {
+		t.Fatal("file <a/1> is not listed in TOC")
+	}
+
+	if embedder.TOCDigest() == "" {
+		t.Fatal("TOC digest is not returned")
+	}
+}
+
+func TestCanReadStargzIndexFromTOC(t *testing.T) {
+	big, err := ioutil.TempFile("", "embedfs-stargz")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(big.Name())
+
+	_, err = big.Write(bytes.Repeat([]byte("stargz "), 100000))
+	if err != nil {
+		panic(err)
+	}
+
+	big.Close()
+
+	container := mockfile.New("lala103")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{Stargz: true})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(big.Name(), "/big")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	damaged, err := fs.lookup("/big")
+	if err != nil {
+		panic(err)
+	}
+
+	// contents of members are not decompressed to find entries
+	middle := damaged.gzipped.offset + damaged.gzipped.size/2
+
+	_, err = container.Seek(middle, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!', '!', '!', '!'})
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err = OpenWithOptions(container, OpenOptions{Mode: ModeStrict})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("file </b/2> is not read after damaged member")
+	}
+
+	_, err = fs.ReadFile("/big")
+	if err == nil {
+		t.Fatal("damaged file </big> is read")
+	}
+
+	// index can't be read without TOC offset in footer
+	_, err = container.Seek(fs.end-stargzFooterSize+12, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!'})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected corrupted error, got %v", err)
+	}
+}
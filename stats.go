@@ -0,0 +1,79 @@
+package embedfs
+
+import (
+	"path"
+	"strings"
+)
+
+// Stats describes usage of space by embedfs.
+type Stats struct {
+	// Entries is the number of visible files.
+	Entries int
+
+	// TotalSize is the sum of sizes of all visible files.
+	TotalSize int64
+
+	// DiskSize is the size of whole embedfs in the container, including
+	// headers, padding and footprint.
+	DiskSize int64
+
+	// CompressionRatio is DiskSize divided by TotalSize.
+	CompressionRatio float64
+
+	// Directories contains usage per top-level directory. Files in the
+	// root directory are accounted under "/".
+	Directories map[string]DirectoryStats
+}
+
+// DirectoryStats describes usage of space by single directory.
+type DirectoryStats struct {
+	Entries   int
+	TotalSize int64
+}
+
+// Stats returns statistics of space usage by embedfs.
+func (fs *EmbedFs) Stats() (Stats, error) {
+	names, err := fs.listAll("/")
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		DiskSize:    fs.Size(),
+		Directories: map[string]DirectoryStats{},
+	}
+
+	for _, name := range uniqueNames(names) {
+		entry, err := fs.lookup(name)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats.Entries++
+		stats.TotalSize += entry.size
+
+		directory := topLevelDirectory(name)
+		directoryStats := stats.Directories[directory]
+		directoryStats.Entries++
+		directoryStats.TotalSize += entry.size
+		stats.Directories[directory] = directoryStats
+	}
+
+	if stats.TotalSize > 0 {
+		stats.CompressionRatio = float64(stats.DiskSize) /
+			float64(stats.TotalSize)
+	}
+
+	return stats, nil
+}
+
+func topLevelDirectory(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) < 2 {
+		return "/"
+	}
+
+	return "/" + parts[0]
+}
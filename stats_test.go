@@ -0,0 +1,52 @@
+package embedfs
+
+import (
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanGetStats(t *testing.T) {
+	container := mockfile.New("lala17")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	stats, err := fs.Stats()
+	if err != nil {
+		panic(err)
+	}
+
+	if stats.Entries != 2 || stats.TotalSize != 4 {
+		t.Fatalf(
+			"unexpected stats: %d entries, %d bytes",
+			stats.Entries, stats.TotalSize,
+		)
+	}
+
+	stat, _ := container.Stat()
+	if stats.DiskSize != stat.Size() {
+		t.Fatalf("unexpected disk size: %d", stats.DiskSize)
+	}
+
+	if stats.Directories["/a"].TotalSize != 2 {
+		t.Fatalf("unexpected stats of </a>: %+v", stats.Directories["/a"])
+	}
+}
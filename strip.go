@@ -0,0 +1,120 @@
+package embedfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CopyWithout writes contents of origin without embedfs data to dst,
+// leaving origin intact, so clean binary can be obtained from pipes,
+// network streams or read-only media, where Truncate can't be used.
+//
+// Footprint is located in the end of origin, so origin which can't be
+// read at arbitrary offsets is spooled to temporary file first.
+func CopyWithout(origin io.Reader, dst io.Writer) error {
+	source, ok := origin.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		spool, err := ioutil.TempFile("", "embedfs-spool")
+		if err != nil {
+			return err
+		}
+
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		_, err = copyBuffered(spool, origin)
+		if err != nil {
+			return err
+		}
+
+		source = spool
+	}
+
+	size, err := source.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	footprint, _, err := findFootprint(source, size, 0, signature)
+	if err != nil {
+		return err
+	}
+
+	_, err = copyBuffered(dst, io.NewSectionReader(source, 0, footprint.Offset))
+
+	return err
+}
+
+// StripOptions holds settings which are used by StripTo.
+type StripOptions struct {
+	// Mode of the clean binary. Zero value means mode of the source.
+	Mode os.FileMode
+}
+
+// StripTo writes clean binary without embedfs data from file src to file
+// dst. Mode of src is preserved regardless of umask of the process, and
+// dst is synced to disk before returning.
+//
+// Clean binary is written next to dst and renamed over it only when it's
+// complete, so dst is never left half-written, and src can be stripped in
+// place.
+func StripTo(src, dst string, options StripOptions) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	mode := options.Mode
+	if mode == 0 {
+		stat, err := source.Stat()
+		if err != nil {
+			return err
+		}
+
+		mode = stat.Mode()
+	}
+
+	target, err := ioutil.TempFile(
+		filepath.Dir(dst), "."+filepath.Base(dst)+".strip",
+	)
+	if err != nil {
+		return err
+	}
+
+	err = CopyWithout(source, target)
+	if err == nil {
+		err = target.Chmod(mode.Perm())
+	}
+
+	if err == nil {
+		err = target.Sync()
+	}
+
+	if err != nil {
+		target.Close()
+		os.Remove(target.Name())
+
+		return err
+	}
+
+	err = target.Close()
+	if err != nil {
+		os.Remove(target.Name())
+
+		return err
+	}
+
+	err = os.Rename(target.Name(), dst)
+	if err != nil {
+		os.Remove(target.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,169 @@
+package embedfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanCopyWithoutEmbedfs(t *testing.T) {
+	container := mockfile.New("lala55")
+
+	_, err := container.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container.Seek(0, 0)
+
+	data, err := ioutil.ReadAll(container)
+	if err != nil {
+		panic(err)
+	}
+
+	// multi reader can't be read at arbitrary offsets, so it's spooled
+	for _, origin := range []io.Reader{
+		bytes.NewReader(data),
+		io.MultiReader(bytes.NewReader(data)),
+	} {
+		clean := &bytes.Buffer{}
+
+		err = CopyWithout(origin, clean)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if clean.String() != "binary" {
+			t.Fatalf("unexpected clean binary: %q", clean.String())
+		}
+	}
+
+	err = CopyWithout(bytes.NewReader([]byte("binary")), &bytes.Buffer{})
+	if err != ErrNoFootprint {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanStripToFile(t *testing.T) {
+	source, err := ioutil.TempFile("", "embedfs-strip")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(source.Name())
+	defer source.Close()
+
+	_, err = source.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := Create(source)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	err = source.Chmod(0751)
+	if err != nil {
+		panic(err)
+	}
+
+	target := source.Name() + ".clean"
+	defer os.Remove(target)
+
+	err = StripTo(source.Name(), target, StripOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clean, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(clean) != "binary" {
+		t.Fatalf("unexpected clean binary: %q", clean)
+	}
+
+	stat, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode().Perm() != 0751 {
+		t.Fatalf("unexpected mode: %s", stat.Mode())
+	}
+}
+
+func TestCanStripFileInPlace(t *testing.T) {
+	source, err := ioutil.TempFile("", "embedfs-strip")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(source.Name())
+	defer source.Close()
+
+	_, err = source.Write([]byte("binary"))
+	if err != nil {
+		panic(err)
+	}
+
+	embedder, err := Create(source)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	err = StripTo(source.Name(), source.Name(), StripOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clean, err := ioutil.ReadFile(source.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(clean) != "binary" {
+		t.Fatalf("unexpected clean binary: %q", clean)
+	}
+}
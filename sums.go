@@ -0,0 +1,80 @@
+package embedfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"strings"
+)
+
+// WriteSHA256Sums writes checksums of all files embedded so far in the
+// format of sha256sum utility. Paths are written relative to the root of
+// embedded fs, so written file can be checked by `sha256sum -c` in the
+// directory where embedded fs is extracted.
+func (e *Embedder) WriteSHA256Sums(w io.Writer) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, checksum := range e.sums {
+		_, err := fmt.Fprintf(
+			w, "%s  %s\n",
+			checksum.hash, strings.TrimPrefix(checksum.name, "/"),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifySHA256Sums reads checksums in the format of sha256sum utility from
+// specified reader and checks that embedded files have the same checksums.
+//
+// Unlike Verify, it doesn't trust checksums stored in embedded fs and
+// calculates them from the files contents.
+func (fs *EmbedFs) VerifySHA256Sums(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 || len(fields[1]) < 2 {
+			return fmt.Errorf(`invalid checksum line %d`, line)
+		}
+
+		// second field starts with space for text mode and with asterisk
+		// for binary mode, and name is relative, like "a" or "./a"
+		expected, name := fields[0], fields[1][1:]
+
+		name, err := cleanPath(name)
+		if err != nil {
+			return &iofs.PathError{Op: "verify", Path: name, Err: err}
+		}
+
+		entry, err := fs.lookup(name)
+		if err != nil {
+			return &iofs.PathError{Op: "verify", Path: name, Err: err}
+		}
+
+		actual, err := fs.checksum(entry)
+		if err != nil {
+			return err
+		}
+
+		if actual != expected {
+			fs.options.Metrics.VerificationFailed(name)
+
+			return fmt.Errorf(
+				`%w: <%s> has checksum %s, expected %s`,
+				ErrChecksumMismatch, name, actual, expected,
+			)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,65 @@
+package embedfs
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanWriteAndVerifySHA256Sums(t *testing.T) {
+	container := mockfile.New("lala19")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	sums := &bytes.Buffer{}
+
+	err = embedder.WriteSHA256Sums(sums)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	if !strings.HasSuffix(strings.Split(sums.String(), "\n")[0], "  a/1") {
+		t.Fatalf("unexpected checksums: %s", sums.String())
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.VerifySHA256Sums(bytes.NewReader(sums.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected verification error: %s", err)
+	}
+
+	// sha256sum run in extracted directory writes names like "./a/1"
+	dotted := strings.Replace(sums.String(), "  ", " *./", -1)
+
+	err = fs.VerifySHA256Sums(strings.NewReader(dotted))
+	if err != nil {
+		t.Fatalf("unexpected verification error: %s", err)
+	}
+
+	broken := strings.Replace(sums.String(), "a/1", "b/2", 1)
+
+	err = fs.VerifySHA256Sums(strings.NewReader(broken))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
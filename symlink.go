@@ -0,0 +1,72 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// embedSymlink embeds symlink found by EmbedDirectory in the specified root
+// as is, rewriting its target if RewriteSymlinks option is set.
+func (e *Embedder) embedSymlink(
+	root, path, target string, info os.FileInfo,
+) error {
+	e.options.emit(Event{Kind: EventStarted, Source: path, Target: target})
+
+	link, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	if e.options.RewriteSymlinks && filepath.IsAbs(link) {
+		link, err = rewriteSymlink(root, path, link)
+		if err != nil {
+			return err
+		}
+	}
+
+	tarHeader, err := tar.FileInfoHeader(info, filepath.ToSlash(link))
+	if err != nil {
+		return err
+	}
+
+	return e.embedContent(tarHeader, path, target, nil)
+}
+
+// rewriteSymlink returns target of symlink relative to the symlink itself,
+// if target is located inside of root. Other targets are returned as is.
+func rewriteSymlink(root, path, link string) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	inside, err := filepath.Rel(root, link)
+	if err != nil || inside == ".." ||
+		strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return link, nil
+	}
+
+	return filepath.Rel(filepath.Dir(path), link)
+}
+
+// extractSymlink creates symlink, replacing previously extracted file.
+func extractSymlink(link, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(filepath.FromSlash(link), target)
+}
@@ -0,0 +1,93 @@
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanPreserveAndRewriteSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-symlink")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+
+	err = os.MkdirAll(filepath.Join(source, "links"), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(source, "data"), []byte("1\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	err = os.Symlink(
+		filepath.Join(source, "data"), filepath.Join(source, "links", "data"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala45")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		PreserveSymlinks: true,
+		RewriteSymlinks:  true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory(source, "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	entry, err := fs.lookup("/links/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := fs.header(entry)
+	if err != nil {
+		panic(err)
+	}
+
+	if header.Linkname != "../data" {
+		t.Fatalf("unexpected target of symlink: %s", header.Linkname)
+	}
+
+	target := filepath.Join(dir, "target")
+
+	err = fs.ExtractAll(target, ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(target, "links", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "1\n" {
+		t.Fatalf("unexpected contents of file read via symlink: %q", data)
+	}
+}
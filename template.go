@@ -0,0 +1,57 @@
+package embedfs
+
+import (
+	"html/template"
+	"path"
+	"strings"
+)
+
+// TemplateFuncs returns functions for html/template, which allow templates
+// to reference files from embedded fs:
+//
+//	assetURL "/static/app.js" - URL of the file, prefixed by baseURL and
+//	    resolved to fingerprinted name, if file was fingerprinted;
+//	inline "/static/app.css" - contents of the file, typed as template.CSS,
+//	    template.JS or template.HTML depending on its content type.
+//
+// Embedded files are trusted, so inlined contents are not escaped.
+func (fs *EmbedFs) TemplateFuncs(baseURL string) template.FuncMap {
+	return template.FuncMap{
+		"assetURL": func(name string) (string, error) {
+			fingerprinted, err := fs.Fingerprinted(name)
+			if err != nil {
+				return "", err
+			}
+
+			return strings.TrimSuffix(baseURL, "/") + fingerprinted, nil
+		},
+
+		"inline": func(name string) (interface{}, error) {
+			name = path.Clean("/" + name)
+
+			entry, err := fs.lookup(name)
+			if err != nil {
+				return nil, err
+			}
+
+			contentType, err := Entry{fs: fs, entry: entry}.ContentType()
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := fs.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case strings.HasPrefix(contentType, "text/css"):
+				return template.CSS(data), nil
+			case strings.Contains(contentType, "javascript"):
+				return template.JS(data), nil
+			default:
+				return template.HTML(data), nil
+			}
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package embedfs
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanUseTemplateFuncs(t *testing.T) {
+	container := mockfile.New("lala25")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Fingerprint: []string{"*.js"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile("_test/a/1", "/static/app.js")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	tpl := template.Must(
+		template.New("page").Funcs(fs.TemplateFuncs("/assets/")).Parse(
+			`<script src="{{ assetURL "/static/app.js" }}"></script>`,
+		),
+	)
+
+	actual := &bytes.Buffer{}
+
+	err = tpl.Execute(actual, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	expected := `<script src="/assets/static/app.4355a46b.js"></script>`
+	if actual.String() != expected {
+		t.Fatalf("unexpected template output: %s", actual.String())
+	}
+}
@@ -0,0 +1,63 @@
+package embedfs
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter limits bandwidth of one or several readers sharing it.
+type rateLimiter struct {
+	mutex sync.Mutex
+	rate  int64
+	next  time.Time
+}
+
+type throttledReader struct {
+	reader  io.Reader
+	limiter *rateLimiter
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{rate: bytesPerSecond}
+}
+
+// wait blocks until it's allowed to transfer specified amount of bytes.
+func (limiter *rateLimiter) wait(size int) {
+	limiter.mutex.Lock()
+
+	now := time.Now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+
+	delay := limiter.next.Sub(now)
+
+	limiter.next = limiter.next.Add(
+		time.Duration(int64(size) * int64(time.Second) / limiter.rate),
+	)
+
+	limiter.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+func (reader *throttledReader) Read(b []byte) (int, error) {
+	n, err := reader.reader.Read(b)
+
+	reader.limiter.wait(n)
+
+	return n, err
+}
+
+func throttle(reader io.Reader, limiter *rateLimiter) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+
+	return &throttledReader{reader: reader, limiter: limiter}
+}
@@ -0,0 +1,33 @@
+package embedfs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCanThrottleReads(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, 3000)
+
+	reader := throttle(bytes.NewReader(data), newRateLimiter(10000))
+
+	started := time.Now()
+
+	buffer := make([]byte, 1000)
+	for {
+		_, err := reader.Read(buffer)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// first chunk is not delayed, every next is delayed for 100ms
+	if time.Since(started) < 200*time.Millisecond {
+		t.Fatalf("reads are not throttled: %s", time.Since(started))
+	}
+}
@@ -0,0 +1,62 @@
+package embedfs
+
+import (
+	"context"
+)
+
+// Tracer starts tracing spans for long embedfs operations, like Open,
+// Extract and VerifyAll. It's meant to be a thin adapter to the tracing
+// system used by application, e.g. OpenTelemetry.
+//
+// Tracer is activated by passing context made by ContextWithTracer into
+// OpenContext, ExtractContext or VerifyAllContext.
+type Tracer interface {
+	Start(
+		ctx context.Context, name string, attributes map[string]interface{},
+	) (context.Context, Span)
+}
+
+// Span represents single traced operation.
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+type tracerKey struct{}
+
+type noTracer struct{}
+
+type noSpan struct{}
+
+// ContextWithTracer returns copy of context which carries specified tracer.
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+func startSpan(
+	ctx context.Context, name string, attributes map[string]interface{},
+) (context.Context, Span) {
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		tracer = noTracer{}
+	}
+
+	return tracer.Start(ctx, name, attributes)
+}
+
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	span.End()
+}
+
+func (noTracer) Start(
+	ctx context.Context, name string, attributes map[string]interface{},
+) (context.Context, Span) {
+	return ctx, noSpan{}
+}
+
+func (noSpan) RecordError(error) {}
+func (noSpan) End()              {}
@@ -0,0 +1,63 @@
+package embedfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+type testTracer struct {
+	spans []string
+}
+
+type testSpan struct{}
+
+func (tracer *testTracer) Start(
+	ctx context.Context, name string, attributes map[string]interface{},
+) (context.Context, Span) {
+	tracer.spans = append(tracer.spans, name)
+
+	return ctx, testSpan{}
+}
+
+func (testSpan) RecordError(error) {}
+func (testSpan) End()              {}
+
+func TestCanTraceOperations(t *testing.T) {
+	container := mockfile.New("lala13")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := &testTracer{}
+	ctx := ContextWithTracer(context.Background(), tracer)
+
+	fs, err := OpenContext(ctx, container, OpenOptions{})
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.VerifyAllContext(ctx, VerifyOptions{})
+	if err != nil {
+		panic(err)
+	}
+
+	expected := []string{"embedfs.Open", "embedfs.VerifyAll"}
+	if !reflect.DeepEqual(tracer.spans, expected) {
+		t.Fatalf("unexpected spans: %v", tracer.spans)
+	}
+}
@@ -0,0 +1,273 @@
+package embedfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNoChecksum       = errors.New("no checksum stored for file")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// VerifyOptions holds settings which are used by VerifyAll.
+type VerifyOptions struct {
+	// Parallelism specifies how many files can be verified concurrently.
+	// Zero value means sequential verification.
+	Parallelism int
+
+	// Progress, if not nil, is called after every file is verified. Calls
+	// are never concurrent, even if Parallelism is specified.
+	Progress func(VerifyProgress)
+}
+
+// VerifyProgress describes verification of single file by VerifyAll.
+type VerifyProgress struct {
+	// Name of verified file and its size.
+	Name string
+	Size int64
+
+	// Err is the verification error, if file is damaged.
+	Err error
+
+	// Verified is the number of files verified so far, including this one,
+	// out of Total.
+	Verified int
+	Total    int
+}
+
+// VerifyError is returned by VerifyAll when some files have failed
+// verification. It matches errors of all failed files.
+type VerifyError struct {
+	// Failures are sorted by file name.
+	Failures []VerifyFailure
+}
+
+// VerifyFailure describes file which has failed verification.
+type VerifyFailure struct {
+	Name string
+	Err  error
+}
+
+func (err *VerifyError) Error() string {
+	return fmt.Sprintf(
+		"embedfs: %d files failed verification, first: %s",
+		len(err.Failures), err.Failures[0].Err,
+	)
+}
+
+func (err *VerifyError) Unwrap() []error {
+	errs := make([]error, len(err.Failures))
+	for i, failure := range err.Failures {
+		errs[i] = failure.Err
+	}
+
+	return errs
+}
+
+// Verify reads specified file from embedded fs and compares its SHA-256
+// checksum with the one stored at embedding time.
+//
+// ErrNoChecksum will be returned if file was embedded without checksum.
+func (fs *EmbedFs) Verify(path string) error {
+	name, err := cleanPath(path)
+	if err != nil {
+		return &iofs.PathError{Op: "verify", Path: path, Err: err}
+	}
+
+	entry, err := fs.lookup(name)
+	if err != nil {
+		return &iofs.PathError{Op: "verify", Path: path, Err: err}
+	}
+
+	return fs.verifyEntry(entry)
+}
+
+// VerifyAll verifies checksums of all files in embedded fs, which have
+// stored checksums. Files without checksums are skipped.
+//
+// All files are verified even if some of them are damaged, and failures are
+// returned as VerifyError.
+func (fs *EmbedFs) VerifyAll(options VerifyOptions) error {
+	return fs.VerifyAllContext(context.Background(), options)
+}
+
+// VerifyAllContext works like VerifyAll, but reports verification via tracer
+// carried by specified context. Verification is stopped with context error
+// as soon as context is done.
+func (fs *EmbedFs) VerifyAllContext(
+	ctx context.Context, options VerifyOptions,
+) error {
+	names, err := fs.listAll("/")
+	if err != nil {
+		return err
+	}
+
+	names = uniqueNames(names)
+
+	_, span := startSpan(ctx, "embedfs.VerifyAll", map[string]interface{}{
+		"embedfs.files":        len(names),
+		"embedfs.payload_size": fs.sizeOf(names),
+	})
+
+	var (
+		mutex    sync.Mutex
+		verified int
+		failures []VerifyFailure
+	)
+
+	err = forEach(options.Parallelism, names,
+		func(name string) error {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			err = fs.Verify(name)
+			if errors.Is(err, ErrNoChecksum) {
+				err = nil
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			verified++
+
+			if err != nil {
+				failures = append(failures, VerifyFailure{Name: name, Err: err})
+			}
+
+			if options.Progress != nil {
+				options.Progress(VerifyProgress{
+					Name:     name,
+					Size:     fs.sizeOf([]string{name}),
+					Err:      err,
+					Verified: verified,
+					Total:    len(names),
+				})
+			}
+
+			return nil
+		},
+	)
+
+	if err == nil && len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool {
+			return failures[i].Name < failures[j].Name
+		})
+
+		err = &VerifyError{Failures: failures}
+	}
+
+	endSpan(span, err)
+
+	return err
+}
+
+func (fs *EmbedFs) verifyEntry(entry *embedFsEntry) error {
+	header, err := fs.header(entry)
+	if err != nil {
+		return err
+	}
+
+	expected, ok := header.PAXRecords[paxChecksum]
+	if !ok {
+		return fmt.Errorf(`%w: <%s>`, ErrNoChecksum, entry.name)
+	}
+
+	actual, err := fs.checksum(entry)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		fs.options.Metrics.VerificationFailed(entry.name)
+
+		return fmt.Errorf(
+			`%w: <%s> has checksum %s, expected %s`,
+			ErrChecksumMismatch, entry.name, actual, expected,
+		)
+	}
+
+	return nil
+}
+
+// verifyStrict checks contents of the entry against its checksum when it's
+// opened first time in strict mode, so silently damaged file is not read.
+// Entries without checksum, like symlinks, are not checked.
+func (fs *EmbedFs) verifyStrict(entry *embedFsEntry) error {
+	if fs.options.Mode != ModeStrict || fs.options.MetadataOnly {
+		return nil
+	}
+
+	entry.verifyOnce.Do(func() {
+		err := fs.verifyEntry(entry)
+		if errors.Is(err, ErrNoChecksum) {
+			err = nil
+		}
+
+		entry.verifyErr = err
+	})
+
+	return entry.verifyErr
+}
+
+// checksum calculates SHA-256 checksum of the entry contents.
+func (fs *EmbedFs) checksum(entry *embedFsEntry) (string, error) {
+	hash := sha256.New()
+
+	_, err := copyBuffered(hash, fs.newReader(entry, entry.name))
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashChunk passes data read sequentially to the hash of the reader.
+func (reader *embedFileReader) hashChunk(data []byte) {
+	if reader.hash == nil || reader.hashed != reader.offset {
+		return
+	}
+
+	reader.hash.Write(data)
+	reader.hashed += int64(len(data))
+}
+
+// verify returns io.EOF if whole file was read and its checksum matches
+// stored one, or error otherwise. Files which were not read sequentially
+// are not verified.
+func (reader *embedFileReader) verify() error {
+	if reader.hash == nil || reader.hashed != reader.length {
+		return io.EOF
+	}
+
+	header, err := reader.fs.header(reader.entry)
+	if err != nil {
+		return err
+	}
+
+	expected, ok := header.PAXRecords[paxChecksum]
+	if !ok {
+		return fmt.Errorf(`%w: <%s>`, ErrNoChecksum, reader.name)
+	}
+
+	actual := hex.EncodeToString(reader.hash.Sum(nil))
+	if actual != expected {
+		reader.fs.options.Metrics.VerificationFailed(reader.name)
+
+		return fmt.Errorf(
+			`%w: <%s> has checksum %s, expected %s`,
+			ErrChecksumMismatch, reader.name, actual, expected,
+		)
+	}
+
+	return io.EOF
+}
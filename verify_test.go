@@ -0,0 +1,240 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanVerifyChecksums(t *testing.T) {
+	container := mockfile.New("lala11")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.VerifyAll(VerifyOptions{Parallelism: 2})
+	if err != nil {
+		t.Fatalf("unexpected verification error: %s", err)
+	}
+
+	entry, err := fs.lookup("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Seek(entry.offset, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!'})
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.VerifyAll(VerifyOptions{Parallelism: 2})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestCanVerifyOnFirstOpenInStrictMode(t *testing.T) {
+	container := mockfile.New("lala99")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		panic(err)
+	}
+
+	entry, err := fs.lookup("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Seek(entry.offset, os.SEEK_SET)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.Write([]byte{'!'})
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err = OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("intact file </a/1> is not read")
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = fs.Open("/b/2")
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("expected checksum mismatch, got %v", err)
+		}
+	}
+
+	// default mode doesn't read whole file on open
+	fs, err = Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fs.Open("/b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanVerifyOnRead(t *testing.T) {
+	container, err := ioutil.TempFile("", "embedfs-container")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(container.Name())
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenWithOptions(container, OpenOptions{VerifyOnRead: true})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.ReadFile("/a/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, _, err := fs.Extent("/b/2")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = container.WriteAt([]byte("X"), offset)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = fs.ReadFile("/b/2")
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestCanReportVerificationProgress(t *testing.T) {
+	container := mockfile.New("lala70")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range []string{"/a/1", "/b/2"} {
+		entry, err := fs.lookup(name)
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = container.Seek(entry.offset, os.SEEK_SET)
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = container.Write([]byte{'!'})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	progress := []VerifyProgress{}
+
+	err = fs.VerifyAll(VerifyOptions{
+		Parallelism: 2,
+		Progress: func(verified VerifyProgress) {
+			progress = append(progress, verified)
+		},
+	})
+
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) || len(verifyErr.Failures) != 2 ||
+		verifyErr.Failures[0].Name != "/a/1" {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	if len(progress) != 2 || progress[1].Verified != 2 ||
+		progress[1].Total != 2 || progress[1].Err == nil {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+}
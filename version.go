@@ -0,0 +1,40 @@
+package embedfs
+
+import (
+	"strings"
+)
+
+const (
+	paxVersion        = paxPrefix + "version"
+	paxMetadataPrefix = paxPrefix + "meta."
+)
+
+// Version returns version of embedfs payload specified by Version option of
+// CreateWithOptions, or empty string if there is none.
+func (fs *EmbedFs) Version() string {
+	records, err := readGlobalHeader(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return ""
+	}
+
+	return records[paxVersion]
+}
+
+// BuildMetadata returns metadata specified by BuildMetadata option of
+// CreateWithOptions.
+func (fs *EmbedFs) BuildMetadata() (map[string]string, error) {
+	records, err := readGlobalHeader(fs.origin, fs.offset, fs.end)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]string{}
+
+	for key, value := range records {
+		if strings.HasPrefix(key, paxMetadataPrefix) {
+			metadata[strings.TrimPrefix(key, paxMetadataPrefix)] = value
+		}
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,48 @@
+package embedfs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanStampVersion(t *testing.T) {
+	container := mockfile.New("lala53")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		Version:       "1.2.3",
+		BuildMetadata: map[string]string{"commit": "d640b06"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fs.Version() != "1.2.3" {
+		t.Fatalf("unexpected version: %q", fs.Version())
+	}
+
+	metadata, err := fs.BuildMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(metadata, map[string]string{"commit": "d640b06"}) {
+		t.Fatalf("unexpected build metadata: %v", metadata)
+	}
+}
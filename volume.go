@@ -0,0 +1,161 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// nextVolumePath is the internal file, which holds name of the next sidecar
+// volume relative to the directory of the volume it's stored in.
+const nextVolumePath = internalDir + "/next-volume"
+
+var ErrUnnamedOrigin = errors.New("sidecar volumes require named origin")
+
+type namedFile interface {
+	Name() string
+}
+
+// reserveVolume switches embedding to the next sidecar volume, if data of
+// specified size doesn't fit into the current one.
+func (e *Embedder) reserveVolume(size int64) error {
+	size = blockSize + alignBlock(size)
+
+	limit := e.options.VolumeSize
+	if limit > 0 && e.volumeSize > 0 && e.volumeSize+size > limit {
+		err := e.nextVolume()
+		if err != nil {
+			return err
+		}
+	}
+
+	e.volumeSize += size
+
+	return nil
+}
+
+func (e *Embedder) nextVolume() error {
+	if e.volumeBase == "" {
+		origin, ok := e.origin.(namedFile)
+		if !ok {
+			return ErrUnnamedOrigin
+		}
+
+		e.volumeBase = origin.Name()
+	}
+
+	name := fmt.Sprintf(
+		"%s.%d.efs", filepath.Base(e.volumeBase), len(e.sidecars)+1,
+	)
+
+	err := e.embedData(nextVolumePath, []byte(name))
+	if err != nil {
+		return err
+	}
+
+	err = e.closeVolume()
+	if err != nil {
+		return err
+	}
+
+	sidecar, err := os.Create(filepath.Join(filepath.Dir(e.volumeBase), name))
+	if err != nil {
+		return err
+	}
+
+	e.sidecars = append(e.sidecars, sidecar)
+	e.origin = sidecar
+	e.writer = tar.NewWriter(sidecar)
+	e.offset = 0
+	e.volumeSize = 0
+
+	return nil
+}
+
+// loadVolumes opens sidecar volumes referenced by origin one by one and
+// adds their entries to the index, so they are stitched into the single
+// namespace.
+func (fs *EmbedFs) loadVolumes(ctx context.Context) error {
+	volume := fs
+	visited := map[string]bool{}
+
+	for {
+		var next *embedFsEntry
+		for _, entry := range volume.files {
+			if entry.name == nextVolumePath {
+				next = entry
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		origin, ok := fs.origin.(namedFile)
+		if !ok {
+			return ErrUnnamedOrigin
+		}
+
+		name, err := ioutil.ReadAll(volume.newReader(next, next.name))
+		if err != nil {
+			return err
+		}
+
+		// volumes are written next to origin, so name can't point anywhere
+		// else
+		if filepath.Base(string(name)) != string(name) ||
+			string(name) == "." || string(name) == ".." {
+			return fmt.Errorf(`%w: invalid name of volume <%s>`,
+				ErrCorrupted, name)
+		}
+
+		path := filepath.Join(filepath.Dir(origin.Name()), string(name))
+		if visited[path] {
+			return fmt.Errorf(`%w: volume <%s> is referenced twice`,
+				ErrCorrupted, path)
+		}
+
+		visited[path] = true
+
+		sidecar, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		volume, err = newEmbedFs(sidecar, fs.options)
+		if err != nil {
+			sidecar.Close()
+			return fmt.Errorf(`can't open volume <%s>: %w`, path, err)
+		}
+
+		fs.volumes = append(fs.volumes, volume)
+
+		// limits apply to all volumes together
+		volume.entries = fs.entries
+		volume.totalSize = fs.totalSize
+
+		err = volume.scan(ctx)
+		if err != nil {
+			return fmt.Errorf(`can't open volume <%s>: %w`, path, err)
+		}
+
+		fs.entries = volume.entries
+		fs.totalSize = volume.totalSize
+
+		fs.errors = append(fs.errors, volume.errors...)
+
+		for _, entry := range volume.files {
+			entry.volume = volume
+
+			// dictionary may be stored in any volume
+			if entry.compressed != nil {
+				entry.compressed.fs = fs
+			}
+			fs.files = append(fs.files, entry)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package embedfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanSplitIntoVolumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-volumes")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	container, err := os.Create(filepath.Join(dir, "app"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer container.Close()
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		VolumeSize: 2 * blockSize,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "app.1.efs"))
+	if err != nil {
+		t.Fatalf("sidecar volume is not created: %s", err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("file </a/1> is not read from origin")
+	}
+
+	if string(fs.MustReadFile("/b/2")) != "2\n" {
+		t.Fatal("file </b/2> is not read from sidecar volume")
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanLimitAllVolumesTogether(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-volumes")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	container, err := os.Create(filepath.Join(dir, "app"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer container.Close()
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		VolumeSize: 2 * blockSize,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedDirectory("_test", "/")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.load()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxEntries: fs.entries - 1},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = OpenWithOptions(container, OpenOptions{
+		Limits: Limits{MaxTotalSize: fs.totalSize - 1},
+	})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanNotOpenVolumeOutsideOfOriginDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-volumes")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	container, err := os.Create(filepath.Join(dir, "app"))
+	if err != nil {
+		panic(err)
+	}
+
+	defer container.Close()
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.embedData(nextVolumePath, []byte("../app"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = Open(container)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
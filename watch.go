@@ -0,0 +1,117 @@
+package embedfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WatchOptions holds settings which are used by Watch.
+type WatchOptions struct {
+	// Interval between checks of watched directory. Zero value means half
+	// of a second.
+	Interval time.Duration
+
+	// Prefix is the directory inside embedfs, where watched directory is
+	// embedded. Zero value means root of embedfs.
+	Prefix string
+
+	// Embed holds settings which are used for every rebuild.
+	Embed EmbedOptions
+
+	// Rebuilt, if not nil, is called after every rebuild with its error,
+	// or with error of failed check of watched directory. Failed rebuild or
+	// check doesn't stop watching, so broken asset can be fixed without
+	// restarting Watch.
+	Rebuilt func(error)
+}
+
+// Watch embeds directory dir into the target binary and then rebuilds
+// embedfs of the target every time when files in dir are changed, until
+// context is canceled. It's intended to be used in development, so
+// templates and assets can be edited without running embedding by hand.
+//
+// Directory is polled, so changes are picked up within Interval. Every
+// rebuild is done by Reembed, so target is never left half-written.
+func Watch(
+	ctx context.Context, dir, target string, options WatchOptions,
+) error {
+	if options.Interval == 0 {
+		options.Interval = 500 * time.Millisecond
+	}
+
+	if options.Prefix == "" {
+		options.Prefix = "/"
+	}
+
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	previous := ""
+
+	for {
+		current, err := snapshotDir(dir)
+		switch {
+		case err != nil:
+			if options.Rebuilt != nil {
+				options.Rebuilt(err)
+			}
+
+		case current != previous:
+			err = ReembedWithOptions(
+				target, options.Embed,
+				func(embedder *Embedder) error {
+					return embedder.EmbedDirectory(dir, options.Prefix)
+				},
+			)
+
+			if options.Rebuilt != nil {
+				options.Rebuilt(err)
+			}
+
+			previous = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshotDir returns hash of names, sizes, modes and modification times of
+// all files in directory, which changes when any file is changed.
+func snapshotDir(dir string) (string, error) {
+	hash := sha256.New()
+
+	err := filepath.Walk(
+		dir,
+		func(path string, info os.FileInfo, err error) error {
+			// files vanish while they are saved atomically by editors
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(
+				hash, "%s\x00%d\x00%s\x00%d\x00",
+				path, info.Size(), info.Mode(), info.ModTime().UnixNano(),
+			)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,132 @@
+package embedfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCanWatchDirectoryAndRebuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-watch")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "assets")
+
+	err = os.Mkdir(source, 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(source, "index.html"), []byte("v1"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	target := filepath.Join(dir, "binary")
+
+	err = ioutil.WriteFile(target, []byte("binary"), 0755)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rebuilt := make(chan error)
+	stopped := make(chan error)
+
+	go func() {
+		stopped <- Watch(ctx, source, target, WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Rebuilt: func(err error) {
+				rebuilt <- err
+			},
+		})
+	}()
+
+	read := func() string {
+		origin, err := os.Open(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer origin.Close()
+
+		fs, err := Open(origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(fs.MustReadFile("/index.html"))
+	}
+
+	err = <-rebuilt
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read() != "v1" {
+		t.Fatal("directory is not embedded initially")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(source, "index.html"), []byte("v2"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	// modification time may be too coarse to notice rewrite
+	err = os.Chtimes(
+		filepath.Join(source, "index.html"),
+		time.Now(), time.Now().Add(time.Hour),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = <-rebuilt
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read() != "v2" {
+		t.Fatal("embedfs is not rebuilt after change")
+	}
+
+	cancel()
+
+	err = <-stopped
+	if err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCanKeepWatchingWhenFilesVanish(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-watch")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = Watch(
+		ctx, filepath.Join(dir, "missing"), filepath.Join(dir, "target"),
+		WatchOptions{
+			Interval: 10 * time.Millisecond,
+			Rebuilt: func(error) {
+				cancel()
+			},
+		},
+	)
+	if err != context.Canceled {
+		t.Fatalf("watching is stopped: %v", err)
+	}
+}
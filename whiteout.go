@@ -0,0 +1,256 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const paxWhiteout = paxPrefix + "whiteout"
+
+// Kinds of whiteouts, which are stored in PAX record of whiteout entry.
+const (
+	// whiteoutFile removes file or directory with the same name.
+	whiteoutFile = "file"
+
+	// whiteoutOpaque removes contents of directory with the same name.
+	whiteoutOpaque = "opaque"
+)
+
+// Names of whiteout files in OCI layers.
+const (
+	ociWhiteoutPrefix = ".wh."
+	ociWhiteoutOpaque = ".wh..wh..opq"
+)
+
+// EmbedOCILayer embeds contents of OCI image layer, which can be gzipped.
+//
+// Whiteouts of the layer remove files embedded before, so layers of image
+// can be embedded one by one to get the same tree as in container. Files of
+// the layer itself are never removed by its whiteouts. Device files and
+// named pipes are skipped.
+func (e *Embedder) EmbedOCILayer(r io.Reader) error {
+	buffered := bufio.NewReader(r)
+
+	magic, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var layer io.Reader = buffered
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressor, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+
+		defer decompressor.Close()
+
+		layer = decompressor
+	}
+
+	// layer is spooled, so its whiteouts are embedded before its files and
+	// remove only files of lower layers
+	spool, err := ioutil.TempFile("", "embedfs-layer")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	reader := tar.NewReader(io.TeeReader(layer, spool))
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name, kind := layerWhiteout(header)
+		if kind == "" {
+			continue
+		}
+
+		err = e.embedWhiteout(name, kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	size, err := spool.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	section := io.NewSectionReader(spool, 0, size)
+	reader = tar.NewReader(section)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		offset, _ := section.Seek(0, os.SEEK_CUR)
+
+		err = e.embedLayerEntry(
+			header, io.NewSectionReader(spool, offset, header.Size),
+		)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// layerWhiteout returns name of files removed by whiteout and its kind, if
+// header describes OCI whiteout file, or empty kind otherwise.
+func layerWhiteout(header *tar.Header) (string, string) {
+	name := path.Join("/", header.Name)
+	base := path.Base(name)
+
+	switch {
+	case base == ociWhiteoutOpaque:
+		return path.Dir(name), whiteoutOpaque
+
+	case strings.HasPrefix(base, ociWhiteoutPrefix):
+		return path.Join(path.Dir(name), base[len(ociWhiteoutPrefix):]),
+			whiteoutFile
+	}
+
+	return "", ""
+}
+
+// embedLayerEntry embeds file of the layer with contents read from
+// specified section of the spool. Whiteouts are skipped, because they are
+// already embedded.
+func (e *Embedder) embedLayerEntry(
+	header *tar.Header, content *io.SectionReader,
+) error {
+	name := path.Join("/", header.Name)
+
+	// layer can be written in any format, but embedfs requires PAX
+	header.Format = tar.FormatUnknown
+
+	stripInternalRecords(header)
+
+	_, kind := layerWhiteout(header)
+
+	switch {
+	case kind != "":
+		return nil
+
+	case header.Typeflag == tar.TypeDir ||
+		header.Typeflag == tar.TypeSymlink ||
+		header.Typeflag == tar.TypeLink:
+		return e.embedContent(header, header.Name, name, nil)
+
+	case header.Typeflag != tar.TypeReg:
+		e.options.emit(Event{
+			Kind:   EventSkipped,
+			Source: header.Name,
+			Target: name,
+			Reason: "special files are not supported",
+		})
+
+		return nil
+	}
+
+	return e.embedContent(header, header.Name, name, content)
+}
+
+// stripInternalRecords removes PAX records of embedfs from header taken
+// from foreign archive, so it can't turn file into remote, external,
+// encrypted or whiteout entry.
+func stripInternalRecords(header *tar.Header) {
+	for key := range header.PAXRecords {
+		if strings.HasPrefix(key, paxPrefix) {
+			delete(header.PAXRecords, key)
+		}
+	}
+}
+
+// embedWhiteout embeds entry, which removes previously embedded files with
+// specified name or located under it.
+func (e *Embedder) embedWhiteout(name, kind string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for embedded := range e.embedded {
+		if whitedOut(embedded, name, kind) {
+			delete(e.embedded, embedded)
+			e.forgetChecksum(embedded)
+		}
+	}
+
+	if e.options.DryRun {
+		return nil
+	}
+
+	// files packed into pending solid block should be written before
+	// whiteout, which removes them
+	err := e.flushBlock()
+	if err != nil {
+		return err
+	}
+
+	err = e.reserveVolume(0)
+	if err != nil {
+		return err
+	}
+
+	return e.writeHeader(&tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       name,
+		ModTime:    time.Now(),
+		PAXRecords: map[string]string{paxWhiteout: kind},
+	})
+}
+
+// applyWhiteouts removes files, which are removed by whiteouts embedded
+// after them, along with whiteouts themselves.
+func (fs *EmbedFs) applyWhiteouts() {
+	files := fs.files[:0]
+
+	for _, entry := range fs.files {
+		if entry.whiteout == "" {
+			files = append(files, entry)
+			continue
+		}
+
+		kept := files[:0]
+		for _, file := range files {
+			if !whitedOut(file.name, entry.name, entry.whiteout) {
+				kept = append(kept, file)
+			}
+		}
+
+		files = kept
+	}
+
+	fs.files = files
+}
+
+// whitedOut returns true if file with specified name is removed by whiteout
+// of specified kind.
+func whitedOut(name, whiteout, kind string) bool {
+	if strings.HasPrefix(name, strings.TrimSuffix(whiteout, "/")+"/") {
+		return true
+	}
+
+	return kind == whiteoutFile && name == whiteout
+}
@@ -0,0 +1,277 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func writeLayer(writer io.Writer, files map[string]string) {
+	tarWriter := tar.NewWriter(writer)
+
+	for _, name := range []string{
+		"a/1", "b/2", "c/3", "a/.wh.1", "b/.wh..wh..opq", "b/new",
+	} {
+		contents, ok := files[name]
+		if !ok {
+			continue
+		}
+
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = tarWriter.Write([]byte(contents))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestCanEmbedOCILayersWithWhiteouts(t *testing.T) {
+	base := &bytes.Buffer{}
+	writeLayer(base, map[string]string{
+		"a/1": "1\n",
+		"b/2": "2\n",
+		"c/3": "3\n",
+	})
+
+	top := &bytes.Buffer{}
+	compressor := gzip.NewWriter(top)
+	writeLayer(compressor, map[string]string{
+		"a/.wh.1":        "",
+		"b/.wh..wh..opq": "",
+		"b/new":          "new\n",
+	})
+
+	err := compressor.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala74")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, layer := range []io.Reader{base, top} {
+		err = embedder.EmbedOCILayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := OpenStrict(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"/a/1", "/b/2"} {
+		_, err = fs.Open(name)
+		if !errors.Is(err, ErrNoExist) {
+			t.Fatalf("file <%s> is not removed by whiteout: %v", name, err)
+		}
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 || files[0] != "/c/3" || files[1] != "/b/new" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	err = fs.VerifyAll(VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanNotInjectRecordsFromOCILayer(t *testing.T) {
+	layer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(layer)
+
+	err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "a/1",
+		Mode:     0644,
+		Size:     2,
+		PAXRecords: map[string]string{
+			paxURL:          "http://127.0.0.1:1/payload",
+			paxExternalSize: "2",
+			paxHidden:       "true",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = tarWriter.Write([]byte("1\n"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala89")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedOCILayer(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(fs.MustReadFile("/a/1")) != "1\n" {
+		t.Fatal("contents of layer file are not embedded")
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestCanNotRemoveFilesOfTheSameLayerByWhiteout(t *testing.T) {
+	base := &bytes.Buffer{}
+	writeLayer(base, map[string]string{"a/1": "1\n"})
+
+	top := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(top)
+
+	for _, name := range []string{"a/2", "a/.wh..wh..opq"} {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala90")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, layer := range []io.Reader{base, top} {
+		err = embedder.EmbedOCILayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := fs.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0] != "/a/2" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestCanRemoveFilesPackedIntoSolidBlocksByWhiteout(t *testing.T) {
+	base := &bytes.Buffer{}
+	writeLayer(base, map[string]string{"a/1": "1\n", "c/3": "3\n"})
+
+	top := &bytes.Buffer{}
+	writeLayer(top, map[string]string{"a/.wh.1": ""})
+
+	container := mockfile.New("lala97")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{
+		SolidBlockSize: 1024,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, layer := range []io.Reader{base, top} {
+		err = embedder.EmbedOCILayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fs.Open("/a/1")
+	if !errors.Is(err, ErrNoExist) {
+		t.Fatalf("packed file </a/1> is not removed by whiteout: %v", err)
+	}
+
+	if string(fs.MustReadFile("/c/3")) != "3\n" {
+		t.Fatal("packed file </c/3> is not embedded")
+	}
+}
@@ -0,0 +1,49 @@
+package embedfs
+
+import (
+	"archive/tar"
+	"strings"
+)
+
+const paxXattrPrefix = "SCHILY.xattr."
+
+// embedXattrs stores extended attributes of specified file in PAX records
+// of the header.
+func embedXattrs(header *tar.Header, path string) error {
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return err
+	}
+
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	if header.PAXRecords == nil {
+		header.PAXRecords = map[string]string{}
+	}
+
+	for name, value := range xattrs {
+		header.PAXRecords[paxXattrPrefix+name] = value
+	}
+
+	return nil
+}
+
+// restoreXattrs sets extended attributes stored in PAX records of the
+// header to the specified file.
+func restoreXattrs(target string, header *tar.Header) error {
+	xattrs := map[string]string{}
+
+	for key, value := range header.PAXRecords {
+		if strings.HasPrefix(key, paxXattrPrefix) {
+			xattrs[strings.TrimPrefix(key, paxXattrPrefix)] = value
+		}
+	}
+
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	return writeXattrs(target, xattrs)
+}
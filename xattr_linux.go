@@ -0,0 +1,64 @@
+//go:build linux
+
+package embedfs
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err == syscall.ENOTSUP {
+		return nil, nil
+	}
+
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	names := make([]byte, size)
+
+	size, err = syscall.Listxattr(path, names)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := map[string]string{}
+
+	for _, name := range strings.Split(string(names[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, size)
+
+		size, err = syscall.Getxattr(path, name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		xattrs[name] = string(value[:size])
+	}
+
+	return xattrs, nil
+}
+
+func writeXattrs(path string, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		err := syscall.Setxattr(path, name, []byte(value), 0)
+		if err != nil {
+			return fmt.Errorf(
+				`can't set xattr <%s> on <%s>: %w`, name, path, err,
+			)
+		}
+	}
+
+	return nil
+}
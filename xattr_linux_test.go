@@ -0,0 +1,76 @@
+//go:build linux
+
+package embedfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanPreserveXattrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embedfs-xattr")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+
+	err = ioutil.WriteFile(source, []byte("xattr\n"), 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	err = syscall.Setxattr(source, "user.embedfs", []byte("label"), 0)
+	if err != nil {
+		t.Skipf("xattrs are not supported by temporary directory: %s", err)
+	}
+
+	container := mockfile.New("lala39")
+
+	embedder, err := CreateWithOptions(container, EmbedOptions{Xattrs: true})
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(source, "/file")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = fs.ExtractAll(
+		filepath.Join(dir, "extracted"), ExtractOptions{Xattrs: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := make([]byte, 16)
+
+	size, err := syscall.Getxattr(
+		filepath.Join(dir, "extracted", "file"), "user.embedfs", value,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(value[:size]) != "label" {
+		t.Fatalf("unexpected value of restored xattr: %q", value[:size])
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package embedfs
+
+func readXattrs(path string) (map[string]string, error) {
+	return nil, ErrNotImplemented
+}
+
+func writeXattrs(path string, xattrs map[string]string) error {
+	return ErrNotImplemented
+}
@@ -0,0 +1,16 @@
+package embedfs
+
+import (
+	"archive/zip"
+)
+
+// OpenZip returns reader of zip archive embedded as specified file, so
+// nested archives can be browsed without copying them out of embedfs.
+func (fs *EmbedFs) OpenZip(path string) (*zip.Reader, error) {
+	section, err := fs.SectionReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(section, section.Size())
+}
@@ -0,0 +1,72 @@
+package embedfs
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/seletskiy/go-mock-file"
+)
+
+func TestCanOpenEmbeddedZip(t *testing.T) {
+	archive, err := ioutil.TempFile("", "embedfs-zip")
+	if err != nil {
+		panic(err)
+	}
+
+	defer os.Remove(archive.Name())
+
+	writer := zip.NewWriter(archive)
+
+	zipped, err := writer.Create("nested/file")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = zipped.Write([]byte("zipped"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	err = archive.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	container := mockfile.New("lala29")
+
+	embedder, err := Create(container)
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.EmbedFile(archive.Name(), "/bundle.zip")
+	if err != nil {
+		panic(err)
+	}
+
+	err = embedder.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	fs, err := Open(container)
+	if err != nil {
+		panic(err)
+	}
+
+	reader, err := fs.OpenZip("/bundle.zip")
+	if err != nil {
+		panic(err)
+	}
+
+	if len(reader.File) != 1 || reader.File[0].Name != "nested/file" {
+		t.Fatalf("unexpected zip contents: %v", reader.File)
+	}
+}